@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"queueCtl/internal/archive"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"queueCtl/internal/output"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func ArchiveCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
+	archiveCmd := &cobra.Command{
+		Use:   "archive",
+		Short: "Move finished jobs to compressed archive files",
+		Long: `Move jobs last updated before --before, with their attempt history, out of
+the database into gzip-compressed JSON lines segments under --to. A
+manifest.json in that directory lists each segment with its SHA-256
+checksum. Jobs are removed from the database in the same transaction that
+writes their segment.`,
+		Args: cobra.NoArgs,
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			dir, _ := flags.GetString("to")
+			beforeValue, _ := flags.GetString("before")
+			states, _ := flags.GetStringSlice("state")
+			dryRun, _ := flags.GetBool("dry-run")
+			segmentSize, _ := flags.GetInt("segment-size")
+
+			if beforeValue == "" {
+				return fmt.Errorf("--before is required")
+			}
+			before, err := parseTimeFlag(beforeValue, time.Now())
+			if err != nil {
+				return fmt.Errorf("--before: %w", err)
+			}
+			for _, state := range states {
+				if !model.IsTerminal(state) {
+					return fmt.Errorf("--state: only finished jobs can be archived, not %q", state)
+				}
+			}
+			filter := storage.JobFilter{States: states, UpdatedBefore: before}
+
+			if dryRun {
+				n, err := store.CountJobs(filter)
+				if err != nil {
+					return err
+				}
+				return printResult(cmd, output.Result{
+					Columns: []string{"jobs"},
+					Rows:    [][]string{{strconv.Itoa(n)}},
+					Records: map[string]any{"dry_run": true, "jobs": n},
+					Text: func(w io.Writer) error {
+						_, err := fmt.Fprintf(w, "Dry run: would archive %d job(s) last updated before %s.\n", n, before.Format(time.RFC3339))
+						return err
+					},
+				})
+			}
+
+			if dir == "" {
+				return fmt.Errorf("--to is required when archive-dir is not configured")
+			}
+			arch, err := archive.Open(dir)
+			if err != nil {
+				return err
+			}
+			moved, segments, err := arch.Move(context.Background(), store, filter, segmentSize)
+			if err != nil {
+				return fmt.Errorf("archived %d job(s) before failing: %w", moved, err)
+			}
+			if segments == nil {
+				segments = []archive.Segment{}
+			}
+			return printResult(cmd, output.Result{
+				Columns: segmentColumns,
+				Rows:    segmentRows(segments),
+				Records: segments,
+				Text: func(w io.Writer) error {
+					_, err := fmt.Fprintf(w, "Archived %d job(s) to %s in %d segment(s).\n", moved, arch.Dir, len(segments))
+					return err
+				},
+			})
+		}),
+	}
+	archiveCmd.Flags().String("to", cfg.ArchiveDir, "Archive directory (default: the archive-dir setting)")
+	archiveCmd.Flags().String("before", "", "Archive jobs last updated before this time (RFC3339, YYYY-MM-DD, or a duration ago like 30d)")
+	archiveCmd.Flags().StringSlice("state", []string{model.StateCompleted, model.StateDead, model.StateCanceled}, "States to archive")
+	archiveCmd.Flags().Int("segment-size", archive.SegmentSize, "Maximum jobs per segment file")
+	archiveCmd.Flags().Bool("dry-run", false, "Count the jobs that would be archived without moving them")
+
+	searchCmd := &cobra.Command{
+		Use:   "search",
+		Short: "Find archived jobs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			arch, q, err := archiveQuery(cmd, nil)
+			if err != nil {
+				return err
+			}
+			found, err := arch.Search(q)
+			if err != nil {
+				return err
+			}
+			if found == nil {
+				found = []archive.Found{}
+			}
+			rows := make([][]string, 0, len(found))
+			for _, f := range found {
+				rows = append(rows, []string{
+					f.Job.ID,
+					f.Job.Queue,
+					f.Job.State,
+					f.Job.Command,
+					strconv.Itoa(len(f.Attempts)),
+					f.Job.UpdatedAt.Format(time.RFC3339),
+					f.Segment,
+				})
+			}
+			columns := []string{"id", "queue", "state", "command", "attempts", "updated_at", "segment"}
+			return printResult(cmd, output.Result{
+				Columns: columns,
+				Rows:    rows,
+				Records: found,
+				Text: func(w io.Writer) error {
+					if len(found) == 0 {
+						fmt.Fprintln(w, "No archived jobs match.")
+						return nil
+					}
+					return output.WriteTable(w, columns, rows)
+				},
+			})
+		},
+	}
+	addArchiveQueryFlags(searchCmd, cfg)
+
+	restoreCmd := &cobra.Command{
+		Use:   "restore [job-id...]",
+		Short: "Copy archived jobs back into the database",
+		Long: `Copy the archived jobs named on the command line, or matching the filters,
+back into the database with their attempt history. Their updated_at is set
+to now, restarting their retention period. Jobs that already exist are
+skipped. The archive itself is not modified.`,
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && !hasArchiveQuery(cmd) {
+				return fmt.Errorf("give job IDs or at least one filter")
+			}
+			arch, q, err := archiveQuery(cmd, args)
+			if err != nil {
+				return err
+			}
+			restored, skipped, err := arch.Restore(store, q)
+			if err != nil {
+				return err
+			}
+			if restored == nil {
+				restored = []string{}
+			}
+			if skipped == nil {
+				skipped = []string{}
+			}
+			rows := make([][]string, 0, len(restored)+len(skipped))
+			for _, id := range restored {
+				rows = append(rows, []string{id, "restored"})
+			}
+			for _, id := range skipped {
+				rows = append(rows, []string{id, "skipped (already exists)"})
+			}
+			return printResult(cmd, output.Result{
+				Columns: []string{"id", "result"},
+				Rows:    rows,
+				Records: map[string][]string{"restored": restored, "skipped": skipped},
+				Text: func(w io.Writer) error {
+					_, err := fmt.Fprintf(w, "Restored %d job(s) from the archive; %d skipped because they already exist.\n", len(restored), len(skipped))
+					return err
+				},
+			})
+		}),
+	}
+	addArchiveQueryFlags(restoreCmd, cfg)
+
+	archiveCmd.AddCommand(searchCmd)
+	archiveCmd.AddCommand(restoreCmd)
+	return archiveCmd
+}
+
+// archiveQueryFlags are the filter flags addArchiveQueryFlags defines.
+var archiveQueryFlags = []string{"id-prefix", "queue", "state", "command", "updated-after", "updated-before"}
+
+func addArchiveQueryFlags(cmd *cobra.Command, cfg *config.Config) {
+	cmd.Flags().String("from", cfg.ArchiveDir, "Archive directory (default: the archive-dir setting)")
+	cmd.Flags().String("id-prefix", "", "Only jobs whose ID starts with this prefix")
+	cmd.Flags().String("queue", "", "Only jobs in this queue")
+	cmd.Flags().StringSlice("state", nil, "Only jobs in these states")
+	cmd.Flags().String("command", "", "Only jobs whose command contains this text")
+	cmd.Flags().String("updated-after", "", "Only jobs last updated at or after this time (RFC3339, YYYY-MM-DD, or a duration ago like 24h)")
+	cmd.Flags().String("updated-before", "", "Only jobs last updated before this time")
+}
+
+func hasArchiveQuery(cmd *cobra.Command) bool {
+	for _, name := range archiveQueryFlags {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// archiveQuery opens the archive named by --from and builds a query from
+// the filter flags and the given job IDs.
+func archiveQuery(cmd *cobra.Command, ids []string) (*archive.Archive, archive.Query, error) {
+	flags := cmd.Flags()
+	now := time.Now()
+
+	q := archive.Query{IDs: ids}
+	q.IDPrefix, _ = flags.GetString("id-prefix")
+	q.Queue, _ = flags.GetString("queue")
+	q.States, _ = flags.GetStringSlice("state")
+	q.CommandContains, _ = flags.GetString("command")
+	for _, bound := range []struct {
+		flag string
+		dest *time.Time
+	}{
+		{"updated-after", &q.UpdatedAfter},
+		{"updated-before", &q.UpdatedBefore},
+	} {
+		value, _ := flags.GetString(bound.flag)
+		t, err := parseTimeFlag(value, now)
+		if err != nil {
+			return nil, q, fmt.Errorf("--%s: %w", bound.flag, err)
+		}
+		*bound.dest = t
+	}
+
+	dir, _ := flags.GetString("from")
+	if dir == "" {
+		return nil, q, fmt.Errorf("--from is required when archive-dir is not configured")
+	}
+	arch, err := archive.Open(dir)
+	return arch, q, err
+}
+
+// segmentColumns are the table and csv columns of an archive run.
+var segmentColumns = []string{"file", "jobs", "bytes", "oldest_update", "newest_update", "sha256"}
+
+func segmentRows(segments []archive.Segment) [][]string {
+	rows := make([][]string, 0, len(segments))
+	for _, s := range segments {
+		rows = append(rows, []string{
+			s.File,
+			strconv.Itoa(s.Jobs),
+			strconv.FormatInt(s.Bytes, 10),
+			s.OldestUpdate.Format(time.RFC3339),
+			s.NewestUpdate.Format(time.RFC3339),
+			s.SHA256,
+		})
+	}
+	return rows
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"queueCtl/internal/audit"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"queueCtl/internal/notify"
+	"queueCtl/internal/output"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// redactedFlags are recorded in the audit log with their value masked:
+// secrets entirely, webhook URLs as notify.RedactURL shows them.
+var redactedFlags = map[string]func(string) string{
+	"secret": func(string) string { return "***" },
+	"url":    notify.RedactURL,
+}
+
+// audited wraps the RunE of a command that changes the queue or its
+// configuration, so that every run is recorded in the audit log with its
+// arguments and outcome.
+func audited(store *storage.Store, run func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		err := run(cmd, args)
+		audit.Record(store, model.AuditSourceCLI, auditAction(cmd), auditArgs(cmd, args), err)
+		return err
+	}
+}
+
+// auditAction names cmd by its path below the root command, e.g.
+// "dlq retry".
+func auditAction(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+}
+
+// auditArgs lists cmd's positional arguments followed by the flags set on
+// its own command line.
+func auditArgs(cmd *cobra.Command, args []string) []string {
+	out := append([]string{}, args...)
+	inherited := cmd.InheritedFlags()
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		if inherited.Lookup(f.Name) != nil {
+			return
+		}
+		value := f.Value.String()
+		if redact := redactedFlags[f.Name]; redact != nil {
+			value = redact(value)
+		}
+		out = append(out, "--"+f.Name+"="+value)
+	})
+	return out
+}
+
+func AuditCmd(store *storage.Store) *cobra.Command {
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Show who changed the queue or its configuration",
+		Long: `Every command that changes the queue or its configuration (enqueue,
+config set, dlq retry and purge, worker stop, ...) and every change made
+through the Go client is recorded in an append-only audit log, with the
+OS user, host, time, arguments and outcome.`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List audit log entries, most recent last",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			var filter storage.AuditFilter
+			filter.Action, _ = flags.GetString("action")
+			filter.User, _ = flags.GetString("user")
+			filter.Host, _ = flags.GetString("host")
+			filter.Source, _ = flags.GetString("source")
+			filter.Outcome, _ = flags.GetString("outcome")
+			filter.Limit, _ = flags.GetInt("limit")
+			if filter.Source != "" && filter.Source != model.AuditSourceCLI && filter.Source != model.AuditSourceAPI {
+				return fmt.Errorf("--source: use %s or %s", model.AuditSourceCLI, model.AuditSourceAPI)
+			}
+			if filter.Outcome != "" && filter.Outcome != model.AuditOK && filter.Outcome != model.AuditError {
+				return fmt.Errorf("--outcome: use %s or %s", model.AuditOK, model.AuditError)
+			}
+			now := time.Now()
+			for _, f := range []struct {
+				name string
+				dest *time.Time
+			}{{"since", &filter.Since}, {"until", &filter.Until}} {
+				value, _ := flags.GetString(f.name)
+				t, err := parseTimeFlag(value, now)
+				if err != nil {
+					return fmt.Errorf("--%s: %w", f.name, err)
+				}
+				*f.dest = t
+			}
+
+			entries, err := store.ListAudit(filter)
+			if err != nil {
+				return fmt.Errorf("failed to list audit entries: %w", err)
+			}
+			return printResult(cmd, output.Result{
+				Columns: auditColumns,
+				Rows:    auditRows(entries),
+				Records: entries,
+				Text: func(w io.Writer) error {
+					if len(entries) == 0 {
+						fmt.Fprintln(w, "No audit entries.")
+						return nil
+					}
+					return output.WriteTable(w, auditColumns, auditRows(entries))
+				},
+			})
+		},
+	}
+	listCmd.Flags().String("action", "", "Only this action, or the actions under it (e.g. 'dlq' or 'config set')")
+	listCmd.Flags().String("user", "", "Only actions by this OS user")
+	listCmd.Flags().String("host", "", "Only actions from this host")
+	listCmd.Flags().String("source", "", "Only actions from this source (cli, api)")
+	listCmd.Flags().String("outcome", "", "Only actions with this outcome (ok, error)")
+	listCmd.Flags().String("since", "", "Only actions at or after this time (RFC3339, YYYY-MM-DD, or a duration like 24h)")
+	listCmd.Flags().String("until", "", "Only actions before this time")
+	listCmd.Flags().Int("limit", 50, "How many of the most recent entries to show (0 for all)")
+	auditCmd.AddCommand(listCmd)
+
+	return auditCmd
+}
+
+var auditColumns = []string{"id", "at", "user", "host", "source", "action", "args", "outcome"}
+
+func auditRows(entries []model.AuditEntry) [][]string {
+	rows := make([][]string, len(entries))
+	for i, e := range entries {
+		outcome := e.Outcome
+		if e.Error != "" {
+			outcome += ": " + e.Error
+		}
+		rows[i] = []string{
+			strconv.FormatInt(e.ID, 10),
+			e.At.Format(time.RFC3339),
+			e.User,
+			e.Host,
+			e.Source,
+			e.Action,
+			strings.Join(e.Args, " "),
+			outcome,
+		}
+	}
+	return rows
+}
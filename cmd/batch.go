@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"queueCtl/internal/output"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func BatchCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
+	batchCmd := &cobra.Command{
+		Use:   "batch",
+		Short: "Run groups of jobs and act when all of them finish",
+	}
+
+	createCmd := &cobra.Command{
+		Use:   "create [file|-]",
+		Short: "Enqueue a group of jobs as one batch",
+		Long: `Enqueue the jobs in file (or stdin) as one batch. The input is a JSON array
+of jobs or one job object per line, in the same form 'enqueue' takes.
+Members without an id get <batch-id>-<n>.
+
+When every member has reached a final state the batch finishes: it fails
+under the fail_on_dead policy if any member is dead, canceled or expired, and
+succeeds otherwise. The --callback job is then enqueued with the
+QUEUECTL_BATCH_ID, QUEUECTL_BATCH_STATE, QUEUECTL_BATCH_COMPLETED,
+QUEUECTL_BATCH_DEAD, QUEUECTL_BATCH_CANCELED and QUEUECTL_BATCH_EXPIRED
+environment variables.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			batchID, _ := flags.GetString("id")
+			policy, _ := flags.GetString("policy")
+			callbackJSON, _ := flags.GetString("callback")
+
+			if !slices.Contains(model.BatchPolicies, policy) {
+				return fmt.Errorf("--policy must be %s or %s, not %q", model.PolicyFailOnDead, model.PolicyIgnoreDead, policy)
+			}
+			if batchID == "" {
+				var err error
+				if batchID, err = newBatchID(); err != nil {
+					return err
+				}
+			}
+
+			r := io.Reader(os.Stdin)
+			if len(args) == 1 && args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			jobs, err := readJobs(r)
+			if err != nil {
+				return err
+			}
+			if len(jobs) == 0 {
+				return errors.New("a batch needs at least one job")
+			}
+
+			now := time.Now()
+			seen := make(map[string]bool)
+			for i, job := range jobs {
+				if job.ID == "" {
+					job.ID = fmt.Sprintf("%s-%d", batchID, i+1)
+				}
+				if err := job.Prepare(now, cfg.MaxRetries); err != nil {
+					return fmt.Errorf("job %d: %w", i+1, err)
+				}
+				if seen[job.ID] {
+					return fmt.Errorf("job %d: id %s is used twice", i+1, job.ID)
+				}
+				seen[job.ID] = true
+			}
+
+			batch := &model.Batch{ID: batchID, Policy: policy, CreatedAt: now}
+			if callbackJSON != "" {
+				var callback model.Job
+				if err := json.Unmarshal([]byte(callbackJSON), &callback); err != nil {
+					return fmt.Errorf("invalid --callback JSON: %w", err)
+				}
+				if callback.ID == "" {
+					callback.ID = batchID + ".callback"
+				}
+				// Validate a copy: the callback is prepared when the batch
+				// finishes, so that a ttl counts from then.
+				prepared := callback
+				if err := prepared.Prepare(now, cfg.MaxRetries); err != nil {
+					return fmt.Errorf("--callback: %w", err)
+				}
+				callback.MaxRetries = prepared.MaxRetries
+				batch.Callback = &callback
+			}
+
+			if err := store.CreateBatch(batch, jobs); err != nil {
+				return fmt.Errorf("failed to create batch: %w", err)
+			}
+			batch.Counts = map[string]int{model.StatePending: len(jobs)}
+			return printResult(cmd, output.Result{
+				Columns: []string{"batch", "jobs", "policy"},
+				Rows:    [][]string{{batch.ID, strconv.Itoa(batch.Total), batch.Policy}},
+				Records: batch,
+				Text: func(w io.Writer) error {
+					_, err := fmt.Fprintf(w, "Batch %s created with %d job(s).\n", batch.ID, batch.Total)
+					return err
+				},
+			})
+		}),
+	}
+	createCmd.Flags().String("id", "", "Batch ID (default: generated)")
+	createCmd.Flags().String("policy", model.PolicyFailOnDead, "fail_on_dead: dead, canceled or expired members fail the batch; ignore_dead: the batch succeeds once all members finish")
+	createCmd.Flags().String("callback", "", "Job JSON to enqueue when the batch finishes")
+
+	statusCmd := &cobra.Command{
+		Use:   "status <batch-id>",
+		Short: "Show a batch's progress",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			batch, err := store.GetBatch(args[0])
+			if err != nil {
+				return err
+			}
+			counts := orderedStats(batch.Counts)
+			rows := make([][]string, len(counts))
+			for i, c := range counts {
+				rows[i] = []string{c.State, strconv.Itoa(c.Count)}
+			}
+			return printResult(cmd, output.Result{
+				Columns: []string{"state", "count"},
+				Rows:    rows,
+				Records: batch,
+				Text: func(w io.Writer) error {
+					finished := 0
+					for state, n := range batch.Counts {
+						if model.IsTerminal(state) {
+							finished += n
+						}
+					}
+					fmt.Fprintf(w, "Batch: \t\t%s\n", batch.ID)
+					fmt.Fprintf(w, "State: \t\t%s\n", batch.State)
+					fmt.Fprintf(w, "Policy: \t%s\n", batch.Policy)
+					fmt.Fprintf(w, "Progress: \t%d/%d finished\n", finished, batch.Total)
+					fmt.Fprintf(w, "Created: \t%s\n", batch.CreatedAt.Format(time.RFC3339))
+					if !batch.FinishedAt.IsZero() {
+						fmt.Fprintf(w, "Finished: \t%s\n", batch.FinishedAt.Format(time.RFC3339))
+					}
+					if batch.Callback != nil {
+						fmt.Fprintf(w, "Callback: \t%s\n", templateSummary(batch.Callback))
+					}
+					fmt.Fprintln(w)
+					return output.WriteTable(w, []string{"state", "count"}, rows)
+				},
+			})
+		},
+	}
+
+	batchCmd.AddCommand(createCmd)
+	batchCmd.AddCommand(statusCmd)
+	return batchCmd
+}
+
+// readJobs reads a JSON array of jobs, or a stream of job objects.
+func readJobs(r io.Reader) ([]*model.Job, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*model.Job
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &jobs); err != nil {
+			return nil, fmt.Errorf("invalid jobs JSON: %w", err)
+		}
+		return jobs, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var job model.Job
+		if err := dec.Decode(&job); err == io.EOF {
+			return jobs, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid job JSON (job %d): %w", len(jobs)+1, err)
+		}
+		jobs = append(jobs, &job)
+	}
+}
+
+// newBatchID returns a random batch ID.
+func newBatchID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "batch-" + hex.EncodeToString(b), nil
+}
@@ -1,15 +1,20 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
 	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/output"
+	"queueCtl/internal/tracing"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
-func ConfigCmd(cfg *config.Config) *cobra.Command {
+func ConfigCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
 	configCmd := &cobra.Command{
 		Use:   "config",
 		Short: "Manage configuration",
@@ -19,21 +24,26 @@ func ConfigCmd(cfg *config.Config) *cobra.Command {
 		Use:   "show",
 		Short: "Show the current configuration",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			data, err := json.MarshalIndent(cfg, "", "  ")
+			shown := *cfg
+			shown.Notifiers = redactNotifiers(cfg.Notifiers)
+			rows, err := output.Flatten(shown)
 			if err != nil {
 				return err
 			}
-			fmt.Println(string(data))
-			return nil
+			return printResult(cmd, output.Result{
+				Columns: []string{"key", "value"},
+				Rows:    rows,
+				Records: shown,
+			})
 		},
 	}
 
 
 	setCmd := &cobra.Command{
 		Use:   "set <key> <value>",
-		Short: "Set a configuration value (data-dir, max-retries, backoff-base)",
+		Short: "Set a configuration value (data-dir, max-retries, backoff-base, job-timeout, limit-cpu-seconds, limit-memory-mb, limit-open-files, limit-max-procs, cgroup-parent, gc-interval, vacuum-interval, retention-completed, retention-dead, retention-canceled, retention-expired, archive-dir, event-retention, tracing-exporter, tracing-endpoint, tracing-file, tracing-service-name)",
 		Args:  cobra.ExactArgs(2),
-		RunE: func(cmd *cobra.Command, args []string) error {
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
 			key := args[0]
 			value := args[1]
 
@@ -52,6 +62,77 @@ func ConfigCmd(cfg *config.Config) *cobra.Command {
 					return fmt.Errorf("invalid value for backoff-base: %s", value)
 				}
 				cfg.BackoffBase = f
+			case "job-timeout":
+				if d, err := config.ParseDuration(value); err != nil || d <= 0 {
+					return fmt.Errorf("invalid value for job-timeout: %s", value)
+				}
+				cfg.JobTimeout = value
+			case "limit-cpu-seconds", "limit-memory-mb", "limit-open-files", "limit-max-procs":
+				i, err := strconv.Atoi(value)
+				if err != nil || i < 0 {
+					return fmt.Errorf("invalid value for %s: %s", key, value)
+				}
+				switch key {
+				case "limit-cpu-seconds":
+					cfg.DefaultLimits.CPUSeconds = i
+				case "limit-memory-mb":
+					cfg.DefaultLimits.MemoryMB = i
+				case "limit-open-files":
+					cfg.DefaultLimits.OpenFiles = i
+				case "limit-max-procs":
+					cfg.DefaultLimits.MaxProcs = i
+				}
+			case "cgroup-parent":
+				cfg.CgroupParent = value
+			case "archive-dir":
+				cfg.ArchiveDir = value
+			case "gc-interval":
+				d, err := config.ParseDuration(value)
+				if err != nil || d <= 0 {
+					return fmt.Errorf("invalid value for gc-interval: %s", value)
+				}
+				cfg.GCInterval = value
+			case "vacuum-interval":
+				if value != "" {
+					if d, err := config.ParseDuration(value); err != nil || d <= 0 {
+						return fmt.Errorf("invalid value for vacuum-interval: %s", value)
+					}
+				}
+				cfg.VacuumInterval = value
+			case "retention-completed", "retention-dead", "retention-canceled", "retention-expired":
+				state := strings.TrimPrefix(key, "retention-")
+				if value == "" {
+					delete(cfg.Retention, state)
+					break
+				}
+				if d, err := config.ParseDuration(value); err != nil || d <= 0 {
+					return fmt.Errorf("invalid value for %s: %s", key, value)
+				}
+				if cfg.Retention == nil {
+					cfg.Retention = make(map[string]string)
+				}
+				cfg.Retention[state] = value
+			case "event-retention":
+				if value != "" {
+					if d, err := config.ParseDuration(value); err != nil || d <= 0 {
+						return fmt.Errorf("invalid value for event-retention: %s", value)
+					}
+				}
+				cfg.EventRetention = value
+			case "tracing-exporter":
+				if value != "" && !slices.Contains(tracing.Exporters, value) {
+					return fmt.Errorf("invalid value for tracing-exporter: %s (use otlp, file, or \"\" to disable)", value)
+				}
+				cfg.Tracing.Exporter = value
+			case "tracing-endpoint":
+				if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+					return fmt.Errorf("invalid value for tracing-endpoint: %s (must start with http:// or https://)", value)
+				}
+				cfg.Tracing.Endpoint = value
+			case "tracing-file":
+				cfg.Tracing.File = value
+			case "tracing-service-name":
+				cfg.Tracing.ServiceName = value
 			default:
 				return fmt.Errorf("unknown config key: %s", key)
 			}
@@ -60,9 +141,16 @@ func ConfigCmd(cfg *config.Config) *cobra.Command {
 				return err
 			}
 
-			fmt.Printf("Updated. %s = %s\n", key, value)
-			return nil
-		},
+			return printResult(cmd, output.Result{
+				Columns: []string{"key", "value"},
+				Rows:    [][]string{{key, value}},
+				Records: map[string]string{"key": key, "value": value},
+				Text: func(w io.Writer) error {
+					_, err := fmt.Fprintf(w, "Updated. %s = %s\n", key, value)
+					return err
+				},
+			})
+		}),
 	}
 
 	configCmd.AddCommand(showCmd)
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"queueCtl/internal/config"
+	"queueCtl/internal/daemon"
+	"queueCtl/internal/database"
+	"queueCtl/internal/health"
+	"queueCtl/internal/output"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// daemonStartTimeout is how long 'worker start --daemon' waits for the
+// background pool to take its PID file.
+const daemonStartTimeout = 10 * time.Second
+
+// unitStopTimeout is the TimeoutStopSec of the generated unit: enough for
+// running jobs, pending notifications and spans to finish after SIGTERM.
+const unitStopTimeout = 30 * time.Second
+
+// startDaemon runs 'worker start' again in the background, without
+// --daemon, and returns once it is running.
+func startDaemon(cmd *cobra.Command, cfg *config.Config) error {
+	if pid := daemon.ReadPIDFile(pidFilePath(cfg)); pid > 0 && daemon.ProcessAlive(pid) {
+		return fmt.Errorf("%w (pid %d); stop it with 'worker stop' first", daemon.ErrRunning, pid)
+	}
+
+	var args []string
+	for _, arg := range os.Args[1:] {
+		if arg == "--daemon" || strings.HasPrefix(arg, "--daemon=") {
+			continue
+		}
+		args = append(args, arg)
+	}
+	logPath, _ := cmd.Flags().GetString("log-file")
+	if logPath == "" {
+		logPath = filepath.Join(cfg.DataDir, "worker.log")
+		args = append(args, "--log-file", logPath)
+	}
+
+	pid, err := daemon.Start(args, logPath, pidFilePath(cfg), daemonStartTimeout)
+	if err != nil {
+		return err
+	}
+	return printResult(cmd, output.Result{
+		Columns: []string{"pid", "log_file"},
+		Rows:    [][]string{{strconv.Itoa(pid), logPath}},
+		Records: map[string]any{"pid": pid, "log_file": logPath},
+		Text: func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "Worker pool started in the background (pid %d), logging to %s\n", pid, logPath)
+			return err
+		},
+	})
+}
+
+// sdNotify sends state to systemd, logging failures.
+func sdNotify(state string) {
+	if err := daemon.Notify(state); err != nil {
+		slog.Warn("could not notify systemd", "err", err)
+	}
+}
+
+// reloadConfig re-reads the configuration file into live, on SIGHUP. The
+// current configuration stays when the file cannot be read.
+func reloadConfig(live *config.Live) {
+	sdNotify(daemon.NotifyReloading)
+	defer sdNotify(daemon.NotifyReady)
+
+	next, err := config.LoadConfig()
+	if err != nil {
+		slog.Error("could not reload the configuration, keeping the current one", "err", err)
+		return
+	}
+	if restart := config.RestartRequired(live.Get(), next); len(restart) > 0 {
+		slog.Warn("some changed settings take effect only after a restart", "settings", strings.Join(restart, ", "))
+	}
+	live.Set(next)
+	slog.Info("configuration reloaded")
+}
+
+// feedWatchdog pings the systemd watchdog every interval while the pool's
+// liveness checks pass, so that systemd restarts a wedged pool.
+func feedWatchdog(ctx context.Context, interval time.Duration, monitor *health.Monitor) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report := monitor.Live()
+			if !report.Healthy() {
+				slog.Warn("health checks failing, withholding the watchdog ping", "checks", report.Checks)
+				continue
+			}
+			sdNotify(daemon.NotifyWatchdog)
+		}
+	}
+}
+
+func installUnitCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "install-unit",
+		Short: "Write a systemd unit that runs the worker pool",
+		Long: `Write a systemd service unit that runs 'worker start' in the foreground as a
+Type=notify service: systemd learns when the pool is ready, restarts it
+when it crashes or stops feeding the watchdog, reloads its configuration
+with 'systemctl reload' (SIGHUP) and stops it gracefully with SIGTERM.
+
+The unit runs this executable from the current directory, as the current
+user, and reads the same configuration file. It is written to
+/etc/systemd/system/<name>.service, or with --user-unit to the user's
+systemd directory; --file - prints it instead.`,
+		Args: cobra.NoArgs,
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			name, _ := flags.GetString("name")
+			count, _ := flags.GetInt("count")
+			listen, _ := flags.GetString("listen")
+			watchdog, _ := flags.GetDuration("watchdog")
+			userUnit, _ := flags.GetBool("user-unit")
+			path, _ := flags.GetString("file")
+			if count < 1 {
+				return fmt.Errorf("--count must be at least 1")
+			}
+
+			exe, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+				exe = resolved
+			}
+			wd, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			configDir, err := os.UserConfigDir()
+			if err != nil {
+				return err
+			}
+			opts := daemon.UnitOptions{
+				Description:      "queuectl worker pool",
+				Exec:             exe,
+				Args:             []string{"worker", "start", "--count", strconv.Itoa(count)},
+				WorkingDirectory: wd,
+				Environment:      map[string]string{"XDG_CONFIG_HOME": configDir},
+				Watchdog:         watchdog,
+				StopTimeout:      unitStopTimeout,
+				UserUnit:         userUnit,
+			}
+			if listen != "" {
+				opts.Args = append(opts.Args, "--listen", listen)
+			}
+			if !userUnit {
+				if u, err := user.Current(); err == nil {
+					opts.User = u.Username
+				}
+			}
+			unit, err := daemon.Unit(opts)
+			if err != nil {
+				return err
+			}
+
+			if path == "-" {
+				_, err := io.WriteString(os.Stdout, unit)
+				return err
+			}
+			if path == "" {
+				dir := "/etc/systemd/system"
+				if userUnit {
+					dir = filepath.Join(configDir, "systemd", "user")
+					if err := os.MkdirAll(dir, 0755); err != nil {
+						return err
+					}
+				}
+				path = filepath.Join(dir, name+".service")
+			}
+			if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+				return fmt.Errorf("could not write the unit: %w", err)
+			}
+
+			systemctl := "systemctl"
+			if userUnit {
+				systemctl += " --user"
+			}
+			return printResult(cmd, output.Result{
+				Columns: []string{"unit", "file"},
+				Rows:    [][]string{{name, path}},
+				Records: map[string]string{"unit": name, "file": path},
+				Text: func(w io.Writer) error {
+					fmt.Fprintf(w, "Wrote %s\n", path)
+					_, err := fmt.Fprintf(w, "Enable it with: %s daemon-reload && %s enable --now %s\n", systemctl, systemctl, name)
+					return err
+				},
+			})
+		}),
+	}
+	cmd.Flags().String("name", "queuectl-worker", "Name of the unit")
+	cmd.Flags().Int("count", 1, "Number of workers the service starts")
+	cmd.Flags().String("listen", "", "Address the service serves HTTP on (events and health endpoints)")
+	cmd.Flags().Duration("watchdog", 30*time.Second, "WatchdogSec of the unit; 0 disables the watchdog")
+	cmd.Flags().Bool("user-unit", false, "Write a unit for the per-user service manager (systemctl --user)")
+	cmd.Flags().String("file", "", "Where to write the unit; - prints it (default /etc/systemd/system/<name>.service)")
+	return cmd
+}
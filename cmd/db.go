@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/output"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func DbCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
+	dbCmd := &cobra.Command{
+		Use:   "db",
+		Short: "Back up and restore the queue database",
+	}
+
+	backupCmd := &cobra.Command{
+		Use:   "backup <file>",
+		Short: "Write a consistent snapshot of the database, safe while workers run",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+			if err := store.Backup(path); err != nil {
+				return fmt.Errorf("backup failed: %w", err)
+			}
+			return printResult(cmd, output.Result{
+				Columns: []string{"file"},
+				Rows:    [][]string{{path}},
+				Records: map[string]string{"file": path},
+				Text: func(w io.Writer) error {
+					_, err := fmt.Fprintf(w, "Database backed up to %s\n", path)
+					return err
+				},
+			})
+		},
+	}
+
+	restoreCmd := &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Replace the database with a backup",
+		Long: `Replace the database with a backup made by 'db backup'. The backup is
+checked for integrity first, and the current database is saved next to it
+as queue.db.before-restore-<time>. Jobs that were processing when the
+backup was taken are returned to pending. The audit log is kept as it is
+rather than rewound to the backup's, and the restore is recorded in it.
+
+Restoring while workers run would pull the database out from under them,
+so it is refused while a worker pool is registered or any job holds a
+live lease; --force skips those checks.`,
+		Args: cobra.ExactArgs(1),
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+			force, _ := cmd.Flags().GetBool("force")
+
+			if !force {
+				status, err := readWorkerStatus(cfg)
+				if err != nil {
+					return err
+				}
+				if status != nil {
+					return fmt.Errorf("a worker pool (pid %d) is running; stop it with 'worker stop' first", status.WorkerPoolPid)
+				}
+				leased, err := store.CountLeased()
+				if err != nil {
+					return err
+				}
+				if leased > 0 {
+					return fmt.Errorf("%d job(s) are being processed by workers; stop them first", leased)
+				}
+			}
+
+			if err := storage.VerifyBackup(path); err != nil {
+				return err
+			}
+			safety := filepath.Join(cfg.DataDir, "queue.db.before-restore-"+time.Now().Format("20060102T150405"))
+			if err := store.Backup(safety); err != nil {
+				return fmt.Errorf("could not save the current database before restoring: %w", err)
+			}
+			requeued, err := store.Restore(path)
+			if err != nil {
+				return fmt.Errorf("restore failed (the previous database is saved at %s): %w", safety, err)
+			}
+
+			return printResult(cmd, output.Result{
+				Columns: []string{"requeued_job"},
+				Rows:    idRows(requeued),
+				Records: map[string]any{"file": path, "previous": safety, "requeued": requeued},
+				Text: func(w io.Writer) error {
+					fmt.Fprintf(w, "Database restored from %s. The previous database is saved at %s.\n", path, safety)
+					if len(requeued) > 0 {
+						fmt.Fprintf(w, "%d job(s) that were processing at backup time returned to pending.\n", len(requeued))
+					}
+					return nil
+				},
+			})
+		}),
+	}
+	restoreCmd.Flags().Bool("force", false, "Restore even if workers appear to be running")
+
+	dbCmd.AddCommand(backupCmd)
+	dbCmd.AddCommand(restoreCmd)
+	return dbCmd
+}
+
+// idRows makes single-column rows from job IDs.
+func idRows(ids []string) [][]string {
+	rows := make([][]string, 0, len(ids))
+	for _, id := range ids {
+		rows = append(rows, []string{id})
+	}
+	return rows
+}
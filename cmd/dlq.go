@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
+	"os"
+	"queueCtl/internal/config"
 	"queueCtl/internal/model"
 	"queueCtl/internal/database"
+	"queueCtl/internal/output"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -19,51 +25,337 @@ func DlqCmd(store *storage.Store) *cobra.Command {
 	// --- 'dlq list' Subcommand ---
 	listCmd := &cobra.Command{
 		Use:   "list",
-		Short: "List all jobs in the DLQ",
+		Short: "List jobs in the DLQ, optionally filtered",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			jobs, err := store.ListJobsByState(model.StateDead)
+			filter, err := dlqFilter(cmd)
+			if err != nil {
+				return err
+			}
+			jobs, err := listDead(store, filter)
 			if err != nil {
 				return fmt.Errorf("failed to list DLQ jobs: %w", err)
 			}
+			return printResult(cmd, output.Result{
+				Columns: dlqColumns,
+				Rows:    dlqRows(jobs),
+				Records: jobs,
+				Text: func(w io.Writer) error {
+					if len(jobs) == 0 {
+						fmt.Fprintln(w, "Dead Letter Queue is empty.")
+						return nil
+					}
+					fmt.Fprintln(w, "--- Jobs in DLQ ---")
+					return output.WriteTable(w, dlqColumns, dlqRows(jobs))
+				},
+			})
+		},
+	}
+	addDLQFilterFlags(listCmd)
 
-			if len(jobs) == 0 {
-				fmt.Println("Dead Letter Queue is empty.")
-				return nil
+	// --- 'dlq retry' Subcommand ---
+	retryCmd := &cobra.Command{
+		Use:   "retry [job-id]",
+		Short: "Retry one job from the DLQ, or every job matching --all or the filters",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			all, _ := cmd.Flags().GetBool("all")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			filtered := hasDLQFilter(cmd)
+
+			if len(args) == 1 {
+				if all || filtered {
+					return fmt.Errorf("give either a job ID or --all/filters, not both")
+				}
+				jobID := args[0]
+				if dryRun {
+					job, err := store.GetJob(jobID)
+					if err != nil {
+						return err
+					}
+					if job.State != model.StateDead {
+						return fmt.Errorf("%w: job %s is %s, not dead", storage.ErrInvalidState, jobID, job.State)
+					}
+					return printDryRun(cmd, "retry", []model.Job{*job})
+				}
+				if err := store.RetryDeadJob(jobID); err != nil {
+					return err
+				}
+				return printResult(cmd, output.Result{
+					Columns: []string{"id", "state"},
+					Rows:    [][]string{{jobID, model.StatePending}},
+					Records: map[string]string{"id": jobID, "state": model.StatePending},
+					Text: func(w io.Writer) error {
+						_, err := fmt.Fprintf(w, "Job %s moved from the DLQ to pending.\n", jobID)
+						return err
+					},
+				})
 			}
 
-			fmt.Println("--- Jobs in DLQ ---")
-			for i, job := range jobs {
-				fmt.Printf("\n--- Job %d ---\n", i+1)
-				fmt.Printf("ID: \t\t%s\n", job.ID)
-				fmt.Printf("Command: \t%s\n", job.Command)
-				fmt.Printf("Attempts: \t%d\n", job.Attempts)
-				fmt.Printf("Last Updated: \t%s\n", job.UpdatedAt.Format(time.RFC3339))
-				if job.Output != "" {
-					fmt.Printf("Last Output: \n%s\n", job.Output)
-				} else {
-					fmt.Println("Last Output: \t(empty)")
+			if !all && !filtered {
+				return fmt.Errorf("give a job ID, --all, or at least one filter")
+			}
+			filter, err := dlqFilter(cmd)
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				jobs, err := listDead(store, filter)
+				if err != nil {
+					return fmt.Errorf("failed to list DLQ jobs: %w", err)
 				}
+				return printDryRun(cmd, "retry", jobs)
 			}
-			return nil
-		},
+			ids, err := store.RetryDeadJobs(filter)
+			if err != nil {
+				return fmt.Errorf("failed to retry DLQ jobs: %w", err)
+			}
+			return printBulkResult(cmd, "retry", "moved from the DLQ to pending", ids)
+		}),
 	}
+	retryCmd.Flags().Bool("all", false, "Retry every dead job matching the filters")
+	retryCmd.Flags().Bool("dry-run", false, "Show the jobs that would be retried without changing them")
+	addDLQFilterFlags(retryCmd)
 
-	// --- 'dlq retry' Subcommand ---
-	retryCmd := &cobra.Command{
-		Use:   "retry <job-id>",
-		Short: "Retry a specific job from the DLQ",
-		Args:  cobra.ExactArgs(1),
+	// --- 'dlq purge' Subcommand ---
+	purgeCmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Delete dead jobs and their attempt history",
+		Args:  cobra.NoArgs,
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			all, _ := cmd.Flags().GetBool("all")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			olderThan, _ := cmd.Flags().GetString("older-than")
+			if !all && olderThan == "" && !hasDLQFilter(cmd) {
+				return fmt.Errorf("give --all, --older-than, or at least one filter")
+			}
+			filter, err := dlqFilter(cmd)
+			if err != nil {
+				return err
+			}
+			if olderThan != "" {
+				age, err := config.ParseDuration(olderThan)
+				if err != nil {
+					return fmt.Errorf("--older-than: %w", err)
+				}
+				cutoff := time.Now().Add(-age)
+				if filter.UpdatedBefore.IsZero() || cutoff.Before(filter.UpdatedBefore) {
+					filter.UpdatedBefore = cutoff
+				}
+			}
+
+			if dryRun {
+				jobs, err := listDead(store, filter)
+				if err != nil {
+					return fmt.Errorf("failed to list DLQ jobs: %w", err)
+				}
+				return printDryRun(cmd, "purge", jobs)
+			}
+			ids, err := store.PurgeJobs(filter)
+			if err != nil {
+				return fmt.Errorf("failed to purge DLQ jobs: %w", err)
+			}
+			return printBulkResult(cmd, "purge", "purged from the DLQ", ids)
+		}),
+	}
+	purgeCmd.Flags().Bool("all", false, "Purge every dead job matching the filters")
+	purgeCmd.Flags().String("older-than", "", "Only jobs that died at least this long ago (e.g. 72h, 30d)")
+	purgeCmd.Flags().Bool("dry-run", false, "Show the jobs that would be purged without deleting them")
+	addDLQFilterFlags(purgeCmd)
+
+	// --- 'dlq export' Subcommand ---
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write dead jobs as JSON lines, one job per line",
+		Args:  cobra.NoArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			jobID := args[0]
-			if err := store.RetryDeadJob(jobID); err != nil {
+			filter, err := dlqFilter(cmd)
+			if err != nil {
 				return err
 			}
-			log.Printf("Job %s moved from DLQ to 'pending' state.", jobID)
+			w := cmd.OutOrStdout()
+			var f *os.File
+			if path, _ := cmd.Flags().GetString("file"); path != "" && path != "-" {
+				if f, err = os.Create(path); err != nil {
+					return err
+				}
+				// Closes the file on the error paths; a successful export
+				// closes it below and checks the error.
+				defer f.Close()
+				w = f
+			}
+
+			enc := json.NewEncoder(w)
+			filter.Limit = exportPageSize
+			count := 0
+			for {
+				page, err := store.ListJobs(filter)
+				if err != nil {
+					return fmt.Errorf("failed to export DLQ jobs: %w", err)
+				}
+				for _, job := range page.Jobs {
+					if err := enc.Encode(job); err != nil {
+						return err
+					}
+					count++
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				filter.Cursor = page.NextCursor
+			}
+			if f != nil {
+				if err := f.Close(); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d job(s) to %s\n", count, f.Name())
+			}
 			return nil
 		},
 	}
+	exportCmd.Flags().StringP("file", "f", "", "Write to this file instead of stdout")
+	addDLQFilterFlags(exportCmd)
 
 	dlqCmd.AddCommand(listCmd)
 	dlqCmd.AddCommand(retryCmd)
+	dlqCmd.AddCommand(purgeCmd)
+	dlqCmd.AddCommand(exportCmd)
 	return dlqCmd
-}
\ No newline at end of file
+}
+
+// exportPageSize is how many jobs dlq export reads per query.
+const exportPageSize = 500
+
+// dlqFilterFlags are the flags addDLQFilterFlags defines.
+var dlqFilterFlags = []string{"queue", "command", "failed-after", "failed-before", "exit-code"}
+
+// addDLQFilterFlags adds the flags that select a subset of the DLQ.
+func addDLQFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("queue", "", "Only jobs in this queue")
+	cmd.Flags().String("command", "", "Only jobs whose command matches this glob pattern (e.g. 'curl *')")
+	cmd.Flags().String("failed-after", "", "Only jobs that died at or after this time (RFC3339, YYYY-MM-DD, or a duration ago like 24h)")
+	cmd.Flags().String("failed-before", "", "Only jobs that died before this time")
+	cmd.Flags().Int("exit-code", 0, "Only jobs whose last attempt exited with this code")
+}
+
+// hasDLQFilter reports whether any DLQ filter flag was given.
+func hasDLQFilter(cmd *cobra.Command) bool {
+	for _, name := range dlqFilterFlags {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// dlqFilter builds a dead-job filter from the flags added by
+// addDLQFilterFlags. A dead job's updated_at is when it was moved to the DLQ.
+func dlqFilter(cmd *cobra.Command) (storage.JobFilter, error) {
+	flags := cmd.Flags()
+	now := time.Now()
+
+	filter := storage.JobFilter{States: []string{model.StateDead}, SortBy: "updated_at"}
+	filter.Queue, _ = flags.GetString("queue")
+	filter.CommandGlob, _ = flags.GetString("command")
+	if flags.Changed("exit-code") {
+		exitCode, _ := flags.GetInt("exit-code")
+		filter.ExitCode = &exitCode
+	}
+	for _, bound := range []struct {
+		flag string
+		dest *time.Time
+	}{
+		{"failed-after", &filter.UpdatedAfter},
+		{"failed-before", &filter.UpdatedBefore},
+	} {
+		value, _ := flags.GetString(bound.flag)
+		t, err := parseTimeFlag(value, now)
+		if err != nil {
+			return filter, fmt.Errorf("--%s: %w", bound.flag, err)
+		}
+		*bound.dest = t
+	}
+	return filter, nil
+}
+
+// listDead returns every dead job matching filter.
+func listDead(store *storage.Store, filter storage.JobFilter) ([]model.Job, error) {
+	page, err := store.ListJobs(filter)
+	if err != nil {
+		return nil, err
+	}
+	if page.Jobs == nil {
+		return []model.Job{}, nil
+	}
+	return page.Jobs, nil
+}
+
+// bulkResult is the structured output of a bulk DLQ operation.
+type bulkResult struct {
+	Action string   `json:"action"`
+	DryRun bool     `json:"dry_run"`
+	Count  int      `json:"count"`
+	IDs    []string `json:"ids"`
+}
+
+// printDryRun lists the jobs a bulk action would affect.
+func printDryRun(cmd *cobra.Command, action string, jobs []model.Job) error {
+	ids := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		ids = append(ids, job.ID)
+	}
+	return printResult(cmd, output.Result{
+		Columns: dlqColumns,
+		Rows:    dlqRows(jobs),
+		Records: bulkResult{Action: action, DryRun: true, Count: len(ids), IDs: ids},
+		Text: func(w io.Writer) error {
+			fmt.Fprintf(w, "Dry run: would %s %d job(s).\n", action, len(jobs))
+			if len(jobs) == 0 {
+				return nil
+			}
+			return output.WriteTable(w, dlqColumns, dlqRows(jobs))
+		},
+	})
+}
+
+// printBulkResult reports the jobs a bulk action changed; message says
+// what happened to them, e.g. "purged from the DLQ".
+func printBulkResult(cmd *cobra.Command, action, message string, ids []string) error {
+	return printResult(cmd, output.Result{
+		Columns: []string{"id"},
+		Rows:    idRows(ids),
+		Records: bulkResult{Action: action, Count: len(ids), IDs: ids},
+		Text: func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "%d job(s) %s.\n", len(ids), message)
+			return err
+		},
+	})
+}
+
+// dlqColumns are the table and csv columns of the DLQ listing.
+var dlqColumns = []string{"id", "queue", "command", "attempts", "failure_reason", "exit_code", "updated_at", "last_output"}
+
+func dlqRows(jobs []model.Job) [][]string {
+	rows := make([][]string, 0, len(jobs))
+	for _, job := range jobs {
+		rows = append(rows, []string{
+			job.ID,
+			job.Queue,
+			job.Command,
+			strconv.Itoa(job.Attempts),
+			job.FailureReason,
+			strconv.Itoa(job.ExitCode),
+			job.UpdatedAt.Format(time.RFC3339),
+			lastLine(job.Output),
+		})
+	}
+	return rows
+}
+
+// lastLine returns the last non-empty line of a job's output.
+func lastLine(out string) string {
+	out = strings.TrimRight(out, "\n")
+	if i := strings.LastIndexByte(out, '\n'); i >= 0 {
+		return out[i+1:]
+	}
+	return out
+}
@@ -3,9 +3,14 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
 	"queueCtl/internal/config"
 	"queueCtl/internal/model"
 	"queueCtl/internal/database"
+	"queueCtl/internal/output"
+	"queueCtl/internal/tracing"
 	"time"
 	"github.com/spf13/cobra"
 )
@@ -16,32 +21,52 @@ func EnqueueCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
 		Use: "enqueue <job(json)>",
 		Short: "adds the job to the queue",
 		Args: cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error{
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error{
 			var job model.Job
 			if err := json.Unmarshal([]byte(args[0]), &job); err != nil {
 				return fmt.Errorf("invalid job JSON: %w", err)
 			}
-			
-			if job.ID == "" || job.Command == "" {
-				return fmt.Errorf("job 'id' or 'command' is empty")
+			// The trace context comes from --traceparent, then the job's
+			// own "traceparent", then the TRACEPARENT of a traced caller.
+			if traceParent, _ := cmd.Flags().GetString("traceparent"); traceParent != "" {
+				job.TraceParent = traceParent
+			} else if job.TraceParent == "" {
+				job.TraceParent = inheritedTraceParent()
 			}
-
-			now := time.Now()
-			job.State = "pending"
-			job.CreatedAt = now
-			job.UpdatedAt = now
-			job.NextRunAt = now
-
-			if job.MaxRetries == 0{
-				job.MaxRetries = cfg.MaxRetries
+			
+			if err := job.Prepare(time.Now(), cfg.MaxRetries); err != nil {
+				return err
 			}
 
 			if err:=store.CreateJob(&job); err!=nil{
 				return fmt.Errorf("failed to enqueue job: %v", err)
 			}
-			fmt.Println("Job enqueued.")
-			return  nil
-		},
+			return printResult(cmd, output.Result{
+				Columns: jobListColumns,
+				Rows:    jobRows([]model.Job{job}),
+				Records: job,
+				Text: func(w io.Writer) error {
+					_, err := fmt.Fprintln(w, "Job enqueued.")
+					return err
+				},
+			})
+		}),
 	}
+	EnqueueCmd.Flags().String("traceparent", "", "W3C trace context of the producer, e.g. 00-<trace-id>-<span-id>-01 (default $TRACEPARENT)")
 	return EnqueueCmd
+}
+
+// inheritedTraceParent returns $TRACEPARENT when it holds a valid trace
+// context. The variable may come from an unrelated process further up, so
+// an invalid value is ignored rather than failing the enqueue.
+func inheritedTraceParent() string {
+	value := os.Getenv(tracing.EnvTraceParent)
+	if value == "" {
+		return ""
+	}
+	if _, err := tracing.ParseTraceParent(value); err != nil {
+		slog.Debug("ignoring $"+tracing.EnvTraceParent, "err", err)
+		return ""
+	}
+	return value
 }
\ No newline at end of file
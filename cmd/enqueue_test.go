@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"queueCtl/internal/tracing"
+	"testing"
+)
+
+func TestInheritedTraceParent(t *testing.T) {
+	valid := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	for env, want := range map[string]string{
+		valid:                          valid,
+		"":                             "",
+		"not a trace context":          "",
+		"00-" + valid[3:35] + "-zz-01": "",
+	} {
+		t.Setenv(tracing.EnvTraceParent, env)
+		if got := inheritedTraceParent(); got != want {
+			t.Errorf("with $TRACEPARENT=%q: got %q, want %q", env, got, want)
+		}
+	}
+}
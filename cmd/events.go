@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"queueCtl/internal/database"
+	"queueCtl/internal/events"
+	"queueCtl/internal/model"
+	"queueCtl/internal/output"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func EventsCmd(store *storage.Store) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "events",
+		Short: "Show job lifecycle events, or follow them as they happen",
+		Long: `Show the job lifecycle events recorded by every queuectl process:
+enqueued, claimed, output, retry_scheduled, requeued, completed, dead,
+canceled and expired. Every event has an increasing ID; pass the last one
+seen as --after to resume. --follow keeps printing new events until
+interrupted. A worker pool started with --listen serves the same stream
+as server-sent events at /events.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			follow, _ := flags.GetBool("follow")
+			limit, _ := flags.GetInt("limit")
+			filter := storage.EventFilter{}
+			filter.JobID, _ = flags.GetString("job")
+			filter.Queue, _ = flags.GetString("queue")
+			filter.Types, _ = flags.GetStringSlice("type")
+			for _, t := range filter.Types {
+				if !slices.Contains(model.EventTypes, t) {
+					return fmt.Errorf("--type: unknown event type %q (use %s)", t, strings.Join(model.EventTypes, ", "))
+				}
+			}
+			if flags.Changed("after") {
+				filter.After, _ = flags.GetInt64("after")
+			} else {
+				// Without a cursor, start from the most recent events.
+				filter.Limit, filter.Newest = limit, true
+			}
+
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			if !follow {
+				if flags.Changed("after") {
+					filter.Limit = limit
+				}
+				list, err := store.ListEvents(filter)
+				if err != nil {
+					return fmt.Errorf("failed to list events: %w", err)
+				}
+				return printResult(cmd, output.Result{
+					Columns: eventColumns,
+					Rows:    eventRows(list),
+					Records: list,
+					Text: func(w io.Writer) error {
+						if len(list) == 0 {
+							fmt.Fprintln(w, "No events.")
+							return nil
+						}
+						return output.WriteTable(w, eventColumns, eventRows(list))
+					},
+				})
+			}
+
+			send, err := eventPrinter(os.Stdout, format)
+			if err != nil {
+				return err
+			}
+			// Print the recent backlog first, then follow from its end.
+			if !flags.Changed("after") {
+				backlog, err := store.ListEvents(filter)
+				if err != nil {
+					return fmt.Errorf("failed to list events: %w", err)
+				}
+				if len(backlog) == 0 {
+					if filter.After, err = store.LastEventID(); err != nil {
+						return err
+					}
+				} else {
+					if err := send(backlog); err != nil {
+						return err
+					}
+					filter.After = backlog[len(backlog)-1].ID
+				}
+			}
+			ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+			return events.Follow(ctx, store, filter, send, nil)
+		},
+	}
+	cmd.Flags().BoolP("follow", "f", false, "Keep printing new events as they are recorded")
+	cmd.Flags().Int64("after", 0, "Only events after this event ID (a cursor from an earlier run)")
+	cmd.Flags().Int("limit", 50, "How many events to show; without --after, the most recent ones")
+	cmd.Flags().String("job", "", "Only events of this job")
+	cmd.Flags().String("queue", "", "Only events of jobs in this queue")
+	cmd.Flags().StringSlice("type", nil, "Only these event types")
+	return cmd
+}
+
+var eventColumns = []string{"id", "at", "type", "job", "queue", "state", "attempt", "detail"}
+
+func eventRows(list []model.Event) [][]string {
+	rows := make([][]string, len(list))
+	for i, ev := range list {
+		rows[i] = []string{
+			strconv.FormatInt(ev.ID, 10),
+			ev.At.Format(time.RFC3339),
+			ev.Type,
+			ev.JobID,
+			ev.Queue,
+			ev.State,
+			strconv.Itoa(ev.Attempt),
+			eventDetail(&ev),
+		}
+	}
+	return rows
+}
+
+// eventDetail summarises what is specific to an event in one line.
+func eventDetail(ev *model.Event) string {
+	var parts []string
+	if ev.WorkerID != "" && ev.Type == model.EventClaimed {
+		parts = append(parts, "worker "+ev.WorkerID)
+	}
+	if ev.FailureReason != "" {
+		parts = append(parts, fmt.Sprintf("%s (exit %d)", ev.FailureReason, ev.ExitCode))
+	}
+	if ev.NextRunAt != nil {
+		parts = append(parts, "next run "+ev.NextRunAt.Format(time.RFC3339))
+	}
+	if ev.FollowUpID != "" {
+		parts = append(parts, "follow-up "+ev.FollowUpID+" already exists")
+	}
+	if ev.Output != "" {
+		out := strings.TrimSpace(ev.Output)
+		if i := strings.LastIndexByte(out, '\n'); i >= 0 {
+			out = "…" + out[i+1:]
+		}
+		parts = append(parts, "output: "+out)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// eventPrinter returns a function printing batches of followed events as
+// they arrive: a line per event in the table format, a JSON object per
+// line in json and jsonl.
+func eventPrinter(w io.Writer, format output.Format) (func([]model.Event) error, error) {
+	switch format {
+	case output.JSON, output.JSONL:
+		enc := json.NewEncoder(w)
+		return func(batch []model.Event) error {
+			for _, ev := range batch {
+				if err := enc.Encode(ev); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	case output.CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(eventColumns); err != nil {
+			return nil, err
+		}
+		cw.Flush()
+		return func(batch []model.Event) error {
+			cw.WriteAll(eventRows(batch))
+			return cw.Error()
+		}, nil
+	case output.Table:
+		return func(batch []model.Event) error {
+			for _, row := range eventRows(batch) {
+				if _, err := fmt.Fprintf(w, "%-6s %s  %-15s %s [%s] %s  %s\n",
+					row[0], row[1], row[2], row[3], row[4], row[5], row[7]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, nil
+	}
+	return nil, fmt.Errorf("--follow does not support the %s format", format)
+}
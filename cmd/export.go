@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/dump"
+	"queueCtl/internal/model"
+	"queueCtl/internal/notify"
+	"queueCtl/internal/output"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func ExportCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Dump every job, its attempts, batches, throttles and the configuration as versioned JSON lines",
+		Long: `Write the whole queue as a versioned JSON lines dump that 'queuectl import'
+reads back on any host or storage backend. Jobs are read page by page, so
+stop the workers first for an exact snapshot.
+
+Notifier secrets, webhook URL tokens and tracing headers are replaced by
+placeholders unless --include-secrets is given. 'queuectl import --config'
+fills them back in from this host's notifiers and headers of the same name.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			w := io.Writer(os.Stdout)
+			path, _ := cmd.Flags().GetString("file")
+			var f *os.File
+			if path != "" && path != "-" {
+				var err error
+				if f, err = os.Create(path); err != nil {
+					return err
+				}
+				// Closes the file on the error paths; a successful export
+				// closes it below and checks the error.
+				defer f.Close()
+				w = f
+			}
+
+			dw, err := dump.NewWriter(w, time.Now())
+			if err != nil {
+				return err
+			}
+			includeSecrets, _ := cmd.Flags().GetBool("include-secrets")
+			exported := cfg
+			if !includeSecrets {
+				exported = redactConfig(cfg)
+			}
+			if err := dw.WriteConfig(exported, !includeSecrets); err != nil {
+				return err
+			}
+			throttles, err := store.ListThrottles()
+			if err != nil {
+				return fmt.Errorf("failed to export throttles: %w", err)
+			}
+			for _, t := range throttles {
+				if err := dw.WriteThrottle(t); err != nil {
+					return err
+				}
+			}
+			batches, err := store.ListBatches()
+			if err != nil {
+				return fmt.Errorf("failed to export batches: %w", err)
+			}
+			for _, batch := range batches {
+				if err := dw.WriteBatch(batch); err != nil {
+					return err
+				}
+			}
+			filter := storage.JobFilter{Limit: exportPageSize}
+			for {
+				page, err := store.ListJobs(filter)
+				if err != nil {
+					return fmt.Errorf("failed to export jobs: %w", err)
+				}
+				ids := make([]string, len(page.Jobs))
+				for i, job := range page.Jobs {
+					ids[i] = job.ID
+				}
+				attempts, err := store.AttemptsFor(ids)
+				if err != nil {
+					return fmt.Errorf("failed to export attempts: %w", err)
+				}
+				for _, job := range page.Jobs {
+					if err := dw.WriteJob(model.JobRecord{Job: job, Attempts: attempts[job.ID]}); err != nil {
+						return err
+					}
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				filter.Cursor = page.NextCursor
+			}
+			n, err := dw.Close()
+			if err != nil {
+				return err
+			}
+			if f != nil {
+				if err := f.Close(); err != nil {
+					return err
+				}
+				// The dump went to the file, so stdout is free for the
+				// summary.
+				return printResult(cmd, output.Result{
+					Columns: []string{"file", "jobs"},
+					Rows:    [][]string{{path, strconv.Itoa(n)}},
+					Records: map[string]any{"file": path, "jobs": n},
+					Text: func(w io.Writer) error {
+						_, err := fmt.Fprintf(w, "Exported %d job(s) to %s\n", n, path)
+						return err
+					},
+				})
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringP("file", "f", "", "Write to this file instead of stdout")
+	cmd.Flags().Bool("include-secrets", false, "Export notifier secrets and tracing headers as they are")
+	return cmd
+}
+
+// redactConfig returns a copy of cfg with its notifiers redacted as in
+// 'config show' and its tracing header values replaced by "***".
+func redactConfig(cfg *config.Config) *config.Config {
+	out := *cfg
+	out.Notifiers = redactNotifiers(cfg.Notifiers)
+	if len(cfg.Tracing.Headers) > 0 {
+		out.Tracing.Headers = make(map[string]string, len(cfg.Tracing.Headers))
+		for k := range cfg.Tracing.Headers {
+			out.Tracing.Headers[k] = "***"
+		}
+	}
+	return &out
+}
+
+// restoreSecrets fills the placeholders of a configuration exported without
+// its secrets from current: a redacted notifier takes the secret and URL of
+// the notifier with the same name, if that one redacts to the same values,
+// and a redacted tracing header takes the value of the same header. It
+// fails if a placeholder has nothing to be filled from.
+func restoreSecrets(imported, current *config.Config) error {
+	notifiers := make([]config.Notifier, len(imported.Notifiers))
+	for i, n := range imported.Notifiers {
+		notifiers[i] = n
+		if n.Secret == "" && !strings.Contains(n.URL, "xxxxx") {
+			continue
+		}
+		j := slices.IndexFunc(current.Notifiers, func(c config.Notifier) bool { return c.Name == n.Name })
+		if j < 0 {
+			return fmt.Errorf("notifier %q was exported without its secrets and does not exist here; export with --include-secrets", n.Name)
+		}
+		local := current.Notifiers[j]
+		if shown := notify.Redact(local); shown.Secret != n.Secret || shown.URL != n.URL {
+			return fmt.Errorf("notifier %q was exported without its secrets and differs from the one here; export with --include-secrets", n.Name)
+		}
+		notifiers[i].Secret, notifiers[i].URL = local.Secret, local.URL
+	}
+	imported.Notifiers = notifiers
+
+	if len(imported.Tracing.Headers) > 0 {
+		headers := make(map[string]string, len(imported.Tracing.Headers))
+		for k, v := range imported.Tracing.Headers {
+			if v == "***" {
+				local, ok := current.Tracing.Headers[k]
+				if !ok {
+					return fmt.Errorf("tracing header %q was exported without its value and is not set here; export with --include-secrets", k)
+				}
+				v = local
+			}
+			headers[k] = v
+		}
+		imported.Tracing.Headers = headers
+	}
+	return nil
+}
+
+func ImportCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import <file|->",
+		Short: "Load a dump written by 'queuectl export'",
+		Long: `Load a dump written by 'queuectl export'. The whole dump is validated before
+anything is written, and all jobs are written in one transaction.
+
+--on-conflict decides what happens to a job or batch whose ID already
+exists, and to a throttle for a queue or key that already has one: fail
+(the default) aborts the import, skip keeps the existing one, overwrite
+replaces it, and a job's attempt history with it. Jobs that were processing in the
+source are imported as pending. --config also applies the dumped
+configuration, keeping this host's data-dir and filling in the secrets
+that export left out from this host's configuration.`,
+		Args: cobra.ExactArgs(1),
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			strategy, _ := cmd.Flags().GetString("on-conflict")
+			withConfig, _ := cmd.Flags().GetBool("config")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			if !slices.Contains(storage.ConflictStrategies, storage.ConflictStrategy(strategy)) {
+				return fmt.Errorf("--on-conflict must be fail, skip or overwrite, not %q", strategy)
+			}
+
+			r := io.Reader(os.Stdin)
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			d, err := dump.Read(r)
+			if err != nil {
+				return fmt.Errorf("invalid dump: %w", err)
+			}
+			if withConfig && d.Config == nil {
+				return fmt.Errorf("--config: the dump has no configuration")
+			}
+
+			if dryRun {
+				return printResult(cmd, output.Result{
+					Columns: []string{"version", "exported_at", "jobs", "batches", "throttles", "config"},
+					Rows: [][]string{{strconv.Itoa(d.Version), d.ExportedAt.Format(time.RFC3339), strconv.Itoa(len(d.Jobs)),
+						strconv.Itoa(len(d.Batches)), strconv.Itoa(len(d.Throttles)), strconv.FormatBool(d.Config != nil)}},
+					Records: map[string]any{"dry_run": true, "version": d.Version, "exported_at": d.ExportedAt, "jobs": len(d.Jobs),
+						"batches": len(d.Batches), "throttles": len(d.Throttles), "config": d.Config != nil},
+					Text: func(w io.Writer) error {
+						_, err := fmt.Fprintf(w, "Dump is valid: version %d, exported %s, %d job(s), %d batch(es), %d throttle(s). Nothing was imported.\n",
+							d.Version, d.ExportedAt.Format(time.RFC3339), len(d.Jobs), len(d.Batches), len(d.Throttles))
+						return err
+					},
+				})
+			}
+
+			var imported config.Config
+			if withConfig {
+				imported = *d.Config
+				imported.DataDir = cfg.DataDir
+				if d.ConfigRedacted {
+					if err := restoreSecrets(&imported, cfg); err != nil {
+						return fmt.Errorf("--config: %w", err)
+					}
+				}
+			}
+
+			result, err := store.ImportJobs(d.Throttles, d.Batches, d.Jobs, storage.ConflictStrategy(strategy))
+			if err != nil {
+				return fmt.Errorf("import failed, nothing was written: %w", err)
+			}
+			if withConfig {
+				*cfg = imported
+				if err := config.SaveConfig(cfg); err != nil {
+					return fmt.Errorf("jobs were imported but the configuration could not be saved: %w", err)
+				}
+			}
+
+			batches, throttles := result.Batches, result.Throttles
+			rows := [][]string{
+				{"created", strconv.Itoa(len(result.Created)), strconv.Itoa(len(batches.Created)), strconv.Itoa(len(throttles.Created))},
+				{"replaced", strconv.Itoa(len(result.Replaced)), strconv.Itoa(len(batches.Replaced)), strconv.Itoa(len(throttles.Replaced))},
+				{"skipped", strconv.Itoa(len(result.Skipped)), strconv.Itoa(len(batches.Skipped)), strconv.Itoa(len(throttles.Skipped))},
+				{"requeued", strconv.Itoa(len(result.Requeued)), "0", "0"},
+			}
+			return printResult(cmd, output.Result{
+				Columns: []string{"outcome", "jobs", "batches", "throttles"},
+				Rows:    rows,
+				Records: result,
+				Text: func(w io.Writer) error {
+					fmt.Fprintf(w, "Imported %d job(s): %d created, %d replaced, %d skipped.\n",
+						len(result.Created)+len(result.Replaced), len(result.Created), len(result.Replaced), len(result.Skipped))
+					if len(d.Batches) > 0 {
+						fmt.Fprintf(w, "Imported %d batch(es): %d created, %d replaced, %d skipped.\n",
+							len(batches.Created)+len(batches.Replaced), len(batches.Created), len(batches.Replaced), len(batches.Skipped))
+					}
+					if len(d.Throttles) > 0 {
+						fmt.Fprintf(w, "Imported %d throttle(s): %d created, %d replaced, %d skipped.\n",
+							len(throttles.Created)+len(throttles.Replaced), len(throttles.Created), len(throttles.Replaced), len(throttles.Skipped))
+					}
+					if len(result.Requeued) > 0 {
+						fmt.Fprintf(w, "%d job(s) that were processing in the source are now pending.\n", len(result.Requeued))
+					}
+					if withConfig {
+						fmt.Fprintln(w, "Configuration applied.")
+					}
+					return nil
+				},
+			})
+		}),
+	}
+	cmd.Flags().String("on-conflict", string(storage.ConflictFail), "What to do with jobs that already exist: fail, skip or overwrite")
+	cmd.Flags().Bool("config", false, "Also apply the dumped configuration (data-dir is kept)")
+	cmd.Flags().Bool("dry-run", false, "Validate the dump without importing it")
+	return cmd
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"queueCtl/internal/config"
+	"strings"
+	"testing"
+)
+
+func TestRedactAndRestoreSecrets(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.Notifiers = []config.Notifier{
+		{Name: "hook", States: []string{"dead"}, URL: "https://hooks.example.com/q?token=abc", Secret: "s3cret"},
+		{Name: "log", States: []string{"dead"}, Command: "logger"},
+	}
+	cfg.Tracing.Headers = map[string]string{"Authorization": "Bearer abc"}
+
+	redacted := redactConfig(cfg)
+	if n := redacted.Notifiers[0]; n.Secret != "***" || strings.Contains(n.URL, "abc") {
+		t.Errorf("redacted notifier = %+v", n)
+	}
+	if v := redacted.Tracing.Headers["Authorization"]; v != "***" {
+		t.Errorf("redacted header = %q", v)
+	}
+	if cfg.Notifiers[0].Secret != "s3cret" || cfg.Tracing.Headers["Authorization"] != "Bearer abc" {
+		t.Error("redactConfig changed the configuration it was given")
+	}
+
+	restored := *redacted
+	if err := restoreSecrets(&restored, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if n := restored.Notifiers[0]; n.Secret != "s3cret" || n.URL != cfg.Notifiers[0].URL {
+		t.Errorf("restored notifier = %+v", n)
+	}
+	if v := restored.Tracing.Headers["Authorization"]; v != "Bearer abc" {
+		t.Errorf("restored header = %q", v)
+	}
+
+	other := config.NewConfig()
+	if err := restoreSecrets(redactConfig(cfg), other); err == nil || !strings.Contains(err.Error(), `"hook"`) {
+		t.Errorf("restoring without a matching notifier: err = %v", err)
+	}
+	other.Notifiers = cfg.Notifiers[:1]
+	if err := restoreSecrets(redactConfig(cfg), other); err == nil || !strings.Contains(err.Error(), "Authorization") {
+		t.Errorf("restoring without the tracing header: err = %v", err)
+	}
+}
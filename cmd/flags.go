@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"fmt"
+	"queueCtl/internal/config"
+	"time"
+)
+
+// timeLayouts are the absolute formats accepted by time flags.
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+// parseTimeFlag reads a time flag value: an absolute time in one of
+// timeLayouts (local time unless it has an offset), or a duration such as
+// "90m" or "7d" meaning that long before now. Empty yields the zero time.
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if d, err := config.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC3339, YYYY-MM-DD or a duration like 24h or 7d", value)
+}
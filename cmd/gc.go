@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/janitor"
+	"queueCtl/internal/model"
+	"queueCtl/internal/output"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func GCCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Delete finished jobs past their retention period",
+		Long: `Delete completed, dead, canceled and expired jobs, with their attempt
+history, once they have been unchanged for longer than the configured
+retention (config set retention-<state> 7d). --retain overrides it for
+this run.
+When archive-dir is set, the jobs are archived there instead of deleted.`,
+		Args: cobra.NoArgs,
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			vacuum, _ := cmd.Flags().GetBool("vacuum")
+			overrides, _ := cmd.Flags().GetStringToString("retain")
+
+			retention, err := cfg.RetentionPeriods()
+			if err != nil {
+				return err
+			}
+			for state, value := range overrides {
+				if !model.IsTerminal(state) {
+					return fmt.Errorf("--retain: %q is not a final state", state)
+				}
+				d, err := config.ParseDuration(value)
+				if err != nil || d <= 0 {
+					return fmt.Errorf("--retain: invalid period %q for %s", value, state)
+				}
+				retention[state] = d
+			}
+
+			results, err := janitor.Collect(context.Background(), store, retention, cfg.ArchiveDir, time.Now(), dryRun)
+			if err != nil {
+				return fmt.Errorf("garbage collection failed: %w", err)
+			}
+			if !dryRun {
+				if vacuum {
+					if err := store.Vacuum(); err != nil {
+						return fmt.Errorf("vacuum failed: %w", err)
+					}
+				}
+				if err := store.Checkpoint(); err != nil {
+					return fmt.Errorf("WAL checkpoint failed: %w", err)
+				}
+			}
+
+			if results == nil {
+				results = []janitor.StateResult{}
+			}
+			columns := []string{"state", "cutoff", "removed"}
+			rows := make([][]string, 0, len(results))
+			for _, r := range results {
+				rows = append(rows, []string{r.State, r.Cutoff.Format(time.RFC3339), strconv.Itoa(r.Removed)})
+			}
+			return printResult(cmd, output.Result{
+				Columns: columns,
+				Rows:    rows,
+				Records: results,
+				Text: func(w io.Writer) error {
+					if len(results) == 0 {
+						fmt.Fprintln(w, "No retention configured; nothing to collect.")
+						return nil
+					}
+					if dryRun {
+						fmt.Fprintln(w, "Dry run: nothing was deleted.")
+						columns[2] = "would_remove"
+					}
+					return output.WriteTable(w, columns, rows)
+				},
+			})
+		}),
+	}
+	cmd.Flags().Bool("dry-run", false, "Count the jobs that would be removed without deleting them")
+	cmd.Flags().Bool("vacuum", false, "Rebuild the database file afterwards to return freed space")
+	cmd.Flags().StringToString("retain", nil, "Retention for this run, e.g. --retain completed=7d,dead=30d")
+	return cmd
+}
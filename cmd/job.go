@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"queueCtl/internal/output"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func JobCmd(store *storage.Store) *cobra.Command {
+	jobCmd := &cobra.Command{
+		Use:   "job",
+		Short: "Inspect individual jobs",
+	}
+
+	// --- 'job get' Subcommand ---
+	getCmd := &cobra.Command{
+		Use:   "get <job-id>",
+		Short: "Show every field of a job",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tail, _ := cmd.Flags().GetInt("tail")
+
+			job, err := store.GetJob(args[0])
+			if err != nil {
+				return err
+			}
+
+			rows, err := output.Flatten(job)
+			if err != nil {
+				return err
+			}
+			return printResult(cmd, output.Result{
+				Columns: []string{"field", "value"},
+				Rows:    rows,
+				Records: job,
+				Text: func(w io.Writer) error {
+					printJob(w, job, tail)
+					return nil
+				},
+			})
+		},
+	}
+	getCmd.Flags().Int("tail", 20, "Number of output lines to show in the table view")
+
+	jobCmd.AddCommand(getCmd)
+	return jobCmd
+}
+
+// printJob writes the human-readable view of a job.
+func printJob(w io.Writer, job *model.Job, tail int) {
+	fmt.Fprintf(w, "ID: \t\t%s\n", job.ID)
+	fmt.Fprintf(w, "Type: \t\t%s\n", job.Type)
+	if job.Command != "" {
+		fmt.Fprintf(w, "Command: \t%s\n", job.Command)
+	}
+	if len(job.Payload) > 0 {
+		fmt.Fprintf(w, "Payload: \t%s\n", job.Payload)
+	}
+	fmt.Fprintf(w, "State: \t\t%s\n", job.State)
+	fmt.Fprintf(w, "Attempts: \t%d/%d\n", job.Attempts, job.MaxRetries)
+	fmt.Fprintf(w, "Created: \t%s\n", job.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(w, "Updated: \t%s\n", job.UpdatedAt.Format(time.RFC3339))
+	if job.State == model.StatePending || job.State == model.StateFailed {
+		fmt.Fprintf(w, "Next Run: \t%s\n", job.NextRunAt.Format(time.RFC3339))
+	}
+	if job.Limits != nil {
+		limits, _ := json.Marshal(job.Limits)
+		fmt.Fprintf(w, "Limits: \t%s\n", limits)
+	}
+	if job.Attempts > 0 {
+		fmt.Fprintf(w, "Exit Code: \t%d\n", job.ExitCode)
+	}
+	if job.FailureReason != "" {
+		fmt.Fprintf(w, "Failure: \t%s\n", job.FailureReason)
+	}
+	if job.WorkerID != "" {
+		fmt.Fprintf(w, "Last Worker: \t%s\n", job.WorkerID)
+	}
+	if job.ExpiresAt != nil {
+		fmt.Fprintf(w, "Expires: \t%s\n", job.ExpiresAt.Format(time.RFC3339))
+	}
+	if job.Deadline != nil {
+		fmt.Fprintf(w, "Deadline: \t%s\n", job.Deadline.Format(time.RFC3339))
+	}
+	if job.LockKey != "" {
+		fmt.Fprintf(w, "Lock Key: \t%s\n", job.LockKey)
+	}
+	if job.TraceParent != "" {
+		fmt.Fprintf(w, "Trace Parent: \t%s\n", job.TraceParent)
+	}
+	if job.ParentID != "" {
+		fmt.Fprintf(w, "Parent: \t%s\n", job.ParentID)
+	}
+	if job.OnSuccess != nil {
+		fmt.Fprintf(w, "On Success: \t%s\n", templateSummary(job.OnSuccess))
+	}
+	if job.OnFailure != nil {
+		fmt.Fprintf(w, "On Failure: \t%s\n", templateSummary(job.OnFailure))
+	}
+
+	out := strings.TrimRight(job.Output, "\n")
+	if out == "" {
+		fmt.Fprintln(w, "Output: \t(empty)")
+		return
+	}
+	lines := strings.Split(out, "\n")
+	if tail > 0 && len(lines) > tail {
+		fmt.Fprintf(w, "Output (last %d of %d lines):\n", tail, len(lines))
+		lines = lines[len(lines)-tail:]
+	} else {
+		fmt.Fprintln(w, "Output:")
+	}
+	fmt.Fprintln(w, strings.Join(lines, "\n"))
+}
+
+// templateSummary describes a follow-up template in one line.
+func templateSummary(t *model.Job) string {
+	what := t.Command
+	if t.Type != "" && t.Type != model.TypeShell {
+		what = t.Type
+	}
+	if t.ID != "" {
+		return t.ID + ": " + what
+	}
+	return what
+}
@@ -1,12 +1,17 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
 	"queueCtl/internal/config"
 	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"queueCtl/internal/output"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -14,37 +19,156 @@ import (
 func ListCmd(store *storage.Store) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "list",
-		Short: "List jobs by state",
+		Short: "List jobs, filtered, sorted and paginated",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			// Get the --state flag
-			state, _ := cmd.Flags().GetString("state")
-			if state == "" {
-				return fmt.Errorf("the --state flag is required")
+			flags := cmd.Flags()
+			now := time.Now()
+
+			var filter storage.JobFilter
+			filter.States, _ = flags.GetStringSlice("state")
+			filter.Queue, _ = flags.GetString("queue")
+			filter.IDPrefix, _ = flags.GetString("id-prefix")
+			filter.CommandContains, _ = flags.GetString("command")
+			filter.MinAttempts, _ = flags.GetInt("min-attempts")
+			if flags.Changed("max-attempts") {
+				maxAttempts, _ := flags.GetInt("max-attempts")
+				filter.MaxAttempts = &maxAttempts
 			}
+			filter.SortBy, _ = flags.GetString("sort")
+			filter.Descending, _ = flags.GetBool("desc")
+			filter.Limit, _ = flags.GetInt("limit")
+			filter.Cursor, _ = flags.GetString("cursor")
 
-			jobs, err := store.ListJobsByState(state)
+			for _, bound := range []struct {
+				flag string
+				dest *time.Time
+			}{
+				{"created-after", &filter.CreatedAfter},
+				{"created-before", &filter.CreatedBefore},
+				{"updated-after", &filter.UpdatedAfter},
+				{"updated-before", &filter.UpdatedBefore},
+			} {
+				value, _ := flags.GetString(bound.flag)
+				t, err := parseTimeFlag(value, now)
+				if err != nil {
+					return fmt.Errorf("--%s: %w", bound.flag, err)
+				}
+				*bound.dest = t
+			}
+
+			page, err := store.ListJobs(filter)
 			if err != nil {
 				return fmt.Errorf("failed to list jobs: %w", err)
 			}
 
-			if len(jobs) == 0 {
-				fmt.Printf("No jobs found in state: %s\n", state)
-				return nil
+			jobs := page.Jobs
+			if jobs == nil {
+				jobs = []model.Job{}
 			}
-
-			fmt.Printf("--- Jobs in '%s' state ---\n", state)
-			fmt.Println("ID\t\tCommand\t\tAttempts")
-			for _, job := range jobs {
-				fmt.Printf("%s\t\t%s\t\t%d\n", job.ID, job.Command, job.Attempts)
+			err = printResult(cmd, output.Result{
+				Columns: jobListColumns,
+				Rows:    jobRows(jobs),
+				Records: jobs,
+				Text: func(w io.Writer) error {
+					if len(jobs) == 0 {
+						fmt.Fprintln(w, "No jobs found.")
+						return nil
+					}
+					if err := output.WriteTable(w, jobListColumns, jobRows(jobs)); err != nil {
+						return err
+					}
+					if page.NextCursor != "" {
+						fmt.Fprintf(w, "\nMore jobs available. Next page: --cursor %s\n", page.NextCursor)
+					}
+					return nil
+				},
+			})
+			if err != nil {
+				return err
+			}
+			// Keep stdout parseable; the cursor goes to stderr.
+			if format, _ := outputFormat(cmd); format != output.Table && page.NextCursor != "" {
+				fmt.Fprintf(os.Stderr, "next cursor: %s\n", page.NextCursor)
 			}
 			return nil
 		},
 	}
-	cmd.Flags().String("state", "", "Filter jobs by state (pending, processing, failed, dead, completed)")
-	cmd.MarkFlagRequired("state")
+	cmd.Flags().StringSlice("state", nil, "Filter by state, repeatable or comma-separated (pending, processing, failed, dead, completed, canceled, expired)")
+	cmd.Flags().String("queue", "", "Filter by queue")
+	cmd.Flags().String("id-prefix", "", "Only jobs whose ID starts with this prefix")
+	cmd.Flags().String("command", "", "Only jobs whose command contains this text")
+	cmd.Flags().String("created-after", "", "Only jobs created at or after this time (RFC3339, YYYY-MM-DD, or a duration ago like 24h)")
+	cmd.Flags().String("created-before", "", "Only jobs created before this time")
+	cmd.Flags().String("updated-after", "", "Only jobs updated at or after this time")
+	cmd.Flags().String("updated-before", "", "Only jobs updated before this time")
+	cmd.Flags().Int("min-attempts", 0, "Only jobs with at least this many attempts")
+	cmd.Flags().Int("max-attempts", 0, "Only jobs with at most this many attempts")
+	cmd.Flags().String("sort", "created_at", "Sort by created_at, updated_at, attempts or id")
+	cmd.Flags().Bool("desc", false, "Sort in descending order")
+	cmd.Flags().Int("limit", 0, "Maximum number of jobs per page (0 for all)")
+	cmd.Flags().String("cursor", "", "Continue from a previous page's cursor")
 	return cmd
 }
 
+// jobListColumns are the table and csv columns of job listings.
+var jobListColumns = []string{"id", "queue", "state", "type", "command", "attempts", "max_retries", "updated_at"}
+
+func jobRows(jobs []model.Job) [][]string {
+	rows := make([][]string, 0, len(jobs))
+	for _, job := range jobs {
+		rows = append(rows, []string{
+			job.ID,
+			job.Queue,
+			job.State,
+			job.Type,
+			job.Command,
+			strconv.Itoa(job.Attempts),
+			strconv.Itoa(job.MaxRetries),
+			job.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+	return rows
+}
+
+// stateCount is one line of the status report.
+type stateCount struct {
+	State string `json:"state"`
+	Count int    `json:"count"`
+}
+
+// statusReport is the machine-readable form of 'status'.
+type statusReport struct {
+	Jobs []stateCount `json:"jobs"`
+	// Workers is nil when no worker pool is running.
+	Workers   *WorkerStatus            `json:"workers"`
+	Throttles []storage.ThrottleStatus `json:"throttles"`
+	// HandlerJobs are the jobs waiting for a pool with a Go handler for
+	// their type; 'worker start' does not run them.
+	HandlerJobs []storage.TypeBacklog `json:"handler_jobs"`
+}
+
+// orderedStats lists job counts in lifecycle order, followed by any state
+// this version does not know about, alphabetically.
+func orderedStats(stats map[string]int) []stateCount {
+	counts := []stateCount{}
+	for _, state := range model.States {
+		if n, ok := stats[state]; ok {
+			counts = append(counts, stateCount{state, n})
+		}
+	}
+	var unknown []string
+	for state := range stats {
+		if !slices.Contains(model.States, state) {
+			unknown = append(unknown, state)
+		}
+	}
+	sort.Strings(unknown)
+	for _, state := range unknown {
+		counts = append(counts, stateCount{state, stats[state]})
+	}
+	return counts
+}
+
 func StatusCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "status",
@@ -55,35 +179,67 @@ func StatusCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
 				return fmt.Errorf("failed to get stats: %w", err)
 			}
 
-			fmt.Println("--- Job Queue Status ---")
-			if len(stats) == 0 {
-				fmt.Println("No jobs in the queue.")
-			}
+			report := statusReport{Jobs: orderedStats(stats)}
 
-			for state, count := range stats {
-				fmt.Printf("%s: \t%d\n", state, count)
+			report.Workers, err = readWorkerStatus(cfg)
+			if err != nil {
+				return err
 			}
-
-			fmt.Println("\n--- Worker Status ---")
-			statusPath := filepath.Join(cfg.DataDir, "worker.status")
-			data, err := os.ReadFile(statusPath)
-
+			report.Throttles, err = store.ThrottleStatuses()
 			if err != nil {
-				if os.IsNotExist(err) {
-					fmt.Println("Workers: \t0 (stopped)")
-					return nil
-				}
-				return fmt.Errorf("could not read worker status: %w", err)
+				return fmt.Errorf("failed to get throttles: %w", err)
+			}
+			report.HandlerJobs, err = store.HandlerBacklog()
+			if err != nil {
+				return fmt.Errorf("failed to get handler jobs: %w", err)
 			}
 
-			var status WorkerStatus
-			if err := json.Unmarshal(data, &status); err != nil {
-				return fmt.Errorf("could not parse worker status: %w", err)
+			rows := make([][]string, len(report.Jobs))
+			for i, c := range report.Jobs {
+				rows[i] = []string{c.State, strconv.Itoa(c.Count)}
 			}
 
-			fmt.Printf("Workers: \t%d started at: %v \nPID of worker pool: %d", status.Count, status.StartedAt, status.WorkerPoolPid)
+			return printResult(cmd, output.Result{
+				Columns: []string{"state", "count"},
+				Rows:    rows,
+				Records: report,
+				Text: func(w io.Writer) error {
+					fmt.Fprintln(w, "--- Job Queue Status ---")
+					if len(rows) == 0 {
+						fmt.Fprintln(w, "No jobs in the queue.")
+					} else if err := output.WriteTable(w, []string{"state", "count"}, rows); err != nil {
+						return err
+					}
 
-			return nil
+					if len(report.Throttles) > 0 {
+						fmt.Fprintln(w, "\n--- Throttles ---")
+						columns, rows := throttleStatusRows(report.Throttles)
+						if err := output.WriteTable(w, columns, rows); err != nil {
+							return err
+						}
+					}
+
+					if len(report.HandlerJobs) > 0 {
+						fmt.Fprintln(w, "\n--- Jobs Waiting for a Go Handler ---")
+						rows := make([][]string, len(report.HandlerJobs))
+						for i, b := range report.HandlerJobs {
+							rows[i] = []string{b.Type, strconv.Itoa(b.Waiting), b.Oldest.Format(time.RFC3339)}
+						}
+						if err := output.WriteTable(w, []string{"type", "waiting", "oldest"}, rows); err != nil {
+							return err
+						}
+						fmt.Fprintln(w, "Only programs that register a handler for the type (queuectl.Register) and run workers claim these jobs.")
+					}
+
+					fmt.Fprintln(w, "\n--- Worker Status ---")
+					if report.Workers == nil {
+						fmt.Fprintln(w, "Workers: \t0 (stopped)")
+						return nil
+					}
+					fmt.Fprintf(w, "Workers: \t%d started at: %v \nPID of worker pool: %d\n", report.Workers.Count, report.Workers.StartedAt, report.Workers.WorkerPoolPid)
+					return nil
+				},
+			})
 		},
 	}
 	return cmd
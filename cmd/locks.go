@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"queueCtl/internal/database"
+	"queueCtl/internal/output"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func LocksCmd(store *storage.Store) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "locks",
+		Short: "List the lock keys currently held and the jobs holding them",
+		Long: `List the lock keys currently held by a processing job. Jobs that share a
+lock_key never run at the same time, and start in the order they were
+enqueued; waiting counts the unfinished jobs queued behind the holder.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			holders, err := store.ListLocks()
+			if err != nil {
+				return fmt.Errorf("failed to list locks: %w", err)
+			}
+			columns := []string{"key", "job", "worker", "since", "waiting"}
+			rows := make([][]string, len(holders))
+			for i, h := range holders {
+				rows[i] = []string{h.Key, h.JobID, h.WorkerID, h.Since.Format(time.RFC3339), strconv.Itoa(h.Waiting)}
+			}
+			return printResult(cmd, output.Result{
+				Columns: columns,
+				Rows:    rows,
+				Records: holders,
+				Text: func(w io.Writer) error {
+					if len(rows) == 0 {
+						fmt.Fprintln(w, "No locks are held.")
+						return nil
+					}
+					return output.WriteTable(w, columns, rows)
+				},
+			})
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"queueCtl/internal/notify"
+	"queueCtl/internal/output"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func NotifyCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
+	notifyCmd := &cobra.Command{
+		Use:   "notify",
+		Short: "Manage webhooks and hook commands run on job state changes",
+		Long: `Notifiers are told when a job enters one of their states: failed (a retry
+is scheduled), completed, dead, canceled or expired. A webhook gets the
+event as a JSON POST, signed with HMAC-SHA256 in the X-Queuectl-Signature
+header when it has a secret; a hook command gets it on stdin. Deliveries
+are made in the background by the worker pool, retried with a backoff,
+and never hold up a worker; failures are logged. Restart the pool to pick
+up changes.`,
+	}
+
+	addCmd := &cobra.Command{
+		Use:   "add <name> (--url <url> | --command <cmd>) --on <states>",
+		Short: "Add or replace a notifier",
+		Args:  cobra.ExactArgs(1),
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			n := config.Notifier{Name: args[0]}
+			n.URL, _ = flags.GetString("url")
+			n.Secret, _ = flags.GetString("secret")
+			n.Command, _ = flags.GetString("command")
+			n.States, _ = flags.GetStringSlice("on")
+			n.Queues, _ = flags.GetStringSlice("queue")
+			n.Retries, _ = flags.GetInt("retries")
+			n.Timeout, _ = flags.GetString("timeout")
+			if n.Secret != "" && n.URL == "" {
+				return fmt.Errorf("--secret only applies to --url notifiers")
+			}
+			if err := n.Validate(); err != nil {
+				return err
+			}
+
+			i := slices.IndexFunc(cfg.Notifiers, func(existing config.Notifier) bool { return existing.Name == n.Name })
+			if i >= 0 {
+				cfg.Notifiers[i] = n
+			} else {
+				cfg.Notifiers = append(cfg.Notifiers, n)
+			}
+			if err := config.SaveConfig(cfg); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+			return printResult(cmd, output.Result{
+				Columns: notifierColumns,
+				Rows:    [][]string{notifierRow(&n)},
+				Records: notify.Redact(n),
+				Text: func(w io.Writer) error {
+					_, err := fmt.Fprintf(w, "Notifier %s saved. Restart the worker pool to apply it.\n", n.Name)
+					return err
+				},
+			})
+		}),
+	}
+	addCmd.Flags().String("url", "", "POST events to this URL")
+	addCmd.Flags().String("secret", "", "Sign webhook bodies with this HMAC-SHA256 key")
+	addCmd.Flags().String("command", "", "Run this shell command with the event on stdin")
+	addCmd.Flags().StringSlice("on", nil, "States that trigger the notifier: "+strings.Join(config.NotifyStates, ", "))
+	addCmd.Flags().StringSlice("queue", nil, "Only notify for jobs in these queues (default: all)")
+	addCmd.Flags().Int("retries", 3, "How many more times a failed delivery is tried")
+	addCmd.Flags().String("timeout", "", "Time limit for one delivery attempt (default 10s)")
+	addCmd.MarkFlagsMutuallyExclusive("url", "command")
+	addCmd.MarkFlagsOneRequired("url", "command")
+	addCmd.MarkFlagRequired("on")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List notifiers",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rows := make([][]string, len(cfg.Notifiers))
+			for i := range cfg.Notifiers {
+				rows[i] = notifierRow(&cfg.Notifiers[i])
+			}
+			records := redactNotifiers(cfg.Notifiers)
+			return printResult(cmd, output.Result{
+				Columns: notifierColumns,
+				Rows:    rows,
+				Records: records,
+				Text: func(w io.Writer) error {
+					if len(rows) == 0 {
+						fmt.Fprintln(w, "No notifiers.")
+						return nil
+					}
+					return output.WriteTable(w, notifierColumns, rows)
+				},
+			})
+		},
+	}
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a notifier",
+		Args:  cobra.ExactArgs(1),
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			i := slices.IndexFunc(cfg.Notifiers, func(n config.Notifier) bool { return n.Name == args[0] })
+			if i < 0 {
+				return fmt.Errorf("no notifier named %s", args[0])
+			}
+			cfg.Notifiers = slices.Delete(cfg.Notifiers, i, i+1)
+			if err := config.SaveConfig(cfg); err != nil {
+				return fmt.Errorf("failed to save config: %w", err)
+			}
+			return printResult(cmd, output.Result{
+				Columns: []string{"name"},
+				Rows:    [][]string{{args[0]}},
+				Records: map[string]string{"removed": args[0]},
+				Text: func(w io.Writer) error {
+					_, err := fmt.Fprintf(w, "Notifier %s removed.\n", args[0])
+					return err
+				},
+			})
+		}),
+	}
+
+	testCmd := &cobra.Command{
+		Use:   "test <name>",
+		Short: "Send a sample event to a notifier and wait for the outcome",
+		Long: `Send a sample event for a made-up job to a notifier, with its retries,
+and report whether it was delivered. Nothing is written to the queue.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			state, _ := cmd.Flags().GetString("state")
+			i := slices.IndexFunc(cfg.Notifiers, func(n config.Notifier) bool { return n.Name == args[0] })
+			if i < 0 {
+				return fmt.Errorf("no notifier named %s", args[0])
+			}
+			n := cfg.Notifiers[i]
+			if !slices.Contains(config.NotifyStates, state) {
+				return fmt.Errorf("--state must be one of %s", strings.Join(config.NotifyStates, ", "))
+			}
+
+			queue := model.DefaultQueue
+			if len(n.Queues) > 0 {
+				queue = n.Queues[0]
+			}
+			job := &model.Job{
+				ID:         "queuectl-test",
+				Queue:      queue,
+				Type:       model.TypeShell,
+				Command:    "true",
+				State:      state,
+				Attempts:   1,
+				MaxRetries: cfg.MaxRetries,
+			}
+			ev := notify.NewEvent(job, time.Now())
+			started := time.Now()
+			if err := notify.Deliver(context.Background(), &n, ev); err != nil {
+				return fmt.Errorf("delivery failed: %w", err)
+			}
+			return printResult(cmd, output.Result{
+				Columns: []string{"name", "event", "state", "took"},
+				Rows:    [][]string{{n.Name, ev.ID, ev.State, time.Since(started).Round(time.Millisecond).String()}},
+				Records: ev,
+				Text: func(w io.Writer) error {
+					_, err := fmt.Fprintf(w, "Delivered %s event %s to %s in %s.\n", ev.State, ev.ID, n.Name, time.Since(started).Round(time.Millisecond))
+					return err
+				},
+			})
+		},
+	}
+	testCmd.Flags().String("state", model.StateDead, "State of the sample event")
+
+	notifyCmd.AddCommand(addCmd)
+	notifyCmd.AddCommand(listCmd)
+	notifyCmd.AddCommand(removeCmd)
+	notifyCmd.AddCommand(testCmd)
+	return notifyCmd
+}
+
+var notifierColumns = []string{"name", "target", "states", "queues", "retries", "signed"}
+
+func notifierRow(n *config.Notifier) []string {
+	target := notify.RedactURL(n.URL)
+	if target == "" {
+		target = "$ " + n.Command
+	}
+	queues := strings.Join(n.Queues, ",")
+	if queues == "" {
+		queues = "*"
+	}
+	return []string{n.Name, target, strings.Join(n.States, ","), queues, strconv.Itoa(n.Retries), strconv.FormatBool(n.Secret != "")}
+}
+
+// redactNotifiers returns notifiers as shown to users, without their
+// secrets; see notify.Redact.
+func redactNotifiers(notifiers []config.Notifier) []config.Notifier {
+	out := make([]config.Notifier, len(notifiers))
+	for i, n := range notifiers {
+		out[i] = notify.Redact(n)
+	}
+	return out
+}
@@ -1,11 +1,13 @@
 package cmd
 
 import (
-
-	"log"
+	"errors"
+	"os"
 
 	"queueCtl/internal/config"
 	"queueCtl/internal/database"
+	"queueCtl/internal/logging"
+	"queueCtl/internal/output"
 
 	"github.com/spf13/cobra"
 )
@@ -13,6 +15,58 @@ import (
 var rootCmd = &cobra.Command{
 	Use: "queueCtl",
 	Short: "A cli-based job queue system",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := outputFormat(cmd); err != nil {
+			return err
+		}
+		return setupLogging(cmd)
+	},
+}
+
+// closeLog closes the log file opened with --log-file.
+var closeLog = func() error { return nil }
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringP("output", "o", "table", "Output format (table, json, jsonl, yaml, csv)")
+	flags.String("log-level", "info", "Log level (debug, info, warn, error)")
+	flags.String("log-format", "text", "Log format (text, json)")
+	flags.String("log-file", "", "Write logs to this file instead of standard error")
+	flags.Int("log-max-size", 100, "Rotate the log file once it reaches this many megabytes (0 disables rotation)")
+	flags.Int("log-max-backups", 5, "How many rotated log files to keep")
+}
+
+// setupLogging installs the logger selected with the global --log-* flags.
+func setupLogging(cmd *cobra.Command) error {
+	flags := cmd.Flags()
+	var opts logging.Options
+	opts.Level, _ = flags.GetString("log-level")
+	opts.Format, _ = flags.GetString("log-format")
+	opts.File, _ = flags.GetString("log-file")
+	opts.MaxSizeMB, _ = flags.GetInt("log-max-size")
+	opts.MaxBackups, _ = flags.GetInt("log-max-backups")
+	closer, err := logging.Setup(opts)
+	if err != nil {
+		return err
+	}
+	closeLog = closer
+	return nil
+}
+
+// outputFormat returns the format selected with the global --output flag.
+func outputFormat(cmd *cobra.Command) (output.Format, error) {
+	value, _ := cmd.Flags().GetString("output")
+	return output.ParseFormat(value)
+}
+
+// printResult writes a command's result in the format selected with
+// --output.
+func printResult(cmd *cobra.Command, r output.Result) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	return output.Write(os.Stdout, format, r)
 }
 
 func Execute(store *storage.Store, cfg *config.Config) {
@@ -22,9 +76,38 @@ func Execute(store *storage.Store, cfg *config.Config) {
 	rootCmd.AddCommand(StatusCmd(store,cfg))
 	rootCmd.AddCommand(WorkerCmd(store, cfg))
 	rootCmd.AddCommand(DlqCmd(store))
-	rootCmd.AddCommand(ConfigCmd(cfg))
+	rootCmd.AddCommand(JobCmd(store))
+	rootCmd.AddCommand(TopCmd(store, cfg))
+	rootCmd.AddCommand(GCCmd(store, cfg))
+	rootCmd.AddCommand(ArchiveCmd(store, cfg))
+	rootCmd.AddCommand(DbCmd(store, cfg))
+	rootCmd.AddCommand(ExportCmd(store, cfg))
+	rootCmd.AddCommand(ImportCmd(store, cfg))
+	rootCmd.AddCommand(BatchCmd(store, cfg))
+	rootCmd.AddCommand(ThrottleCmd(store))
+	rootCmd.AddCommand(LocksCmd(store))
+	rootCmd.AddCommand(NotifyCmd(store, cfg))
+	rootCmd.AddCommand(EventsCmd(store))
+	rootCmd.AddCommand(AuditCmd(store))
+	rootCmd.AddCommand(ConfigCmd(store, cfg))
 
-    if err := rootCmd.Execute(); err != nil {
-		log.Fatal(err)
+	err := rootCmd.Execute()
+	closeLog()
+	if err != nil {
+		var exit exitError
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
+		}
+		os.Exit(1)
     }
-}
\ No newline at end of file
+}
+
+// exitError makes the process exit with code instead of 1, for commands
+// whose exit status is part of their interface.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string { return e.err.Error() }
+func (e exitError) Unwrap() error { return e.err }
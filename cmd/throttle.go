@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"queueCtl/internal/output"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func ThrottleCmd(store *storage.Store) *cobra.Command {
+	throttleCmd := &cobra.Command{
+		Use:   "throttle",
+		Short: "Limit how fast and how many jobs of a queue or concurrency key run",
+		Long: `Throttles limit job starts for a whole queue (--queue) or for every job
+with a given concurrency_key (--key), across all worker pools that share
+the database. --max-concurrency caps the jobs processing at once, and
+--rate is a token bucket: jobs start at most that often on average, with
+up to --burst starts at once. Jobs held back by a throttle stay pending
+and show as throttled in 'status'.`,
+	}
+
+	setCmd := &cobra.Command{
+		Use:   "set (--queue <name> | --key <key>)",
+		Short: "Create or replace a throttle",
+		Args:  cobra.NoArgs,
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			scope, key, err := throttleTarget(cmd)
+			if err != nil {
+				return err
+			}
+			flags := cmd.Flags()
+			rateValue, _ := flags.GetString("rate")
+			burst, _ := flags.GetInt("burst")
+			maxConcurrency, _ := flags.GetInt("max-concurrency")
+
+			rate, err := parseRate(rateValue)
+			if err != nil {
+				return err
+			}
+			if maxConcurrency < 0 || burst < 0 {
+				return errors.New("--max-concurrency and --burst cannot be negative")
+			}
+			if rate == 0 && maxConcurrency == 0 {
+				return errors.New("set --rate, --max-concurrency or both")
+			}
+			if rate > 0 && burst == 0 {
+				burst = max(1, int(math.Ceil(rate)))
+			}
+
+			t := &model.Throttle{Scope: scope, Key: key, MaxConcurrency: maxConcurrency, Rate: rate, Burst: burst}
+			if err := store.SetThrottle(t); err != nil {
+				return fmt.Errorf("failed to set throttle: %w", err)
+			}
+			return printResult(cmd, output.Result{
+				Columns: throttleColumns,
+				Rows:    [][]string{throttleRow(t)},
+				Records: t,
+				Text: func(w io.Writer) error {
+					_, err := fmt.Fprintf(w, "Throttle set for %s %s.\n", scope, key)
+					return err
+				},
+			})
+		}),
+	}
+	addThrottleTargetFlags(setCmd)
+	setCmd.Flags().String("rate", "", "Job starts allowed, e.g. 5/s, 100/m, 1000/h (a bare number is per second)")
+	setCmd.Flags().Int("burst", 0, "Starts allowed at once before the rate applies (default: one second's worth, at least 1)")
+	setCmd.Flags().Int("max-concurrency", 0, "Jobs allowed to process at the same time (0: no cap)")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List throttles and their current load",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			statuses, err := store.ThrottleStatuses()
+			if err != nil {
+				return fmt.Errorf("failed to list throttles: %w", err)
+			}
+			columns, rows := throttleStatusRows(statuses)
+			return printResult(cmd, output.Result{
+				Columns: columns,
+				Rows:    rows,
+				Records: statuses,
+				Text: func(w io.Writer) error {
+					if len(rows) == 0 {
+						fmt.Fprintln(w, "No throttles.")
+						return nil
+					}
+					return output.WriteTable(w, columns, rows)
+				},
+			})
+		},
+	}
+
+	removeCmd := &cobra.Command{
+		Use:   "remove (--queue <name> | --key <key>)",
+		Short: "Remove a throttle",
+		Args:  cobra.NoArgs,
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
+			scope, key, err := throttleTarget(cmd)
+			if err != nil {
+				return err
+			}
+			if err := store.DeleteThrottle(scope, key); err != nil {
+				return err
+			}
+			return printResult(cmd, output.Result{
+				Columns: []string{"scope", "key"},
+				Rows:    [][]string{{scope, key}},
+				Records: map[string]string{"scope": scope, "key": key},
+				Text: func(w io.Writer) error {
+					_, err := fmt.Fprintf(w, "Throttle removed for %s %s.\n", scope, key)
+					return err
+				},
+			})
+		}),
+	}
+	addThrottleTargetFlags(removeCmd)
+
+	throttleCmd.AddCommand(setCmd)
+	throttleCmd.AddCommand(listCmd)
+	throttleCmd.AddCommand(removeCmd)
+	return throttleCmd
+}
+
+func addThrottleTargetFlags(cmd *cobra.Command) {
+	cmd.Flags().String("queue", "", "Throttle every job in this queue")
+	cmd.Flags().String("key", "", "Throttle every job with this concurrency_key")
+	cmd.MarkFlagsMutuallyExclusive("queue", "key")
+	cmd.MarkFlagsOneRequired("queue", "key")
+}
+
+// throttleTarget returns the scope and key chosen with --queue or --key.
+func throttleTarget(cmd *cobra.Command) (string, string, error) {
+	queue, _ := cmd.Flags().GetString("queue")
+	key, _ := cmd.Flags().GetString("key")
+	switch {
+	case queue != "":
+		return model.ScopeQueue, queue, nil
+	case key != "":
+		return model.ScopeKey, key, nil
+	}
+	return "", "", errors.New("--queue or --key cannot be empty")
+}
+
+// rateUnits are the per-period suffixes accepted by --rate, in seconds.
+var rateUnits = map[string]float64{"s": 1, "m": 60, "h": 3600}
+
+// parseRate reads a rate such as "5/s", "100/m" or "2.5" into starts per
+// second. Empty yields 0, no rate limit.
+func parseRate(value string) (float64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	count, unit, found := strings.Cut(value, "/")
+	period := 1.0
+	if found {
+		var ok bool
+		if period, ok = rateUnits[unit]; !ok {
+			return 0, fmt.Errorf("invalid rate %q: the period must be s, m or h", value)
+		}
+	}
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 || math.IsInf(n, 0) {
+		return 0, fmt.Errorf("invalid rate %q: use a positive number such as 5/s or 100/m", value)
+	}
+	return n / period, nil
+}
+
+var throttleColumns = []string{"scope", "key", "max_concurrency", "rate", "burst"}
+
+func throttleRow(t *model.Throttle) []string {
+	return []string{t.Scope, t.Key, limitString(t.MaxConcurrency), rateString(t.Rate), strconv.Itoa(t.Burst)}
+}
+
+// throttleStatusRows renders throttles with their load, as 'throttle list'
+// and 'status' show them.
+func throttleStatusRows(statuses []storage.ThrottleStatus) ([]string, [][]string) {
+	columns := append(append([]string{}, throttleColumns...), "running", "waiting", "throttled")
+	rows := make([][]string, len(statuses))
+	for i, st := range statuses {
+		throttled := "no"
+		if st.Blocked != "" && st.Waiting > 0 {
+			throttled = "yes (" + st.Blocked + ")"
+		}
+		rows[i] = append(throttleRow(&st.Throttle), strconv.Itoa(st.Running), strconv.Itoa(st.Waiting), throttled)
+	}
+	return columns, rows
+}
+
+func limitString(n int) string {
+	if n == 0 {
+		return "-"
+	}
+	return strconv.Itoa(n)
+}
+
+func rateString(rate float64) string {
+	switch {
+	case rate == 0:
+		return "-"
+	case rate >= 1:
+		return strconv.FormatFloat(rate, 'g', 4, 64) + "/s"
+	case rate*60 >= 1:
+		return strconv.FormatFloat(rate*60, 'g', 4, 64) + "/m"
+	}
+	return strconv.FormatFloat(rate*3600, 'g', 4, 64) + "/h"
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/notify"
+	"queueCtl/internal/tui"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TopCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Live full-screen dashboard of the queue",
+		Long: `Shows job counts per state, throughput, running jobs with their worker and
+elapsed time, recent failures and the DLQ, refreshed continuously.
+
+Keys: up/down or j/k select a job, enter or i inspect it, l shows its output,
+r retries a dead job, c cancels it, esc goes back and q quits.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			interval, _ := cmd.Flags().GetDuration("interval")
+			if interval <= 0 {
+				interval = time.Second
+			}
+			notifier := notify.New(cfg.Notifiers)
+			defer notifier.Close(notifyGracePeriod)
+			return tui.New(store, notifier, interval).Run()
+		},
+	}
+	cmd.Flags().Duration("interval", time.Second, "Refresh interval")
+	return cmd
+}
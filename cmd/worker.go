@@ -3,14 +3,25 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"queueCtl/internal/config"
+	"queueCtl/internal/daemon"
 	"queueCtl/internal/database"
+	"queueCtl/internal/events"
+	"queueCtl/internal/health"
+	"queueCtl/internal/janitor"
+	"queueCtl/internal/notify"
+	"queueCtl/internal/output"
+	"queueCtl/internal/tracing"
 	"queueCtl/internal/worker"
 	"runtime"
 	"strconv"
@@ -21,10 +32,68 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// notifyGracePeriod is how long a stopping pool waits for notifications
+// still being delivered.
+const notifyGracePeriod = 10 * time.Second
+
+// traceFlushPeriod is how long a stopping pool waits for spans still being
+// exported.
+const traceFlushPeriod = 5 * time.Second
+
 type WorkerStatus struct {
 	WorkerPoolPid int       `json:"pid"`
 	Count         int       `json:"count"`
 	StartedAt     time.Time `json:"started_at"`
+	// Listen is the address the pool serves HTTP on, if any.
+	Listen string `json:"listen,omitempty"`
+}
+
+// serveHTTP serves handler on ln until ctx is canceled. Requests run with
+// contexts derived from ctx, so open event streams end with the pool.
+func serveHTTP(ctx context.Context, ln net.Listener, handler http.Handler) {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	if err := server.Serve(ln); err != nil && err != http.ErrServerClosed {
+		slog.Error("HTTP server stopped", "err", err)
+	}
+}
+
+// readWorkerStatus returns the running worker pool's status file, or nil
+// when no pool is running. A status file left behind by a pool that has
+// died is removed.
+func readWorkerStatus(cfg *config.Config) (*WorkerStatus, error) {
+	statusPath := filepath.Join(cfg.DataDir, "worker.status")
+	data, err := os.ReadFile(statusPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read worker status: %w", err)
+	}
+	var status WorkerStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("could not parse worker status: %w", err)
+	}
+	if !daemon.ProcessAlive(status.WorkerPoolPid) {
+		slog.Warn("removing the status file of a worker pool that is no longer running", "pid", status.WorkerPoolPid)
+		os.Remove(statusPath)
+		return nil, nil
+	}
+	return &status, nil
+}
+
+// pidFilePath is the worker pool's PID lock file.
+func pidFilePath(cfg *config.Config) string {
+	return filepath.Join(cfg.DataDir, "worker.pid")
 }
 
 func WorkerCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
@@ -36,21 +105,73 @@ func WorkerCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
 	startCmd := &cobra.Command{
 		Use:   "start",
 		Short: "Start one or more worker processes",
+		Long: `Start a pool of workers, in the foreground unless --daemon is given. Only
+one pool runs per data directory: it holds worker.pid there for as long as
+it runs.
+
+SIGINT and SIGTERM shut the pool down gracefully. SIGHUP reloads the
+configuration file: max_retries, backoff_base, the default limits and
+cgroup_parent apply to jobs claimed afterwards, while the other settings
+need a restart. Under systemd (see 'worker install-unit') the pool reports
+readiness, reloads and shutdown with sd_notify, and pings the watchdog
+only while its health checks pass.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			count, _ := cmd.Flags().GetInt("count")
+			listen, _ := cmd.Flags().GetString("listen")
+			if background, _ := cmd.Flags().GetBool("daemon"); background && !daemon.IsChild() {
+				return startDaemon(cmd, cfg)
+			}
+
+			pidFile, err := daemon.AcquirePIDFile(pidFilePath(cfg))
+			if err != nil {
+				if errors.Is(err, daemon.ErrRunning) {
+					return fmt.Errorf("%w; stop it with 'worker stop' first", err)
+				}
+				return err
+			}
+			defer pidFile.Release()
 
-			log.Printf("Starting %d worker(s)...", count)
-			log.Println("Use 'worker stop' command in different terminal to shutdown the workers.")
+			// Fail before starting anything if the address is taken.
+			var ln net.Listener
+			if listen != "" {
+				if ln, err = net.Listen("tcp", listen); err != nil {
+					return fmt.Errorf("--listen: %w", err)
+				}
+			}
+
+			tracer, err := tracing.New(tracing.Options{
+				Exporter:    cfg.Tracing.Exporter,
+				Endpoint:    cfg.Tracing.Endpoint,
+				Headers:     cfg.Tracing.Headers,
+				File:        cfg.Tracing.File,
+				ServiceName: cfg.Tracing.ServiceName,
+			})
+			if err != nil {
+				return err
+			}
+			defer func() {
+				flushCtx, cancel := context.WithTimeout(context.Background(), traceFlushPeriod)
+				defer cancel()
+				if err := tracer.Shutdown(flushCtx); err != nil {
+					slog.Warn("could not flush spans", "err", err)
+				}
+			}()
+
+			slog.Info("starting workers", "count", count, "pid", os.Getpid())
+			slog.Info("use 'worker stop' in another terminal to shut the workers down")
 			status := WorkerStatus{
 				WorkerPoolPid: os.Getpid(), // Get our own Process ID
 				Count:         count,
 				StartedAt:     time.Now(),
 			}
+			if ln != nil {
+				status.Listen = ln.Addr().String()
+			}
 			statusPath := filepath.Join(cfg.DataDir, "worker.status")
 
 			data, err := json.Marshal(status)
 			if err != nil {
-				log.Printf("Warning: could not create status file: %v", err)
+				slog.Warn("could not create status file", "err", err)
 			} else {
 
 				os.WriteFile(statusPath, data, 0644)
@@ -66,30 +187,88 @@ func WorkerCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
 			// A WaitGroup blocks until all workers have finished.
 			var wg sync.WaitGroup
 
-			// Start the workers
-			for i := 1; i <= count; i++ {
-				wg.Add(1) // Increment the WaitGroup counter
-				w := worker.New(i, store, cfg)
+			// Notifications are delivered in the background; whatever is
+			// still pending gets a short grace period at shutdown.
+			notifier := notify.New(cfg.Notifiers)
+			defer notifier.Close(notifyGracePeriod)
+
+			monitor := health.NewMonitor(store)
 
-				// Run the worker in a new goroutine
-				// Pass 'ctx' so the worker knows when to shut down.
-				go w.Run(ctx, &wg)
+			// Workers read the configuration through live, which SIGHUP
+			// replaces.
+			live := config.NewLive(cfg)
+
+			// Start the workers, and the loop settling expired jobs
+			pool := &worker.Pool{
+				Count:    count,
+				Store:    store,
+				Config:   live,
+				Notifier: notifier,
+				Tracer:   tracer,
+				Health:   monitor,
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pool.Run(ctx)
+			}()
+
+			// The janitor applies the retention settings in the background,
+			// also reading them through live, and stops with the workers.
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				janitor.New(store, live).Run(ctx)
+			}()
+
+			if ln != nil {
+				mux := http.NewServeMux()
+				mux.Handle("/events", events.Handler(store))
+				healthHandler := health.Handler(monitor)
+				mux.Handle("/healthz", healthHandler)
+				mux.Handle("/readyz", healthHandler)
+				slog.Info("serving HTTP", "addr", ln.Addr().String())
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					serveHTTP(ctx, ln, mux)
+				}()
 			}
 
 			// Listen for shutdown signals (Ctrl+C)
-			// This goroutine waits for a signal and calls 'cancel()'.
+			// This goroutine waits for a signal and calls 'cancel()';
+			// SIGHUP reloads the configuration instead.
 			go func() {
 				sigCh := make(chan os.Signal, 1)
-				signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-				sig := <-sigCh
-				log.Printf("Received signal: %v. Shutting down...", sig)
-				cancel() // Cancel the context
+				signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+				for sig := range sigCh {
+					if sig == syscall.SIGHUP {
+						reloadConfig(live)
+						continue
+					}
+					slog.Info("received signal, shutting down", "signal", sig.String())
+					sdNotify(daemon.NotifyStopping)
+					monitor.Stopping()
+					cancel() // Cancel the context
+					return
+				}
 			}()
 
+			// Tell systemd, when it started us, that the pool is up, and
+			// keep its watchdog fed for as long as the pool is healthy.
+			sdNotify(fmt.Sprintf("%s\nMAINPID=%d\nSTATUS=%d workers running", daemon.NotifyReady, os.Getpid(), count))
+			if interval := daemon.WatchdogInterval(); interval > 0 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					feedWatchdog(ctx, interval/2, monitor)
+				}()
+			}
+
 			// Wait for all workers to exit
 			wg.Wait()
 
-			log.Println("All workers have shut down. Exiting.")
+			slog.Info("all workers have shut down")
 			return nil
 		},
 	}
@@ -97,54 +276,160 @@ func WorkerCmd(store *storage.Store, cfg *config.Config) *cobra.Command {
 	stopCmd := &cobra.Command{
 		Use:   "stop",
 		Short: "Stop running worker processes gracefully",
-		RunE: func(cmd *cobra.Command, args []string) error {
+		RunE: audited(store, func(cmd *cobra.Command, args []string) error {
 			statusPath := filepath.Join(cfg.DataDir, "worker.status")
 
-			data, err := os.ReadFile(statusPath)
+			status, err := readWorkerStatus(cfg)
 			if err != nil {
-				if os.IsNotExist(err) {
-					log.Println("Workers are not running (no status file found).")
-					return nil
-				}
-				return fmt.Errorf("could not read worker status: %w", err)
+				return err
 			}
-			var status WorkerStatus
-			if err := json.Unmarshal(data, &status); err != nil {
-				return fmt.Errorf("could not parse worker status: %w", err)
+			if status == nil {
+				return printStopResult(cmd, stopResult{})
 			}
-			fmt.Println("Worker pool PID: ",status.WorkerPoolPid)
 			if runtime.GOOS == "windows" {
 				// This is an alternative to taskkill
 				cmd := exec.Command("powershell", "-Command", "Stop-Process", "-Id", strconv.Itoa(status.WorkerPoolPid))
 				if err := cmd.Run(); err != nil {
-					log.Printf("Failed to stop process with taskkill: %v. Cleaning up status file.", err)
+					slog.Error("failed to stop the worker pool, cleaning up status file", "pid", status.WorkerPoolPid, "err", err)
 					os.Remove(statusPath)
 					return err
 				}
 			} else {
 				process, err := os.FindProcess(status.WorkerPoolPid)
 				if err != nil {
-					log.Printf("Could not find process with PID %d: %v", status.WorkerPoolPid, err)
+					slog.Error("could not find the worker pool process", "pid", status.WorkerPoolPid, "err", err)
 					os.Remove(statusPath)
 					return nil
 				}
 				
 				if err := process.Signal(syscall.SIGINT); err != nil {
-					log.Printf("Failed to send signal: %v. Cleaning up status file.", err)
+					slog.Error("failed to send signal, cleaning up status file", "pid", status.WorkerPoolPid, "err", err)
 					os.Remove(statusPath)
 					return err
 				}
 			}
 
-			log.Println("Signal sent. Workers should shut down gracefully.")
-			return nil
-		},
+			return printStopResult(cmd, stopResult{Running: true, PID: status.WorkerPoolPid, SignalSent: true})
+		}),
 	}
 	workerCmd.AddCommand(stopCmd)
 
+	workerCmd.AddCommand(workerHealthCmd(cfg))
+	workerCmd.AddCommand(installUnitCmd(store, cfg))
+
 	startCmd.Flags().Int("count", 1, "Number of workers to start")
+	startCmd.Flags().Bool("daemon", false, "Run in the background, logging to --log-file (default <data_dir>/worker.log)")
+	startCmd.Flags().String("listen", "", "Serve HTTP on this address, e.g. 127.0.0.1:8080: GET /events streams job events, /healthz and /readyz report health")
 	workerCmd.AddCommand(startCmd)
 
 	return workerCmd
 }
 
+// stopResult is the outcome of 'worker stop'.
+type stopResult struct {
+	Running    bool `json:"running"`
+	PID        int  `json:"pid,omitempty"`
+	SignalSent bool `json:"signal_sent"`
+}
+
+func printStopResult(cmd *cobra.Command, r stopResult) error {
+	return printResult(cmd, output.Result{
+		Columns: []string{"running", "pid", "signal_sent"},
+		Rows:    [][]string{{strconv.FormatBool(r.Running), strconv.Itoa(r.PID), strconv.FormatBool(r.SignalSent)}},
+		Records: r,
+		Text: func(w io.Writer) error {
+			if !r.Running {
+				_, err := fmt.Fprintln(w, "Workers are not running (no status file found).")
+				return err
+			}
+			fmt.Fprintln(w, "Worker pool PID: ", r.PID)
+			_, err := fmt.Fprintln(w, "Signal sent. Workers should shut down gracefully.")
+			return err
+		},
+	})
+}
+
+// Exit codes of 'worker health'.
+const (
+	healthExitUnhealthy   = 1
+	healthExitUnreachable = 2
+)
+
+func workerHealthCmd(cfg *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "health",
+		Short: "Probe the running worker pool's health endpoint",
+		Long: `Ask the running worker pool for its readiness report (/readyz), or with
+--live its liveness report (/healthz), and exit with 0 when it is healthy,
+1 when it is not, and 2 when no pool can be reached. The pool must have
+been started with --listen; its address is read from the status file
+unless --addr is given.
+
+Readiness checks that the database answers, that every worker has polled
+for work recently (or is running a job that has not outlived the job
+timeout), that claims have not found the database locked for more than
+30s, and that the pool is not shutting down. Liveness leaves out the
+database and shutdown checks.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			flags := cmd.Flags()
+			addr, _ := flags.GetString("addr")
+			live, _ := flags.GetBool("live")
+			timeout, _ := flags.GetDuration("timeout")
+
+			if addr == "" {
+				status, err := readWorkerStatus(cfg)
+				if err != nil {
+					return exitError{healthExitUnreachable, err}
+				}
+				if status == nil {
+					return exitError{healthExitUnreachable, fmt.Errorf("no worker pool is running")}
+				}
+				if status.Listen == "" {
+					return exitError{healthExitUnreachable, fmt.Errorf("the worker pool (pid %d) was started without --listen", status.WorkerPoolPid)}
+				}
+				addr = status.Listen
+			}
+			path := "/readyz"
+			if live {
+				path = "/healthz"
+			}
+
+			client := &http.Client{Timeout: timeout}
+			resp, err := client.Get("http://" + addr + path)
+			if err != nil {
+				return exitError{healthExitUnreachable, err}
+			}
+			defer resp.Body.Close()
+			var report health.Report
+			if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+				return exitError{healthExitUnreachable, fmt.Errorf("%s answered %s without a health report", addr, resp.Status)}
+			}
+
+			rows := make([][]string, len(report.Checks))
+			for i, c := range report.Checks {
+				rows[i] = []string{c.Name, strconv.FormatBool(c.OK), c.Detail}
+			}
+			if err := printResult(cmd, output.Result{
+				Columns: []string{"check", "ok", "detail"},
+				Rows:    rows,
+				Records: report,
+				Text: func(w io.Writer) error {
+					fmt.Fprintf(w, "Status: %s\n\n", report.Status)
+					return output.WriteTable(w, []string{"check", "ok", "detail"}, rows)
+				},
+			}); err != nil {
+				return err
+			}
+			if !report.Healthy() || resp.StatusCode != http.StatusOK {
+				return exitError{healthExitUnhealthy, fmt.Errorf("worker pool is unhealthy")}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String("addr", "", "Address of the pool's HTTP server (default: the --listen address it was started with)")
+	cmd.Flags().Bool("live", false, "Check liveness (/healthz) instead of readiness (/readyz)")
+	cmd.Flags().Duration("timeout", 5*time.Second, "How long to wait for an answer")
+	return cmd
+}
@@ -0,0 +1,362 @@
+// Package archive keeps jobs removed from the live database in a directory
+// of gzip-compressed JSON lines segments, one model.JobRecord per line. A
+// manifest lists every segment with its SHA-256 checksum so that a damaged
+// or altered segment is detected before it is read.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ManifestVersion is the manifest format this package writes.
+const ManifestVersion = 1
+
+const manifestName = "manifest.json"
+
+// SegmentSize is the default number of jobs per segment.
+const SegmentSize = 1000
+
+// ErrCorrupt is returned when a segment does not match its checksum.
+var ErrCorrupt = errors.New("archive segment is corrupt")
+
+// Manifest describes the segments in an archive directory.
+type Manifest struct {
+	Version  int       `json:"version"`
+	Segments []Segment `json:"segments"`
+}
+
+// Segment is one compressed file of archived jobs.
+type Segment struct {
+	File      string    `json:"file"`
+	CreatedAt time.Time `json:"created_at"`
+	Jobs      int       `json:"jobs"`
+	Bytes     int64     `json:"bytes"`
+	SHA256    string    `json:"sha256"`
+	// OldestUpdate and NewestUpdate bound the jobs' updated_at.
+	OldestUpdate time.Time `json:"oldest_update"`
+	NewestUpdate time.Time `json:"newest_update"`
+}
+
+// Archive is an archive directory.
+type Archive struct {
+	Dir string
+}
+
+// Open returns the archive in dir, creating the directory if needed.
+func Open(dir string) (*Archive, error) {
+	if dir == "" {
+		return nil, errors.New("no archive directory given")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Archive{Dir: dir}, nil
+}
+
+// Manifest reads the archive's manifest. A directory without one is an
+// empty archive.
+func (a *Archive) Manifest() (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(a.Dir, manifestName))
+	if os.IsNotExist(err) {
+		return &Manifest{Version: ManifestVersion}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid archive manifest: %w", err)
+	}
+	if m.Version > ManifestVersion {
+		return nil, fmt.Errorf("archive manifest version %d is newer than this queuectl supports (%d)", m.Version, ManifestVersion)
+	}
+	return &m, nil
+}
+
+// saveManifest replaces the manifest atomically.
+func (a *Archive) saveManifest(m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(a.Dir, manifestName+".tmp")
+	if err := writeFileSync(tmp, data); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(a.Dir, manifestName))
+}
+
+// lock serializes writers to the archive across processes with a lock
+// file. A lock older than staleLock is assumed to be left by a crash.
+func (a *Archive) lock() (func(), error) {
+	const staleLock = 5 * time.Minute
+	path := filepath.Join(a.Dir, "archive.lock")
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("archive %s is locked by another process (%s)", a.Dir, path)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// Move archives the jobs matching filter, batch jobs per segment, and
+// removes them from store. Each segment is written and added to the
+// manifest inside the transaction that deletes its jobs, so a job is never
+// only half moved: a failure leaves it in the database, and a crash at the
+// worst moment leaves it in both places. Canceling ctx stops after the
+// current segment.
+func (a *Archive) Move(ctx context.Context, store *storage.Store, filter storage.JobFilter, batch int) (moved int, segments []Segment, err error) {
+	unlock, err := a.lock()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer unlock()
+
+	if batch <= 0 {
+		batch = SegmentSize
+	}
+	filter.Limit = batch
+	for ctx.Err() == nil {
+		var seg Segment
+		n, err := store.MoveJobs(filter, func(records []model.JobRecord) error {
+			var err error
+			seg, err = a.writeSegment(records)
+			return err
+		})
+		if err != nil {
+			if seg.File != "" {
+				a.dropSegment(seg)
+			}
+			return moved, segments, err
+		}
+		if n == 0 {
+			break
+		}
+		moved += n
+		segments = append(segments, seg)
+		if n < batch {
+			break
+		}
+	}
+	return moved, segments, nil
+}
+
+// writeSegment writes records to a new segment file and adds it to the
+// manifest. The caller holds the lock.
+func (a *Archive) writeSegment(records []model.JobRecord) (Segment, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for i := range records {
+		if err := enc.Encode(&records[i]); err != nil {
+			return Segment{}, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return Segment{}, err
+	}
+
+	m, err := a.Manifest()
+	if err != nil {
+		return Segment{}, err
+	}
+	now := time.Now()
+	sum := sha256.Sum256(buf.Bytes())
+	seg := Segment{
+		File:         fmt.Sprintf("jobs-%s-%04d.jsonl.gz", now.UTC().Format("20060102T150405Z"), len(m.Segments)+1),
+		CreatedAt:    now,
+		Jobs:         len(records),
+		Bytes:        int64(buf.Len()),
+		SHA256:       hex.EncodeToString(sum[:]),
+		OldestUpdate: records[0].Job.UpdatedAt,
+		NewestUpdate: records[len(records)-1].Job.UpdatedAt,
+	}
+	if err := writeFileSync(filepath.Join(a.Dir, seg.File), buf.Bytes()); err != nil {
+		return Segment{}, err
+	}
+	m.Segments = append(m.Segments, seg)
+	if err := a.saveManifest(m); err != nil {
+		os.Remove(filepath.Join(a.Dir, seg.File))
+		return Segment{}, err
+	}
+	return seg, nil
+}
+
+// dropSegment undoes writeSegment after the database refused the move.
+func (a *Archive) dropSegment(seg Segment) {
+	if m, err := a.Manifest(); err == nil {
+		m.Segments = slices.DeleteFunc(m.Segments, func(s Segment) bool { return s.File == seg.File })
+		a.saveManifest(m)
+	}
+	os.Remove(filepath.Join(a.Dir, seg.File))
+}
+
+// ReadSegment verifies a segment against its checksum and returns its
+// records.
+func (a *Archive) ReadSegment(seg Segment) ([]model.JobRecord, error) {
+	data, err := os.ReadFile(filepath.Join(a.Dir, seg.File))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != seg.SHA256 {
+		return nil, fmt.Errorf("%w: %s does not match its checksum", ErrCorrupt, seg.File)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCorrupt, seg.File, err)
+	}
+	var records []model.JobRecord
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		var r model.JobRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrCorrupt, seg.File, err)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCorrupt, seg.File, err)
+	}
+	return records, nil
+}
+
+// Query selects archived jobs. Zero fields match everything.
+type Query struct {
+	// IDs matches these job IDs exactly.
+	IDs             []string
+	IDPrefix        string
+	Queue           string
+	States          []string
+	CommandContains string
+	UpdatedAfter    time.Time
+	UpdatedBefore   time.Time
+}
+
+// Match reports whether job is selected by q.
+func (q Query) Match(job *model.Job) bool {
+	switch {
+	case len(q.IDs) > 0 && !slices.Contains(q.IDs, job.ID),
+		q.IDPrefix != "" && !strings.HasPrefix(job.ID, q.IDPrefix),
+		q.Queue != "" && job.Queue != q.Queue,
+		len(q.States) > 0 && !slices.Contains(q.States, job.State),
+		q.CommandContains != "" && !strings.Contains(job.Command, q.CommandContains),
+		!q.UpdatedAfter.IsZero() && job.UpdatedAt.Before(q.UpdatedAfter),
+		!q.UpdatedBefore.IsZero() && !job.UpdatedAt.Before(q.UpdatedBefore):
+		return false
+	}
+	return true
+}
+
+// skip reports whether no job in seg can match q's time bounds.
+func (q Query) skip(seg Segment) bool {
+	return (!q.UpdatedAfter.IsZero() && seg.NewestUpdate.Before(q.UpdatedAfter)) ||
+		(!q.UpdatedBefore.IsZero() && !seg.OldestUpdate.Before(q.UpdatedBefore))
+}
+
+// Found is an archived job matched by Search.
+type Found struct {
+	Segment string `json:"segment"`
+	model.JobRecord
+}
+
+// Search returns the archived jobs matching q. When a job was archived
+// more than once, only its most recent copy is returned.
+func (a *Archive) Search(q Query) ([]Found, error) {
+	m, err := a.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	var found []Found
+	index := make(map[string]int)
+	for _, seg := range m.Segments {
+		if q.skip(seg) {
+			continue
+		}
+		records, err := a.ReadSegment(seg)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range records {
+			if !q.Match(&r.Job) {
+				continue
+			}
+			f := Found{Segment: seg.File, JobRecord: r}
+			if i, ok := index[r.Job.ID]; ok {
+				found[i] = f
+				continue
+			}
+			index[r.Job.ID] = len(found)
+			found = append(found, f)
+		}
+	}
+	return found, nil
+}
+
+// Restore puts the archived jobs matching q back into store. Restored jobs
+// get updated_at set to now, which restarts their retention period. Jobs
+// that already exist in the database are skipped. Archive segments are
+// left as they are.
+func (a *Archive) Restore(store *storage.Store, q Query) (restored, skipped []string, err error) {
+	found, err := a.Search(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(found) == 0 {
+		return nil, nil, nil
+	}
+	now := time.Now()
+	records := make([]model.JobRecord, 0, len(found))
+	for _, f := range found {
+		f.Job.UpdatedAt = now
+		records = append(records, f.JobRecord)
+	}
+	return store.RestoreJobs(records)
+}
+
+// writeFileSync writes data to path and flushes it to disk.
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
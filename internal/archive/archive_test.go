@@ -0,0 +1,214 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newStore returns a fresh store with n completed jobs, job-0 to job-n-1,
+// updated a minute apart in that order, each with one attempt.
+func newStore(t *testing.T, n int) *storage.Store {
+	t.Helper()
+	store, err := storage.NewStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Db.Close() })
+	start := time.Now().Add(-time.Hour)
+	for i := range n {
+		job := &model.Job{ID: fmt.Sprintf("job-%d", i), Command: fmt.Sprintf("echo %d", i)}
+		if err := job.Prepare(start, 3); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateJob(job); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Db.Exec(`update jobs set state = ?, updated_at = ? where id = ?`,
+			model.StateCompleted, start.Add(time.Duration(i)*time.Minute), job.ID); err != nil {
+			t.Fatal(err)
+		}
+		a := &model.Attempt{JobID: job.ID, Attempt: 1, WorkerID: "w1", StartedAt: start, FinishedAt: start, Output: fmt.Sprintf("%d\n", i)}
+		if err := store.RecordAttempt(a, job.Queue); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func openArchive(t *testing.T) *Archive {
+	t.Helper()
+	a, err := Open(filepath.Join(t.TempDir(), "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func ids(found []Found) []string {
+	var ids []string
+	for _, f := range found {
+		ids = append(ids, f.Job.ID)
+	}
+	return ids
+}
+
+func TestMoveAndSearch(t *testing.T) {
+	store := newStore(t, 5)
+	a := openArchive(t)
+	moved, segments, err := a.Move(context.Background(), store, storage.JobFilter{}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 5 || len(segments) != 3 || segments[0].Jobs != 2 || segments[2].Jobs != 1 {
+		t.Fatalf("moved %d jobs into %+v", moved, segments)
+	}
+	if n, _ := store.CountJobs(storage.JobFilter{}); n != 0 {
+		t.Errorf("%d jobs left in the database", n)
+	}
+	m, err := a.Manifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != ManifestVersion || len(m.Segments) != len(segments) {
+		t.Fatalf("manifest = %+v", m)
+	}
+	for i, seg := range m.Segments {
+		if seg.File != segments[i].File || seg.SHA256 != segments[i].SHA256 || seg.Jobs != segments[i].Jobs {
+			t.Errorf("manifest segment %d = %+v, want %+v", i, seg, segments[i])
+		}
+	}
+	if first := segments[0]; !first.OldestUpdate.Before(first.NewestUpdate) {
+		t.Errorf("first segment spans %v to %v", first.OldestUpdate, first.NewestUpdate)
+	}
+
+	all, err := a.Search(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(all); !slices.Equal(got, []string{"job-0", "job-1", "job-2", "job-3", "job-4"}) {
+		t.Errorf("archive holds %v", got)
+	}
+	if r := all[3]; r.Segment != segments[1].File || len(r.Attempts) != 1 || r.Attempts[0].Output != "3\n" || r.Job.Command != "echo 3" {
+		t.Errorf("job-3 archived as %+v", r)
+	}
+
+	tests := []struct {
+		name string
+		q    Query
+		want []string
+	}{
+		{"ids", Query{IDs: []string{"job-4", "job-1"}}, []string{"job-1", "job-4"}},
+		{"command", Query{CommandContains: "echo 2"}, []string{"job-2"}},
+		{"state", Query{States: []string{model.StateDead}}, nil},
+		{"updated before", Query{UpdatedBefore: segments[1].OldestUpdate}, []string{"job-0", "job-1"}},
+		{"updated after", Query{UpdatedAfter: segments[2].NewestUpdate}, []string{"job-4"}},
+	}
+	for _, tt := range tests {
+		found, err := a.Search(tt.q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(found); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: found %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	store := newStore(t, 3)
+	a := openArchive(t)
+	if _, _, err := a.Move(context.Background(), store, storage.JobFilter{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	restored, skipped, err := a.Restore(store, Query{IDs: []string{"job-1"}})
+	if err != nil || !slices.Equal(restored, []string{"job-1"}) || len(skipped) != 0 {
+		t.Fatalf("Restore = %v, %v, %v", restored, skipped, err)
+	}
+	job, err := store.GetJob("job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != model.StateCompleted || job.Command != "echo 1" || job.UpdatedAt.Before(before) {
+		t.Errorf("restored job = %+v, want updated_at reset to now", job)
+	}
+	attempts, err := store.AttemptsFor([]string{"job-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := attempts["job-1"]; len(got) != 1 || got[0].Output != "1\n" {
+		t.Errorf("restored attempts = %+v", got)
+	}
+
+	// Jobs already in the database are skipped, and the archive is left
+	// as it was.
+	restored, skipped, err = a.Restore(store, Query{})
+	if err != nil || len(restored) != 2 || !slices.Equal(skipped, []string{"job-1"}) {
+		t.Errorf("second Restore = %v, %v, %v", restored, skipped, err)
+	}
+	if found, _ := a.Search(Query{}); len(found) != 3 {
+		t.Errorf("archive holds %d jobs after restoring, want 3", len(found))
+	}
+
+	// Archiving a job again keeps only its newest copy in searches.
+	if _, err := store.Db.Exec(`update jobs set command = 'echo again' where id = 'job-1'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := a.Move(context.Background(), store, storage.JobFilter{IDPrefix: "job-1"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	found, err := a.Search(Query{IDs: []string{"job-1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Job.Command != "echo again" {
+		t.Errorf("found %+v, want the newest copy only", found)
+	}
+}
+
+func TestReadSegmentDetectsCorruption(t *testing.T) {
+	store := newStore(t, 2)
+	a := openArchive(t)
+	_, segments, err := a.Move(context.Background(), store, storage.JobFilter{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(a.Dir, segments[0].File)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Search(Query{}); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Search error = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestMoveKeepsJobsWhenTheArchiveFails(t *testing.T) {
+	store := newStore(t, 2)
+	a := openArchive(t)
+	if err := os.WriteFile(filepath.Join(a.Dir, manifestName), []byte(`{"version": 99}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	moved, _, err := a.Move(context.Background(), store, storage.JobFilter{}, 0)
+	if err == nil || moved != 0 {
+		t.Fatalf("Move = %d, %v, want an error", moved, err)
+	}
+	if n, _ := store.CountJobs(storage.JobFilter{}); n != 2 {
+		t.Errorf("%d jobs left, want both", n)
+	}
+	if entries, _ := os.ReadDir(a.Dir); len(entries) != 1 {
+		t.Errorf("archive directory holds %d files, want only the manifest", len(entries))
+	}
+}
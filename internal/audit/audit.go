@@ -0,0 +1,71 @@
+// Package audit records administrative actions, who performed them and how
+// they ended, in the queue database's append-only audit log.
+package audit
+
+import (
+	"log/slog"
+	"os"
+	"os/user"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"sync"
+	"time"
+)
+
+// maxArgBytes caps each recorded argument, such as an enqueued job's JSON.
+const maxArgBytes = 4096
+
+var (
+	actorOnce sync.Once
+	userName  string
+	hostName  string
+)
+
+// actor returns the OS user and host this process runs as.
+func actor() (string, string) {
+	actorOnce.Do(func() {
+		if u, err := user.Current(); err == nil {
+			userName = u.Username
+		}
+		if userName == "" {
+			userName = os.Getenv("USER")
+		}
+		if userName == "" {
+			userName = "unknown"
+		}
+		hostName, _ = os.Hostname()
+		if hostName == "" {
+			hostName = "unknown"
+		}
+	})
+	return userName, hostName
+}
+
+// Record appends an entry for action, performed from source with args and
+// ending with err, to store's audit log. Failing to record is logged, not
+// returned: the action has already happened.
+func Record(store *storage.Store, source, action string, args []string, err error) {
+	user, host := actor()
+	e := &model.AuditEntry{
+		At:      time.Now(),
+		User:    user,
+		Host:    host,
+		PID:     os.Getpid(),
+		Source:  source,
+		Action:  action,
+		Args:    make([]string, len(args)),
+		Outcome: model.AuditOK,
+	}
+	for i, arg := range args {
+		if len(arg) > maxArgBytes {
+			arg = arg[:maxArgBytes] + "..."
+		}
+		e.Args[i] = arg
+	}
+	if err != nil {
+		e.Outcome, e.Error = model.AuditError, err.Error()
+	}
+	if recErr := store.RecordAudit(e); recErr != nil {
+		slog.Warn("could not record audit entry", "component", "audit", "action", action, "err", recErr)
+	}
+}
@@ -0,0 +1,47 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"strings"
+	"testing"
+)
+
+func TestRecord(t *testing.T) {
+	store, err := storage.NewStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Db.Close()
+
+	long := strings.Repeat("j", maxArgBytes+10)
+	Record(store, model.AuditSourceCLI, "enqueue", []string{long}, nil)
+	Record(store, model.AuditSourceAPI, "job cancel", []string{"a"}, errors.New("job a is completed"))
+
+	entries, err := store.ListAudit(storage.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("recorded %d entries, want 2", len(entries))
+	}
+	ok, failed := entries[0], entries[1]
+	user, host := actor()
+	if ok.User != user || ok.Host != host || user == "" || host == "" || ok.PID != os.Getpid() {
+		t.Errorf("entry actor = %s@%s pid %d", ok.User, ok.Host, ok.PID)
+	}
+	if ok.Outcome != model.AuditOK || ok.Source != model.AuditSourceCLI || len(ok.Args[0]) != maxArgBytes+3 ||
+		!strings.HasSuffix(ok.Args[0], "...") {
+		t.Errorf("enqueue entry = %+v", ok)
+	}
+	if failed.Outcome != model.AuditError || failed.Error != "job a is completed" || failed.Source != model.AuditSourceAPI {
+		t.Errorf("cancel entry = %+v", failed)
+	}
+
+	// A store that cannot record does not fail the action.
+	store.Db.Close()
+	Record(store, model.AuditSourceCLI, "enqueue", nil, nil)
+}
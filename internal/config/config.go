@@ -2,14 +2,124 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"queueCtl/internal/model"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type Config struct {
 	DataDir     string  `json:"data_dir"`
 	MaxRetries  int     `json:"max_retries"`
 	BackoffBase float64 `json:"backoff_base"`
+	// JobTimeout limits each attempt of a job that sets no timeout of its
+	// own, as a duration like "30m".
+	JobTimeout string `json:"job_timeout"`
+
+	// DefaultLimits apply to every job that does not set its own.
+	DefaultLimits model.ResourceLimits `json:"default_limits"`
+	// CgroupParent is a cgroup v2 directory under which each job gets its
+	// own cgroup. Empty disables cgroup placement.
+	CgroupParent string `json:"cgroup_parent,omitempty"`
+
+	// Retention is how long jobs in a final state are kept after they last
+	// changed, keyed by state, as durations like "7d". States without an
+	// entry are kept forever.
+	Retention map[string]string `json:"retention,omitempty"`
+	// GCInterval is how often a running worker pool applies Retention.
+	GCInterval string `json:"gc_interval"`
+	// VacuumInterval is how often a running worker pool rebuilds the
+	// database file to return the space GC freed. Empty disables it.
+	VacuumInterval string `json:"vacuum_interval,omitempty"`
+	// ArchiveDir, when set, is where retention moves expired jobs instead
+	// of deleting them, and the default directory of the archive command.
+	ArchiveDir string `json:"archive_dir,omitempty"`
+	// EventRetention is how long lifecycle events are kept. Empty keeps
+	// them forever.
+	EventRetention string `json:"event_retention"`
+
+	// Notifiers are told about job state changes by a running worker pool.
+	Notifiers []Notifier `json:"notifiers,omitempty"`
+
+	// Tracing exports spans for the jobs a running worker pool processes.
+	Tracing Tracing `json:"tracing,omitzero"`
+}
+
+// Tracing selects where a worker pool exports its spans.
+type Tracing struct {
+	// Exporter is "otlp", "file", or empty to disable tracing.
+	Exporter string `json:"exporter,omitempty"`
+	// Endpoint is the base URL of an OTLP/HTTP collector. Empty uses
+	// OTEL_EXPORTER_OTLP_ENDPOINT, or http://localhost:4318.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Headers are sent with every OTLP request, e.g. for authentication.
+	Headers map[string]string `json:"headers,omitempty"`
+	// File receives one JSON span per line with the file exporter.
+	File string `json:"file,omitempty"`
+	// ServiceName is the service.name spans are reported under; it
+	// defaults to "queuectl".
+	ServiceName string `json:"service_name,omitempty"`
+}
+
+// Notifier sends an event when a job enters one of the States, by POSTing
+// it to URL or by running Command with the event on stdin.
+type Notifier struct {
+	Name string `json:"name"`
+	// States are the job states that trigger the notifier: failed,
+	// completed, dead, canceled or expired.
+	States []string `json:"states"`
+	// Queues limits the notifier to jobs in these queues; empty means all.
+	Queues []string `json:"queues,omitempty"`
+
+	URL string `json:"url,omitempty"`
+	// Secret, when set, signs webhook bodies with HMAC-SHA256.
+	Secret string `json:"secret,omitempty"`
+	// Retries is how many more times a failed delivery is tried.
+	Retries int `json:"retries,omitempty"`
+
+	Command string `json:"command,omitempty"`
+
+	// Timeout bounds one delivery attempt; it defaults to 10s.
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// NotifyStates are the job states a notifier can be triggered by.
+var NotifyStates = []string{model.StateFailed, model.StateCompleted, model.StateDead, model.StateCanceled, model.StateExpired}
+
+// Validate checks a notifier before it is saved.
+func (n *Notifier) Validate() error {
+	if n.Name == "" {
+		return fmt.Errorf("notifier has no name")
+	}
+	if (n.URL == "") == (n.Command == "") {
+		return fmt.Errorf("notifier %s: set either a URL or a command", n.Name)
+	}
+	if n.URL != "" && !strings.HasPrefix(n.URL, "http://") && !strings.HasPrefix(n.URL, "https://") {
+		return fmt.Errorf("notifier %s: URL must start with http:// or https://", n.Name)
+	}
+	if len(n.States) == 0 {
+		return fmt.Errorf("notifier %s: no states to notify on", n.Name)
+	}
+	for _, state := range n.States {
+		if !slices.Contains(NotifyStates, state) {
+			return fmt.Errorf("notifier %s: cannot notify on state %q", n.Name, state)
+		}
+	}
+	if n.Retries < 0 {
+		return fmt.Errorf("notifier %s: retries cannot be negative", n.Name)
+	}
+	if n.Timeout != "" {
+		if d, err := ParseDuration(n.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("notifier %s: invalid timeout %q", n.Name, n.Timeout)
+		}
+	}
+	return nil
 }
 
 const configFileName = "config.json"
@@ -20,9 +130,28 @@ func NewConfig() *Config {
 		DataDir:     "./db",
 		MaxRetries:  3,
 		BackoffBase: 2.0,
+		JobTimeout:  "5m",
+		GCInterval:     "1h",
+		EventRetention: "7d",
 	}
 }
 
+// RetentionPeriods parses Retention, rejecting states that are not final.
+func (c *Config) RetentionPeriods() (map[string]time.Duration, error) {
+	periods := make(map[string]time.Duration, len(c.Retention))
+	for state, value := range c.Retention {
+		if !model.IsTerminal(state) {
+			return nil, fmt.Errorf("retention: %q is not a final state", state)
+		}
+		d, err := ParseDuration(value)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("retention: invalid period %q for %s", value, state)
+		}
+		periods[state] = d
+	}
+	return periods, nil
+}
+
 func configPath() (string, error) {
 	configDir, err := os.UserConfigDir()
     if err!=nil{
@@ -72,4 +201,68 @@ func SaveConfig(cfg *Config) error {
 	}
 
 	return os.WriteFile(path, data, 0644)
-}
\ No newline at end of file
+}
+// ParseDuration is time.ParseDuration with an added "d" unit for days,
+// e.g. "7d" or "1d12h".
+func ParseDuration(s string) (time.Duration, error) {
+	days := 0
+	if i := strings.IndexByte(s, 'd'); i > 0 {
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		days, s = n, s[i+1:]
+	}
+	var rest time.Duration
+	if s != "" {
+		var err error
+		if rest, err = time.ParseDuration(s); err != nil {
+			return 0, err
+		}
+	}
+	return time.Duration(days)*24*time.Hour + rest, nil
+}
+
+// Live holds the configuration a running worker pool uses. Reloading the
+// pool replaces it as a whole, so a reader sees either the old or the new
+// configuration, never a mix.
+type Live struct {
+	p atomic.Pointer[Config]
+}
+
+// NewLive returns a Live holding cfg.
+func NewLive(cfg *Config) *Live {
+	l := &Live{}
+	l.p.Store(cfg)
+	return l
+}
+
+// Get returns the current configuration. Callers must not modify it.
+func (l *Live) Get() *Config {
+	return l.p.Load()
+}
+
+// Set replaces the configuration.
+func (l *Live) Set(cfg *Config) {
+	l.p.Store(cfg)
+}
+
+// RestartRequired lists the settings, by their JSON names, that differ
+// between old and new but that a running worker pool only reads when it
+// starts.
+func RestartRequired(old, new *Config) []string {
+	var changed []string
+	for _, f := range []struct {
+		name     string
+		old, new any
+	}{
+		{"data_dir", old.DataDir, new.DataDir},
+		{"notifiers", old.Notifiers, new.Notifiers},
+		{"tracing", old.Tracing, new.Tracing},
+	} {
+		if !reflect.DeepEqual(f.old, f.new) {
+			changed = append(changed, f.name)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"maps"
+	"queueCtl/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"7d":     7 * 24 * time.Hour,
+		"1d12h":  36 * time.Hour,
+		"90m":    90 * time.Minute,
+		"0d":     0,
+		"2d1.5h": 49*time.Hour + 30*time.Minute,
+	} {
+		if got, err := ParseDuration(s); err != nil || got != want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"d", "xd", "7", "7dd", "1.5d"} {
+		if got, err := ParseDuration(s); err == nil {
+			t.Errorf("ParseDuration(%q) = %v, want an error", s, got)
+		}
+	}
+}
+
+func TestRetentionPeriods(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Retention = map[string]string{model.StateCompleted: "7d", model.StateDead: "12h"}
+	got, err := cfg.RetentionPeriods()
+	want := map[string]time.Duration{model.StateCompleted: 7 * 24 * time.Hour, model.StateDead: 12 * time.Hour}
+	if err != nil || !maps.Equal(got, want) {
+		t.Errorf("RetentionPeriods = %v, %v, want %v", got, err, want)
+	}
+
+	for _, retention := range []map[string]string{
+		{model.StatePending: "1d"},
+		{model.StateCompleted: "soon"},
+		{model.StateCompleted: "0d"},
+		{model.StateCompleted: "-1h"},
+	} {
+		cfg.Retention = retention
+		if _, err := cfg.RetentionPeriods(); err == nil {
+			t.Errorf("RetentionPeriods accepted %v", retention)
+		}
+	}
+}
+
+func TestNotifierValidate(t *testing.T) {
+	valid := []Notifier{
+		{Name: "hook", URL: "https://example.com/hook", States: []string{model.StateDead}, Retries: 2, Timeout: "5s"},
+		{Name: "page", Command: "page-oncall", States: []string{model.StateFailed, model.StateExpired}},
+	}
+	for _, n := range valid {
+		if err := n.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", n, err)
+		}
+	}
+
+	states := []string{model.StateDead}
+	for _, n := range []Notifier{
+		{URL: "https://example.com", States: states},
+		{Name: "none", States: states},
+		{Name: "both", URL: "https://example.com", Command: "true", States: states},
+		{Name: "scheme", URL: "ftp://example.com", States: states},
+		{Name: "no-states", Command: "true"},
+		{Name: "pending", Command: "true", States: []string{model.StatePending}},
+		{Name: "retries", Command: "true", States: states, Retries: -1},
+		{Name: "timeout", Command: "true", States: states, Timeout: "0s"},
+		{Name: "timeout", Command: "true", States: states, Timeout: "soon"},
+	} {
+		if err := n.Validate(); err == nil {
+			t.Errorf("Validate(%+v) succeeded", n)
+		}
+	}
+}
+
+func TestRestartRequired(t *testing.T) {
+	old := NewConfig()
+	reloadable := *old
+	reloadable.MaxRetries = 9
+	reloadable.BackoffBase = 3
+	reloadable.JobTimeout = "1m"
+	reloadable.Retention = map[string]string{model.StateCompleted: "7d"}
+	reloadable.GCInterval = "10m"
+	if got := RestartRequired(old, &reloadable); len(got) != 0 {
+		t.Errorf("RestartRequired = %v for settings a reload applies", got)
+	}
+
+	changed := reloadable
+	changed.DataDir = "/var/lib/queuectl"
+	changed.Notifiers = []Notifier{{Name: "hook", Command: "true", States: []string{model.StateDead}}}
+	want := []string{"data_dir", "notifiers"}
+	if got := RestartRequired(old, &changed); !slices.Equal(got, want) {
+		t.Errorf("RestartRequired = %v, want %v", got, want)
+	}
+}
+
+func TestLive(t *testing.T) {
+	first, second := NewConfig(), NewConfig()
+	live := NewLive(first)
+	if live.Get() != first {
+		t.Fatal("Get does not return the initial config")
+	}
+	live.Set(second)
+	if live.Get() != second {
+		t.Error("Get does not return the replaced config")
+	}
+}
@@ -0,0 +1,160 @@
+//go:build unix
+
+package daemon
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain runs the test binary as the child of TestStart when Start
+// starts it.
+func TestMain(m *testing.M) {
+	if IsChild() {
+		runChild()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func runChild() {
+	switch os.Getenv("QUEUECTL_TEST_CHILD") {
+	case "exit":
+		os.Exit(3)
+	case "slow":
+		// Outlives the parent's timeout without writing a pid file.
+		time.Sleep(2 * time.Second)
+	default:
+		if _, err := AcquirePIDFile(os.Getenv("QUEUECTL_TEST_PIDFILE")); err != nil {
+			os.Exit(1)
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+func TestPIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queuectl.pid")
+	if pid := ReadPIDFile(path); pid != 0 {
+		t.Errorf("ReadPIDFile of a missing file = %d", pid)
+	}
+	p, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid := ReadPIDFile(path); pid != os.Getpid() {
+		t.Errorf("pid file holds %d, want %d", pid, os.Getpid())
+	}
+
+	// The lock is per open file, so a second acquisition fails even in
+	// the same process.
+	_, err = AcquirePIDFile(path)
+	if !errors.Is(err, ErrRunning) || !strings.Contains(err.Error(), strconv.Itoa(os.Getpid())) {
+		t.Errorf("second AcquirePIDFile error = %v, want ErrRunning naming the pid", err)
+	}
+
+	if err := p.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("pid file left after Release: %v", err)
+	}
+}
+
+func TestPIDFileTakesOverStaleFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queuectl.pid")
+	// A long PID left by a process that died without removing its file.
+	if err := os.WriteFile(path, []byte("4194303999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := AcquirePIDFile(path)
+	if err != nil {
+		t.Fatalf("stale pid file not taken over: %v", err)
+	}
+	defer p.Release()
+	if data, _ := os.ReadFile(path); string(data) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("pid file = %q", data)
+	}
+}
+
+func TestStart(t *testing.T) {
+	dir := t.TempDir()
+	pidPath, logPath := filepath.Join(dir, "queuectl.pid"), filepath.Join(dir, "queuectl.log")
+	t.Setenv("QUEUECTL_TEST_PIDFILE", pidPath)
+
+	pid, err := Start(nil, logPath, pidPath, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if proc, err := os.FindProcess(pid); err == nil {
+			proc.Kill()
+		}
+	}()
+	if !ProcessAlive(pid) || ReadPIDFile(pidPath) != pid {
+		t.Errorf("child %d is not running with its pid file", pid)
+	}
+	if _, err := AcquirePIDFile(pidPath); !errors.Is(err, ErrRunning) {
+		t.Errorf("AcquirePIDFile while the child runs = %v, want ErrRunning", err)
+	}
+
+	t.Setenv("QUEUECTL_TEST_CHILD", "exit")
+	if _, err := Start(nil, logPath, filepath.Join(dir, "other.pid"), 10*time.Second); err == nil ||
+		!strings.Contains(err.Error(), "exited during startup") || !strings.Contains(err.Error(), logPath) {
+		t.Errorf("Start of a child that exits = %v", err)
+	}
+	t.Setenv("QUEUECTL_TEST_CHILD", "slow")
+	if _, err := Start(nil, logPath, filepath.Join(dir, "slow.pid"), 300*time.Millisecond); err == nil ||
+		!strings.Contains(err.Error(), "did not start within 300ms") {
+		t.Errorf("Start of a child that never writes its pid = %v", err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := Notify(NotifyReady); err != nil {
+		t.Errorf("Notify without a service manager = %v", err)
+	}
+	t.Setenv("NOTIFY_SOCKET", socket)
+	if err := Notify(NotifyReady + "\nSTATUS=4 workers"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "READY=1\nSTATUS=4 workers" {
+		t.Errorf("service manager got %q", got)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "30000000")
+	t.Setenv("WATCHDOG_PID", "")
+	if got := WatchdogInterval(); got != 30*time.Second {
+		t.Errorf("WatchdogInterval = %v, want 30s", got)
+	}
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if got := WatchdogInterval(); got != 0 {
+		t.Errorf("WatchdogInterval for another process = %v", got)
+	}
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("WATCHDOG_USEC", "soon")
+	if got := WatchdogInterval(); got != 0 {
+		t.Errorf("WatchdogInterval with a bad value = %v", got)
+	}
+}
@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// ChildEnv is set in the environment of a process started by Start, so that
+// it runs its work instead of detaching again.
+const ChildEnv = "QUEUECTL_DAEMON_CHILD"
+
+// IsChild reports whether this process was started by Start.
+func IsChild() bool {
+	return os.Getenv(ChildEnv) == "1"
+}
+
+// Start runs this executable again with args, detached from the terminal,
+// with its standard output and error appended to logPath. It waits until
+// the child has written its PID to pidPath and returns that PID. If the
+// child exits first, or does not get that far within timeout, it returns an
+// error pointing at the log.
+func Start(args []string, logPath, pidPath string, timeout time.Duration) (int, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(), ChildEnv+"=1")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	pid := cmd.Process.Pid
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	deadline := time.After(timeout)
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exit status 0")
+			}
+			return 0, fmt.Errorf("the worker pool exited during startup (%v); see %s", err, logPath)
+		case <-deadline:
+			return 0, fmt.Errorf("the worker pool (pid %d) did not start within %s; see %s", pid, timeout, logPath)
+		case <-ticker.C:
+			if ReadPIDFile(pidPath) == pid {
+				return pid, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package daemon runs the worker pool as a well-behaved service: detached
+// in the background with a PID lock file, or under systemd with readiness
+// and watchdog notifications, and it writes the systemd unit to do so.
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrRunning is returned by AcquirePIDFile when a live process holds the
+// lock.
+var ErrRunning = errors.New("a worker pool is already running")
+
+// PIDFile is a held PID lock file. The lock is released when the process
+// exits, however it exits, so a crash never leaves it held.
+type PIDFile struct {
+	path string
+	f    *os.File
+}
+
+// AcquirePIDFile locks path and writes this process's PID to it. If another
+// live process holds it, it returns an error wrapping ErrRunning. A file
+// left behind by a process that has died is taken over.
+func AcquirePIDFile(path string) (*PIDFile, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	previous := readPID(f)
+	running := func() (*PIDFile, error) {
+		f.Close()
+		if previous > 0 {
+			return nil, fmt.Errorf("%w (pid %d)", ErrRunning, previous)
+		}
+		return nil, ErrRunning
+	}
+	if err := lockFile(f); err != nil {
+		return running()
+	}
+	if previous > 0 && previous != os.Getpid() {
+		if !lockSupported && ProcessAlive(previous) {
+			return running()
+		}
+		slog.Warn("taking over a stale pid file", "file", path, "stale_pid", previous)
+	}
+
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if _, err := f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &PIDFile{path: path, f: f}, nil
+}
+
+// Release removes the file and drops the lock.
+func (p *PIDFile) Release() error {
+	os.Remove(p.path)
+	return p.f.Close()
+}
+
+// ReadPIDFile returns the PID recorded in path, or 0 when there is none.
+func ReadPIDFile(path string) int {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	return readPID(f)
+}
+
+func readPID(f *os.File) int {
+	buf := make([]byte, 32)
+	n, _ := f.ReadAt(buf, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,111 @@
+package daemon
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// UnitOptions describe the systemd service Unit writes.
+type UnitOptions struct {
+	Description string
+	// Exec is the queuectl executable, and Args what follows it on the
+	// ExecStart line.
+	Exec string
+	Args []string
+	// WorkingDirectory anchors a relative data_dir.
+	WorkingDirectory string
+	// User runs the service as that account; empty for a user unit.
+	User string
+	// Environment is set for the service, e.g. XDG_CONFIG_HOME so that it
+	// finds the same configuration file as the user who installed it.
+	Environment map[string]string
+	// Watchdog is WatchdogSec; 0 disables the watchdog.
+	Watchdog time.Duration
+	// StopTimeout is how long systemd waits after SIGTERM before killing
+	// the pool and its jobs.
+	StopTimeout time.Duration
+	// UserUnit writes a unit for the per-user service manager.
+	UserUnit bool
+}
+
+var unitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description={{.Description}}
+{{- if not .UserUnit}}
+After=network-online.target
+Wants=network-online.target
+{{- end}}
+
+[Service]
+Type=notify
+NotifyAccess=main
+ExecStart={{.ExecStart}}
+ExecReload=/bin/kill -HUP $MAINPID
+{{- if .WorkingDirectory}}
+WorkingDirectory={{.WorkingDirectory}}
+{{- end}}
+{{- if .User}}
+User={{.User}}
+{{- end}}
+{{- range .Env}}
+Environment={{.}}
+{{- end}}
+{{- if .WatchdogSec}}
+WatchdogSec={{.WatchdogSec}}
+{{- end}}
+# SIGTERM goes to the pool, which interrupts its jobs gracefully; anything
+# left when the timeout expires is killed.
+KillMode=mixed
+TimeoutStopSec={{.StopTimeoutSec}}
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy={{if .UserUnit}}default.target{{else}}multi-user.target{{end}}
+`))
+
+// Unit returns the text of a systemd service unit that runs the worker
+// pool in the foreground as a Type=notify service: it reports readiness,
+// pings the watchdog while healthy, and reloads its configuration on
+// systemctl reload.
+func Unit(opts UnitOptions) (string, error) {
+	execStart := []string{quoteArg(opts.Exec)}
+	for _, arg := range opts.Args {
+		execStart = append(execStart, quoteArg(arg))
+	}
+	var env []string
+	for _, k := range slices.Sorted(maps.Keys(opts.Environment)) {
+		env = append(env, quoteArg(k+"="+opts.Environment[k]))
+	}
+	data := struct {
+		UnitOptions
+		ExecStart      string
+		Env            []string
+		WatchdogSec    int
+		StopTimeoutSec int
+	}{
+		UnitOptions:    opts,
+		ExecStart:      strings.Join(execStart, " "),
+		Env:            env,
+		WatchdogSec:    int(opts.Watchdog.Round(time.Second) / time.Second),
+		StopTimeoutSec: int(opts.StopTimeout.Round(time.Second) / time.Second),
+	}
+	var b strings.Builder
+	if err := unitTemplate.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// quoteArg quotes s for a unit file command line or assignment when it
+// holds characters systemd would otherwise split on or expand.
+func quoteArg(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\$%;") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`)
+	return fmt.Sprintf(`"%s"`, r.Replace(s))
+}
@@ -0,0 +1,87 @@
+package daemon
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnit(t *testing.T) {
+	unit, err := Unit(UnitOptions{
+		Description:      "queuectl worker pool",
+		Exec:             "/usr/local/bin/queuectl",
+		Args:             []string{"worker", "start", "--count", "4"},
+		WorkingDirectory: "/srv/queue",
+		User:             "queue",
+		Environment:      map[string]string{"XDG_CONFIG_HOME": "/home/ops/.config", "A": "1 2"},
+		Watchdog:         1500 * time.Millisecond,
+		StopTimeout:      90 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[Unit]
+Description=queuectl worker pool
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=notify
+NotifyAccess=main
+ExecStart=/usr/local/bin/queuectl worker start --count 4
+ExecReload=/bin/kill -HUP $MAINPID
+WorkingDirectory=/srv/queue
+User=queue
+Environment="A=1 2"
+Environment=XDG_CONFIG_HOME=/home/ops/.config
+WatchdogSec=2
+# SIGTERM goes to the pool, which interrupts its jobs gracefully; anything
+# left when the timeout expires is killed.
+KillMode=mixed
+TimeoutStopSec=90
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`
+	if unit != want {
+		t.Errorf("unit =\n%s\nwant\n%s", unit, want)
+	}
+}
+
+func TestUserUnit(t *testing.T) {
+	unit, err := Unit(UnitOptions{Description: "queuectl", Exec: "/opt/queue ctl/queuectl", Args: []string{"worker", "start"}, UserUnit: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`ExecStart="/opt/queue ctl/queuectl" worker start`, "WantedBy=default.target", "TimeoutStopSec=0"} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("user unit lacks %q:\n%s", want, unit)
+		}
+	}
+	for _, unwanted := range []string{"After=", "User=", "WatchdogSec=", "WorkingDirectory=", "Environment="} {
+		if strings.Contains(unit, unwanted) {
+			t.Errorf("user unit has %q:\n%s", unwanted, unit)
+		}
+	}
+}
+
+func TestQuoteArg(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain", "plain"},
+		{"--data-dir=/var/lib/q", "--data-dir=/var/lib/q"},
+		{"", `""`},
+		{"two words", `"two words"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`C:\queue`, `"C:\\queue"`},
+		{"$HOME", `"$$HOME"`},
+		{"100%", `"100%%"`},
+		{"a;b", `"a;b"`},
+	}
+	for _, tt := range tests {
+		if got := quoteArg(tt.in); got != tt.want {
+			t.Errorf("quoteArg(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
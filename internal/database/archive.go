@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"database/sql"
+	"queueCtl/internal/model"
+	"sort"
+	"strings"
+)
+
+// MoveJobs removes up to filter.Limit jobs matching filter (every match
+// when Limit is zero), least recently updated first, together with their
+// attempt history. The removed records are passed to save before the
+// transaction commits; if save returns an error nothing is removed. It
+// returns the number of jobs moved, which is zero when nothing matched, in
+// which case save is not called.
+func (s *Store) MoveJobs(filter JobFilter, save func([]model.JobRecord) error) (int, error) {
+	where, args := filterWhere(filter)
+	cond := ""
+	if len(where) > 0 {
+		cond = ` where ` + strings.Join(where, " and ")
+	}
+	if filter.Limit > 0 {
+		cond = ` where id in (select id from jobs` + cond + ` order by updated_at, id limit ?)`
+		args = append(args, filter.Limit)
+	}
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Deleting first takes the write lock up front, so the rows cannot
+	// change between being read and being removed.
+	rows, err := tx.Query(`delete from jobs`+cond+` returning `+jobColumns, args...)
+	if err != nil {
+		return 0, err
+	}
+	var records []model.JobRecord
+	index := make(map[string]int)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		index[job.ID] = len(records)
+		records = append(records, model.JobRecord{Job: *job})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]any, 0, len(records))
+	for _, r := range records {
+		ids = append(ids, r.Job.ID)
+	}
+	rows, err = tx.Query(`delete from job_attempts where job_id in (?`+strings.Repeat(",?", len(ids)-1)+`)
+		returning `+attemptColumns, ids...)
+	if err != nil {
+		return 0, err
+	}
+	for rows.Next() {
+		a, err := scanAttempt(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		r := &records[index[a.JobID]]
+		r.Attempts = append(r.Attempts, *a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// RETURNING gives no ordering guarantee.
+	sort.Slice(records, func(i, j int) bool {
+		a, b := records[i].Job, records[j].Job
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.Before(b.UpdatedAt)
+		}
+		return a.ID < b.ID
+	})
+	for _, r := range records {
+		sort.Slice(r.Attempts, func(i, j int) bool { return r.Attempts[i].Attempt < r.Attempts[j].Attempt })
+	}
+
+	if err := save(records); err != nil {
+		return 0, err
+	}
+	return len(records), tx.Commit()
+}
+
+// RestoreJobs inserts jobs with every stored field and their attempt
+// history, in one transaction. Jobs whose ID already exists are left alone
+// and reported as skipped.
+func (s *Store) RestoreJobs(records []model.JobRecord) (restored, skipped []string, err error) {
+	result, err := s.ImportJobs(nil, nil, records, ConflictSkip)
+	if err != nil {
+		return nil, nil, err
+	}
+	return result.Created, result.Skipped, nil
+}
+
+// insertRecord writes every column of job unless its ID is taken, and
+// reports whether it did.
+func insertRecord(db execer, job *model.Job) (bool, error) {
+	limits, err := marshalLimits(job.Limits)
+	if err != nil {
+		return false, err
+	}
+	var payload sql.NullString
+	if len(job.Payload) > 0 {
+		payload = sql.NullString{String: string(job.Payload), Valid: true}
+	}
+	env, onSuccess, onFailure, err := marshalChain(job)
+	if err != nil {
+		return false, err
+	}
+	statement := `insert or ignore into jobs (
+		id, command, state, attempts, max_retries, created_at, updated_at, next_run_at, output,
+		limits, exit_code, failure_reason, type, payload, worker_id, queue, env, on_success, on_failure, parent_id, batch_id, concurrency_key, lock_key,
+		expires_at, deadline, traceparent, timeout
+		) values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?)`
+	res, err := db.Exec(statement,
+		job.ID, job.Command, job.State, job.Attempts, job.MaxRetries, job.CreatedAt, job.UpdatedAt, job.NextRunAt, job.Output,
+		limits, job.ExitCode, job.FailureReason, job.Type, payload, job.WorkerID, job.Queue,
+		env, onSuccess, onFailure, nullString(job.ParentID), nullString(job.BatchID), nullString(job.ConcurrencyKey), nullString(job.LockKey),
+		nullTime(job.ExpiresAt), nullTime(job.Deadline), nullString(job.TraceParent), nullString(job.Timeout))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"queueCtl/internal/model"
+	"strings"
+)
+
+func (s *Store) initAttempts() error {
+	createAttemptTable := `create table if not exists job_attempts(
+		id integer primary key autoincrement,
+		job_id text not null,
+		attempt integer not null,
+		worker_id text not null,
+		started_at DATETIME not null,
+		finished_at DATETIME not null,
+		exit_code integer not null,
+		failure_reason text,
+		output text,
+		leftover_processes text
+	);
+	create index if not exists job_attempts_job_id on job_attempts(job_id);`
+	_, err := s.Db.Exec(createAttemptTable)
+	return err
+}
+
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// RecordAttempt appends the outcome of one execution to the job's history.
+// Its output is also recorded as an output event.
+func (s *Store) RecordAttempt(a *model.Attempt, queue string) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := insertAttempt(tx, a); err != nil {
+		return err
+	}
+	ev := &model.Event{
+		At:            a.FinishedAt,
+		Type:          model.EventOutput,
+		JobID:         a.JobID,
+		Queue:         queue,
+		State:         model.StateProcessing,
+		Attempt:       a.Attempt,
+		WorkerID:      a.WorkerID,
+		ExitCode:      a.ExitCode,
+		FailureReason: a.FailureReason,
+		Output:        a.Output,
+	}
+	if err := recordEvent(tx, ev); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertAttempt(db execer, a *model.Attempt) error {
+	var leftovers sql.NullString
+	if len(a.LeftoverProcesses) > 0 {
+		data, err := json.Marshal(a.LeftoverProcesses)
+		if err != nil {
+			return err
+		}
+		leftovers = sql.NullString{String: string(data), Valid: true}
+	}
+	statement := `insert into job_attempts (
+		job_id, attempt, worker_id, started_at, finished_at, exit_code, failure_reason, output, leftover_processes
+		) values (?,?,?,?,?,?,?,?,?)`
+	_, err := db.Exec(statement,
+		a.JobID,
+		a.Attempt,
+		a.WorkerID,
+		a.StartedAt,
+		a.FinishedAt,
+		a.ExitCode,
+		a.FailureReason,
+		a.Output,
+		leftovers,
+	)
+	return err
+}
+
+// attemptColumns is the column list scanAttempt expects.
+const attemptColumns = `job_id, attempt, worker_id, started_at, finished_at, exit_code, failure_reason, output, leftover_processes`
+
+// scanAttempt reads a row selected with attemptColumns.
+func scanAttempt(row rowScanner) (*model.Attempt, error) {
+	var a model.Attempt
+	var failureReason, output, leftovers sql.NullString
+	if err := row.Scan(
+		&a.JobID,
+		&a.Attempt,
+		&a.WorkerID,
+		&a.StartedAt,
+		&a.FinishedAt,
+		&a.ExitCode,
+		&failureReason,
+		&output,
+		&leftovers,
+	); err != nil {
+		return nil, err
+	}
+	a.FailureReason = failureReason.String
+	a.Output = output.String
+	if leftovers.Valid && leftovers.String != "" {
+		if err := json.Unmarshal([]byte(leftovers.String), &a.LeftoverProcesses); err != nil {
+			return nil, err
+		}
+	}
+	return &a, nil
+}
+
+// AttemptsFor returns the attempt history of each of the given jobs, in
+// attempt order, keyed by job ID.
+func (s *Store) AttemptsFor(jobIDs []string) (map[string][]model.Attempt, error) {
+	attempts := make(map[string][]model.Attempt)
+	if len(jobIDs) == 0 {
+		return attempts, nil
+	}
+	args := make([]any, len(jobIDs))
+	for i, id := range jobIDs {
+		args[i] = id
+	}
+	rows, err := s.Db.Query(`select `+attemptColumns+` from job_attempts
+		where job_id in (?`+strings.Repeat(",?", len(args)-1)+`) order by job_id, attempt, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a, err := scanAttempt(rows)
+		if err != nil {
+			return nil, err
+		}
+		attempts[a.JobID] = append(attempts[a.JobID], *a)
+	}
+	return attempts, rows.Err()
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"encoding/json"
+	"queueCtl/internal/model"
+	"slices"
+	"strings"
+	"time"
+)
+
+// createAuditTable creates the audit log. The triggers keep it append-only
+// for every client of the file, not just this package.
+const createAuditTable = `create table if not exists audit_log(
+		id integer primary key autoincrement,
+		at DATETIME not null,
+		user text not null,
+		host text not null,
+		pid integer not null default 0,
+		source text not null,
+		action text not null,
+		args text not null default '[]',
+		outcome text not null,
+		error text
+	);
+	create index if not exists audit_log_at on audit_log(at);
+	create index if not exists audit_log_action on audit_log(action);
+	create trigger if not exists audit_log_no_update before update on audit_log
+	begin select raise(abort, 'the audit log is append-only'); end;
+	create trigger if not exists audit_log_no_delete before delete on audit_log
+	begin select raise(abort, 'the audit log is append-only'); end;`
+
+func (s *Store) initAudit() error {
+	_, err := s.Db.Exec(createAuditTable)
+	return err
+}
+
+// RecordAudit appends e to the audit log and sets its ID.
+func (s *Store) RecordAudit(e *model.AuditEntry) error {
+	args, err := json.Marshal(e.Args)
+	if err != nil {
+		return err
+	}
+	res, err := s.Db.Exec(`insert into audit_log (at, user, host, pid, source, action, args, outcome, error)
+		values (?,?,?,?,?,?,?,?,?)`,
+		e.At, e.User, e.Host, e.PID, e.Source, e.Action, string(args), e.Outcome, nullString(e.Error))
+	if err != nil {
+		return err
+	}
+	e.ID, err = res.LastInsertId()
+	return err
+}
+
+// AuditFilter selects audit entries. Zero fields match everything.
+type AuditFilter struct {
+	// Action matches the action exactly, or every action under it: "dlq"
+	// matches "dlq retry" and "dlq purge".
+	Action  string
+	User    string
+	Host    string
+	Source  string
+	Outcome string
+	Since   time.Time
+	Until   time.Time
+	// Limit caps the number of entries returned, keeping the most recent;
+	// 0 means no cap.
+	Limit int
+}
+
+// ListAudit returns the audit entries matching filter, oldest first.
+func (s *Store) ListAudit(filter AuditFilter) ([]model.AuditEntry, error) {
+	where := []string{"1 = 1"}
+	var args []any
+	if filter.Action != "" {
+		where = append(where, "(action = ? or substr(action, 1, length(?)) = ?)")
+		args = append(args, filter.Action, filter.Action+" ", filter.Action+" ")
+	}
+	for _, f := range []struct {
+		column string
+		value  string
+	}{
+		{"user", filter.User},
+		{"host", filter.Host},
+		{"source", filter.Source},
+		{"outcome", filter.Outcome},
+	} {
+		if f.value != "" {
+			where = append(where, f.column+" = ?")
+			args = append(args, f.value)
+		}
+	}
+	if !filter.Since.IsZero() {
+		where = append(where, "at >= ?")
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		where = append(where, "at < ?")
+		args = append(args, filter.Until)
+	}
+	query := `select id, at, user, host, pid, source, action, args, outcome, coalesce(error, '')
+		from audit_log where ` + strings.Join(where, " and ") + ` order by id desc`
+	if filter.Limit > 0 {
+		query += ` limit ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []model.AuditEntry{}
+	for rows.Next() {
+		var e model.AuditEntry
+		var entryArgs string
+		if err := rows.Scan(&e.ID, &e.At, &e.User, &e.Host, &e.PID, &e.Source, &e.Action, &entryArgs, &e.Outcome, &e.Error); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(entryArgs), &e.Args); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	slices.Reverse(entries)
+	return entries, rows.Err()
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"queueCtl/internal/model"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func auditEntry(action, outcome string, args ...string) *model.AuditEntry {
+	return &model.AuditEntry{At: time.Now(), User: "ops", Host: "box", PID: 42, Source: model.AuditSourceCLI,
+		Action: action, Args: args, Outcome: outcome}
+}
+
+func TestAuditLogIsAppendOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.db")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Db.Close()
+	if err := s.RecordAudit(auditEntry("config set", model.AuditOK, "max-retries", "5")); err != nil {
+		t.Fatal(err)
+	}
+
+	// The triggers hold for any client of the file, not just the store.
+	other, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	for _, db := range []*sql.DB{s.Db, other} {
+		for _, statement := range []string{
+			`update audit_log set outcome = 'error'`,
+			`delete from audit_log`,
+		} {
+			_, err := db.Exec(statement)
+			if err == nil || !strings.Contains(err.Error(), "append-only") {
+				t.Errorf("%s: error = %v, want the append-only abort", statement, err)
+			}
+		}
+	}
+	entries, err := s.ListAudit(AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Outcome != model.AuditOK {
+		t.Errorf("entries = %+v", entries)
+	}
+}
+
+func TestListAudit(t *testing.T) {
+	s := newTestStore(t)
+	for _, e := range []*model.AuditEntry{
+		auditEntry("dlq retry", model.AuditOK, "--all=true"),
+		auditEntry("dlq purge", model.AuditError),
+		auditEntry("dlqx", model.AuditOK),
+		auditEntry("config set", model.AuditOK, "max-retries", "5"),
+		auditEntry("dlq retry", model.AuditOK, "job-1"),
+	} {
+		if err := s.RecordAudit(e); err != nil {
+			t.Fatal(err)
+		}
+		if e.ID == 0 {
+			t.Fatal("RecordAudit left the ID unset")
+		}
+	}
+	api := &model.AuditEntry{At: time.Now(), User: "root", Host: "box", Source: model.AuditSourceAPI,
+		Action: "enqueue", Outcome: model.AuditOK}
+	if err := s.RecordAudit(api); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []string
+	}{
+		{"all, oldest first", AuditFilter{}, []string{"dlq retry", "dlq purge", "dlqx", "config set", "dlq retry", "enqueue"}},
+		{"action and the actions under it", AuditFilter{Action: "dlq"}, []string{"dlq retry", "dlq purge", "dlq retry"}},
+		{"exact action", AuditFilter{Action: "dlq retry"}, []string{"dlq retry", "dlq retry"}},
+		{"outcome", AuditFilter{Outcome: model.AuditError}, []string{"dlq purge"}},
+		{"user and source", AuditFilter{User: "root", Source: model.AuditSourceAPI}, []string{"enqueue"}},
+		{"limit keeps the most recent", AuditFilter{Limit: 2}, []string{"dlq retry", "enqueue"}},
+		{"until", AuditFilter{Until: time.Now().Add(-time.Hour)}, nil},
+	}
+	for _, tt := range tests {
+		entries, err := s.ListAudit(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.Action)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	entries, err := s.ListAudit(AuditFilter{Action: "config set"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := entries[0]; !slices.Equal(e.Args, []string{"max-retries", "5"}) || e.User != "ops" || e.PID != 42 {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestRestoreKeepsAuditLog(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.RecordAudit(auditEntry("enqueue", model.AuditOK, "a")); err != nil {
+		t.Fatal(err)
+	}
+	backup := filepath.Join(t.TempDir(), "backup.db")
+	if err := s.Backup(backup); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordAudit(auditEntry("config set", model.AuditOK)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Restore(backup); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.ListAudit(AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[1].Action != "config set" {
+		t.Errorf("entries after restore = %+v, want both", entries)
+	}
+	if _, err := s.Db.Exec(`delete from audit_log`); err == nil {
+		t.Error("the restored audit log is no longer append-only")
+	}
+}
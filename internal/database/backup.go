@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"queueCtl/internal/model"
+	"time"
+)
+
+// backupStep is how many pages one backup step copies. Between steps the
+// source is unlocked, so workers keep running during a long backup.
+const backupStep = 256
+
+// Backup writes a consistent snapshot of the database to path using
+// SQLite's online backup API. The snapshot is built in a temporary file,
+// checked for integrity and then renamed into place, so path never holds a
+// partial copy. The result is a self-contained file without a WAL.
+func (s *Store) Backup(path string) error {
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	defer os.Remove(tmp)
+
+	dest, err := sql.Open("sqlite3", tmp)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	if err := copyDatabase(dest, s.Db); err != nil {
+		return err
+	}
+	// The copy inherits WAL mode from the source; switch it back so the
+	// backup is a single file.
+	if _, err := dest.Exec(`pragma journal_mode=DELETE`); err != nil {
+		return err
+	}
+	if err := checkIntegrity(dest); err != nil {
+		return err
+	}
+	if err := dest.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// VerifyBackup checks that path is an intact queue database.
+func VerifyBackup(path string) error {
+	src, err := openBackup(path)
+	if err != nil {
+		return err
+	}
+	return src.Close()
+}
+
+// openBackup opens the backup at path read-only after checking it.
+func openBackup(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	src, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIntegrity(src); err != nil {
+		src.Close()
+		return nil, fmt.Errorf("backup %s: %w", path, err)
+	}
+	var tables int
+	if err := src.QueryRow(`select count(*) from sqlite_master where type = 'table' and name = 'jobs'`).Scan(&tables); err != nil {
+		src.Close()
+		return nil, err
+	}
+	if tables == 0 {
+		src.Close()
+		return nil, fmt.Errorf("backup %s: not a queue database (no jobs table)", path)
+	}
+	return src, nil
+}
+
+// Restore replaces the database's contents with the backup at path. The
+// backup is checked for integrity first. The audit log is the exception: it
+// is append-only, so the current log is kept rather than rewound to the
+// backup's. Jobs that were processing when the backup was taken have no
+// worker any more, so they are returned to pending without the attempt
+// counting against them, as an interrupted worker would; their IDs are
+// returned.
+func (s *Store) Restore(path string) ([]string, error) {
+	src, err := openBackup(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	audit, err := s.auditRows()
+	if err != nil {
+		return nil, fmt.Errorf("reading the audit log: %w", err)
+	}
+	if err := copyDatabase(s.Db, src); err != nil {
+		return nil, err
+	}
+	if err := s.replaceAudit(audit); err != nil {
+		return nil, fmt.Errorf("keeping the audit log: %w", err)
+	}
+	// The backup may predate columns added since.
+	if err := s.Init(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	rows, err := s.Db.Query(`update jobs set state = ?, attempts = max(attempts - 1, 0), worker_id = null,
+		failure_reason = ?, next_run_at = ?, updated_at = ?
+		where state = ? returning id`,
+		model.StatePending, model.FailureInterrupted, now, now, model.StateProcessing)
+	if err != nil {
+		return nil, err
+	}
+	return collectIDs(rows)
+}
+
+// auditRows returns every row of the audit log, as stored.
+func (s *Store) auditRows() ([][]any, error) {
+	rows, err := s.Db.Query(`select id, at, user, host, pid, source, action, args, outcome, error from audit_log order by id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var all [][]any
+	for rows.Next() {
+		row := make([]any, 10)
+		dest := make([]any, len(row))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		all = append(all, row)
+	}
+	return all, rows.Err()
+}
+
+// replaceAudit swaps the audit log for rows. Dropping the table bypasses
+// its append-only triggers, which are created again with it.
+func (s *Store) replaceAudit(rows [][]any) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`drop table if exists audit_log`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(createAuditTable); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err := tx.Exec(`insert into audit_log (id, at, user, host, pid, source, action, args, outcome, error)
+			values (?,?,?,?,?,?,?,?,?,?)`, row...); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// copyDatabase copies the main database of src over dest with the online
+// backup API.
+func copyDatabase(dest, src *sql.DB) error {
+	ctx := context.Background()
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(d any) error {
+		return srcConn.Raw(func(s any) error {
+			destRaw, ok1 := sqliteConn(d)
+			srcRaw, ok2 := sqliteConn(s)
+			if !ok1 || !ok2 {
+				return errors.New("backup needs sqlite3 connections")
+			}
+			backup, err := destRaw.Backup("main", srcRaw, "main")
+			if err != nil {
+				return err
+			}
+			for {
+				done, err := backup.Step(backupStep)
+				if err != nil {
+					backup.Finish()
+					return err
+				}
+				if done {
+					return backup.Finish()
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	})
+}
+
+// checkIntegrity runs SQLite's integrity check on db.
+func checkIntegrity(db *sql.DB) error {
+	var result string
+	if err := db.QueryRow(`pragma integrity_check`).Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	return nil
+}
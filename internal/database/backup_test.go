@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"queueCtl/internal/model"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "running"}, &model.Job{ID: "done"}, &model.Job{ID: "later"})
+	claim(t, s, "w1")
+	claim(t, s, "w1")
+	finish(t, s, "done", model.StateCompleted)
+
+	path := filepath.Join(t.TempDir(), "backup.db")
+	if err := s.Backup(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyBackup(path); err != nil {
+		t.Fatalf("VerifyBackup: %v", err)
+	}
+	// The backup is one self-contained file.
+	for _, suffix := range []string{".tmp", "-wal", "-journal"} {
+		if _, err := os.Stat(path + suffix); err == nil {
+			t.Errorf("backup left %s behind", path+suffix)
+		}
+	}
+
+	enqueue(t, s, &model.Job{ID: "after-backup"})
+	if _, err := s.PurgeJobs(JobFilter{IDPrefix: "later"}); err != nil {
+		t.Fatal(err)
+	}
+	interrupted, err := s.Restore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(interrupted, []string{"running"}) {
+		t.Errorf("Restore returned %v, want the job that was running", interrupted)
+	}
+
+	page, err := s.ListJobs(JobFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, j := range page.Jobs {
+		got = append(got, j.ID+" "+j.State)
+	}
+	if want := []string{"running pending", "done completed", "later pending"}; !slices.Equal(got, want) {
+		t.Errorf("restored jobs %v, want %v", got, want)
+	}
+	job, err := s.GetJob("running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Attempts != 0 || job.WorkerID != "" || job.FailureReason != model.FailureInterrupted {
+		t.Errorf("interrupted job = %+v", job)
+	}
+	if got := claim(t, s, "w2"); got != "running" {
+		t.Errorf("claimed %q after the restore, want running", got)
+	}
+}
+
+func TestVerifyBackupRejects(t *testing.T) {
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte(strings.Repeat("not a database ", 100)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "other.db")
+	db, err := sql.Open("sqlite3", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`create table notes (body text)`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	for name, path := range map[string]string{
+		"missing file": filepath.Join(dir, "missing.db"),
+		"not sqlite":   garbage,
+		"not a queue":  other,
+	} {
+		if err := VerifyBackup(path); err == nil {
+			t.Errorf("%s: VerifyBackup accepted it", name)
+		}
+	}
+
+	// A rejected backup leaves the database alone.
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "a"})
+	if _, err := s.Restore(other); err == nil {
+		t.Fatal("restored from a database without jobs")
+	}
+	if got := state(t, s, "a"); got != model.StatePending {
+		t.Errorf("a is %s after a failed restore", got)
+	}
+}
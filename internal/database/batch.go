@@ -0,0 +1,288 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"queueCtl/internal/model"
+	"strconv"
+	"time"
+)
+
+func (s *Store) initBatches() error {
+	createBatchTable := `create table if not exists batches(
+		id text primary key,
+		state text not null default 'running',
+		policy text not null,
+		total integer not null,
+		callback text,
+		created_at DATETIME not null,
+		finished_at DATETIME
+	);`
+	if _, err := s.Db.Exec(createBatchTable); err != nil {
+		return err
+	}
+	var counted bool
+	if err := s.Db.QueryRow(`select count(*) > 0 from pragma_table_info('batches') where name = 'completed'`).Scan(&counted); err != nil {
+		return err
+	}
+	if err := s.migrate("batches", batchMigrations); err != nil {
+		return err
+	}
+	if !counted {
+		// Count the members of batches created before the counts were
+		// kept; any that were deleted since are lost.
+		_, err := s.Db.Exec(`update batches set
+			completed = (select count(*) from jobs where batch_id = batches.id and state = ?),
+			dead = (select count(*) from jobs where batch_id = batches.id and state = ?),
+			canceled = (select count(*) from jobs where batch_id = batches.id and state = ?),
+			expired = (select count(*) from jobs where batch_id = batches.id and state = ?)`,
+			model.StateCompleted, model.StateDead, model.StateCanceled, model.StateExpired)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Members are counted as they reach, or leave, a final state, so the
+	// counts still add up after finished members are archived, purged or
+	// removed by the janitor.
+	countMembers := `create trigger if not exists jobs_batch_counts after update of state on jobs
+	when new.batch_id is not null and new.state != old.state
+	begin
+		update batches set
+			completed = completed + (new.state = 'completed') - (old.state = 'completed'),
+			dead = dead + (new.state = 'dead') - (old.state = 'dead'),
+			canceled = canceled + (new.state = 'canceled') - (old.state = 'canceled'),
+			expired = expired + (new.state = 'expired') - (old.state = 'expired')
+		where id = new.batch_id;
+	end;`
+	_, err := s.Db.Exec(countMembers)
+	return err
+}
+
+// batchMigrations are applied to existing databases on startup.
+var batchMigrations = []columnMigration{
+	{"completed", "integer not null default 0"},
+	{"dead", "integer not null default 0"},
+	{"canceled", "integer not null default 0"},
+	{"expired", "integer not null default 0"},
+}
+
+// finishedCounts is the number of a batch's members in each final state.
+type finishedCounts struct {
+	completed, dead, canceled, expired int
+}
+
+func (c finishedCounts) total() int {
+	return c.completed + c.dead + c.canceled + c.expired
+}
+
+// CreateBatch inserts a batch and all of its member jobs in one
+// transaction. The members must already be prepared; their BatchID is set
+// to the batch's. The callback, if any, is stored as a template with its ID
+// and max retries filled in, and is prepared and enqueued when the batch
+// finishes.
+func (s *Store) CreateBatch(batch *model.Batch, jobs []*model.Job) error {
+	var callback sql.NullString
+	if batch.Callback != nil {
+		data, err := json.Marshal(batch.Callback)
+		if err != nil {
+			return err
+		}
+		callback = sql.NullString{String: string(data), Valid: true}
+	}
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`insert into batches (id, state, policy, total, callback, created_at) values (?,?,?,?,?,?)`,
+		batch.ID, model.BatchRunning, batch.Policy, len(jobs), callback, batch.CreatedAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%w: batch %s", ErrDuplicate, batch.ID)
+		}
+		return err
+	}
+	for _, job := range jobs {
+		job.BatchID = batch.ID
+		if _, err := insertNewJob(tx, job, false); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	batch.State = model.BatchRunning
+	batch.Total = len(jobs)
+	return nil
+}
+
+// batchColumns are the columns scanBatch reads, in its order.
+const batchColumns = `id, state, policy, total, callback, created_at, finished_at, completed, dead, canceled, expired`
+
+// scanBatch reads a batch row selected with batchColumns. Counts holds the
+// members counted on the batch, those in a final state.
+func scanBatch(scan func(dest ...any) error) (*model.Batch, error) {
+	var batch model.Batch
+	var callback sql.NullString
+	var finishedAt sql.NullTime
+	var finished finishedCounts
+	err := scan(&batch.ID, &batch.State, &batch.Policy, &batch.Total, &callback, &batch.CreatedAt, &finishedAt,
+		&finished.completed, &finished.dead, &finished.canceled, &finished.expired)
+	if err != nil {
+		return nil, err
+	}
+	if finishedAt.Valid {
+		batch.FinishedAt = finishedAt.Time
+	}
+	if callback.Valid && callback.String != "" {
+		batch.Callback = &model.Job{}
+		if err := json.Unmarshal([]byte(callback.String), batch.Callback); err != nil {
+			return nil, err
+		}
+	}
+
+	batch.Counts = make(map[string]int)
+	for state, n := range map[string]int{
+		model.StateCompleted: finished.completed,
+		model.StateDead:      finished.dead,
+		model.StateCanceled:  finished.canceled,
+		model.StateExpired:   finished.expired,
+	} {
+		if n > 0 {
+			batch.Counts[state] = n
+		}
+	}
+	return &batch, nil
+}
+
+// GetBatch returns a batch with the number of its members in each state.
+// Members in a final state are counted on the batch, so they stay counted
+// once the jobs themselves are gone.
+func (s *Store) GetBatch(id string) (*model.Batch, error) {
+	batch, err := scanBatch(s.Db.QueryRow(`select `+batchColumns+` from batches where id = ?`, id).Scan)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: batch %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.Db.Query(`select state, count(*) from jobs where batch_id = ? and state in (?, ?, ?) group by state`,
+		id, model.StatePending, model.StateProcessing, model.StateFailed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var state string
+		var count int
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, err
+		}
+		batch.Counts[state] = count
+	}
+	return batch, rows.Err()
+}
+
+// ListBatches returns every batch, oldest first, as export writes them:
+// Counts holds only the members in a final state, which are counted on the
+// batch. The others are counted from the jobs themselves.
+func (s *Store) ListBatches() ([]model.Batch, error) {
+	rows, err := s.Db.Query(`select ` + batchColumns + ` from batches order by created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	batches := []model.Batch{}
+	for rows.Next() {
+		batch, err := scanBatch(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		batches = append(batches, *batch)
+	}
+	return batches, rows.Err()
+}
+
+// insertBatch inserts a batch as ListBatches returned it, unless its ID is
+// taken. It reports whether the batch was inserted.
+func insertBatch(db execer, batch *model.Batch) (bool, error) {
+	var callback sql.NullString
+	if batch.Callback != nil {
+		data, err := json.Marshal(batch.Callback)
+		if err != nil {
+			return false, err
+		}
+		callback = sql.NullString{String: string(data), Valid: true}
+	}
+	var finishedAt *time.Time
+	if !batch.FinishedAt.IsZero() {
+		finishedAt = &batch.FinishedAt
+	}
+	res, err := db.Exec(`insert or ignore into batches (id, state, policy, total, callback, created_at, finished_at,
+		completed, dead, canceled, expired) values (?,?,?,?,?,?,?,?,?,?,?)`,
+		batch.ID, batch.State, batch.Policy, batch.Total, callback, batch.CreatedAt, nullTime(finishedAt),
+		batch.Counts[model.StateCompleted], batch.Counts[model.StateDead], batch.Counts[model.StateCanceled], batch.Counts[model.StateExpired])
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// finishBatch finishes the batch once none of its members can run again:
+// it records the outcome under the batch's policy and enqueues the
+// callback, prepared at that moment so that its ttl runs from then. It runs
+// inside the transaction that moved a member to a final state, and the
+// state guard on the batch makes sure only one such transaction fires the
+// callback.
+func finishBatch(tx *sql.Tx, batchID string, now time.Time) error {
+	var policy string
+	var callback sql.NullString
+	var total int
+	var finished finishedCounts
+	err := tx.QueryRow(`select policy, callback, total, completed, dead, canceled, expired from batches where id = ? and state = ?`,
+		batchID, model.BatchRunning).
+		Scan(&policy, &callback, &total, &finished.completed, &finished.dead, &finished.canceled, &finished.expired)
+	if err == sql.ErrNoRows {
+		return nil // already finished, or not a batch we know
+	}
+	if err != nil || finished.total() < total {
+		return err
+	}
+
+	state := model.BatchSucceeded
+	if finished.dead+finished.canceled+finished.expired > 0 && policy != model.PolicyIgnoreDead {
+		state = model.BatchFailed
+	}
+	if _, err := tx.Exec(`update batches set state = ?, finished_at = ? where id = ? and state = ?`,
+		state, now, batchID, model.BatchRunning); err != nil {
+		return err
+	}
+	if !callback.Valid || callback.String == "" {
+		return nil
+	}
+
+	var job model.Job
+	if err := json.Unmarshal([]byte(callback.String), &job); err != nil {
+		return err
+	}
+	// The callback's max retries were defaulted when the batch was created.
+	if err := job.Prepare(now, job.MaxRetries); err != nil {
+		return fmt.Errorf("callback: %w", err)
+	}
+	if job.Env == nil {
+		job.Env = make(map[string]string)
+	}
+	job.Env["QUEUECTL_BATCH_ID"] = batchID
+	job.Env["QUEUECTL_BATCH_STATE"] = state
+	job.Env["QUEUECTL_BATCH_COMPLETED"] = strconv.Itoa(finished.completed)
+	job.Env["QUEUECTL_BATCH_DEAD"] = strconv.Itoa(finished.dead)
+	job.Env["QUEUECTL_BATCH_CANCELED"] = strconv.Itoa(finished.canceled)
+	job.Env["QUEUECTL_BATCH_EXPIRED"] = strconv.Itoa(finished.expired)
+	_, err = insertNewJob(tx, &job, true)
+	return err
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"maps"
+	"queueCtl/internal/model"
+	"testing"
+	"time"
+)
+
+// createBatch creates a batch of shell jobs with the given IDs and a
+// callback with the ID batchID.callback.
+func createBatch(t *testing.T, s *Store, batchID, policy string, ids ...string) {
+	t.Helper()
+	now := time.Now().Add(-time.Hour)
+	jobs := make([]*model.Job, len(ids))
+	for i, id := range ids {
+		jobs[i] = &model.Job{ID: id, Command: "true"}
+		if err := jobs[i].Prepare(now.Add(time.Duration(i)*time.Second), 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	callback := &model.Job{ID: batchID + ".callback", Command: "echo done", MaxRetries: 3, Env: map[string]string{"KEEP": "1"}}
+	batch := &model.Batch{ID: batchID, Policy: policy, CreatedAt: now, Callback: callback}
+	if err := s.CreateBatch(batch, jobs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getBatch(t *testing.T, s *Store, id string) *model.Batch {
+	t.Helper()
+	batch, err := s.GetBatch(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batch
+}
+
+func TestBatchSucceeds(t *testing.T) {
+	s := newTestStore(t)
+	createBatch(t, s, "b", model.PolicyFailOnDead, "b-1", "b-2", "b-3")
+	if batch := getBatch(t, s, "b"); batch.State != model.BatchRunning || batch.Total != 3 ||
+		!maps.Equal(batch.Counts, map[string]int{model.StatePending: 3}) {
+		t.Fatalf("new batch = %+v", batch)
+	}
+
+	claimAll(t, s, "w1")
+	finish(t, s, "b-1", model.StateCompleted)
+	finish(t, s, "b-2", model.StateCompleted)
+	batch := getBatch(t, s, "b")
+	if batch.State != model.BatchRunning || !maps.Equal(batch.Counts, map[string]int{model.StateCompleted: 2, model.StateProcessing: 1}) {
+		t.Fatalf("batch with one member running = %+v", batch)
+	}
+	if _, err := s.GetJob("b.callback"); err == nil {
+		t.Fatal("callback enqueued before the batch finished")
+	}
+
+	finish(t, s, "b-3", model.StateCompleted)
+	batch = getBatch(t, s, "b")
+	if batch.State != model.BatchSucceeded || batch.FinishedAt.IsZero() {
+		t.Errorf("finished batch = %+v, want succeeded", batch)
+	}
+	callback, err := s.GetJob("b.callback")
+	if err != nil {
+		t.Fatalf("callback not enqueued: %v", err)
+	}
+	want := map[string]string{
+		"KEEP":                     "1",
+		"QUEUECTL_BATCH_ID":        "b",
+		"QUEUECTL_BATCH_STATE":     model.BatchSucceeded,
+		"QUEUECTL_BATCH_COMPLETED": "3",
+		"QUEUECTL_BATCH_DEAD":      "0",
+		"QUEUECTL_BATCH_CANCELED":  "0",
+		"QUEUECTL_BATCH_EXPIRED":   "0",
+	}
+	if callback.State != model.StatePending || callback.Command != "echo done" || !maps.Equal(callback.Env, want) {
+		t.Errorf("callback = %+v", callback)
+	}
+	if callback.BatchID != "" {
+		t.Errorf("callback is a member of batch %q", callback.BatchID)
+	}
+}
+
+func TestBatchPolicies(t *testing.T) {
+	for policy, want := range map[string]string{
+		model.PolicyFailOnDead: model.BatchFailed,
+		model.PolicyIgnoreDead: model.BatchSucceeded,
+	} {
+		t.Run(policy, func(t *testing.T) {
+			s := newTestStore(t)
+			createBatch(t, s, "b", policy, "done", "dead", "canceled", "expired")
+			if _, err := s.Db.Exec(`update jobs set expires_at = ? where id = 'expired'`, time.Now().Add(-time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			claimAll(t, s, "w1")
+			finish(t, s, "done", model.StateCompleted)
+			finish(t, s, "dead", model.StateDead)
+			if err := s.CancelJob("canceled"); err != nil {
+				t.Fatal(err)
+			}
+			if getBatch(t, s, "b").State != model.BatchRunning {
+				t.Fatal("batch finished with a member left")
+			}
+			if _, err := s.ExpireJobs(time.Now(), nil); err != nil {
+				t.Fatal(err)
+			}
+
+			batch := getBatch(t, s, "b")
+			counts := map[string]int{model.StateCompleted: 1, model.StateDead: 1, model.StateCanceled: 1, model.StateExpired: 1}
+			if batch.State != want || !maps.Equal(batch.Counts, counts) {
+				t.Errorf("batch = %s %v, want %s %v", batch.State, batch.Counts, want, counts)
+			}
+			callback, err := s.GetJob("b.callback")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if callback.Env["QUEUECTL_BATCH_STATE"] != want || callback.Env["QUEUECTL_BATCH_DEAD"] != "1" {
+				t.Errorf("callback env = %v", callback.Env)
+			}
+		})
+	}
+}
+
+func TestBatchCountsFollowMembers(t *testing.T) {
+	s := newTestStore(t)
+	createBatch(t, s, "b", model.PolicyFailOnDead, "b-1", "b-2")
+	claimAll(t, s, "w1")
+	finish(t, s, "b-1", model.StateDead)
+
+	// A member retried from the DLQ is no longer counted as dead.
+	if err := s.RetryDeadJob("b-1"); err != nil {
+		t.Fatal(err)
+	}
+	if batch := getBatch(t, s, "b"); !maps.Equal(batch.Counts, map[string]int{model.StatePending: 1, model.StateProcessing: 1}) {
+		t.Errorf("counts after a retry = %v", batch.Counts)
+	}
+	claim(t, s, "w1")
+	finish(t, s, "b-1", model.StateCompleted)
+	finish(t, s, "b-2", model.StateCompleted)
+
+	// Finished members stay counted once they are purged.
+	if _, err := s.PurgeJobs(JobFilter{IDPrefix: "b-"}); err != nil {
+		t.Fatal(err)
+	}
+	batch := getBatch(t, s, "b")
+	if batch.State != model.BatchSucceeded || !maps.Equal(batch.Counts, map[string]int{model.StateCompleted: 2}) {
+		t.Errorf("purged batch = %s %v", batch.State, batch.Counts)
+	}
+}
+
+func TestBatchFinishesOnce(t *testing.T) {
+	s := newTestStore(t)
+	createBatch(t, s, "b", model.PolicyFailOnDead, "b-1")
+	claim(t, s, "w1")
+	finish(t, s, "b-1", model.StateDead)
+	first := getBatch(t, s, "b")
+	if first.State != model.BatchFailed {
+		t.Fatalf("batch is %s, want failed", first.State)
+	}
+
+	// Retrying the member and finishing it again leaves the finished
+	// batch, and its single callback, alone.
+	if err := s.RetryDeadJob("b-1"); err != nil {
+		t.Fatal(err)
+	}
+	claim(t, s, "w1")
+	finish(t, s, "b-1", model.StateCompleted)
+	again := getBatch(t, s, "b")
+	if again.State != model.BatchFailed || !again.FinishedAt.Equal(first.FinishedAt) {
+		t.Errorf("batch refinished: %+v", again)
+	}
+	events, err := s.ListEvents(EventFilter{Types: []string{model.EventEnqueued}, JobID: "b.callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("callback enqueued %d times", len(events))
+	}
+}
+
+func TestCreateBatchIsAtomic(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "taken"})
+	jobs := []*model.Job{{ID: "new", Command: "true"}, {ID: "taken", Command: "true"}}
+	for _, j := range jobs {
+		if err := j.Prepare(time.Now(), 3); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.CreateBatch(&model.Batch{ID: "b", Policy: model.PolicyFailOnDead, CreatedAt: time.Now()}, jobs); err == nil {
+		t.Fatal("batch with a taken job ID created")
+	}
+	if _, err := s.GetBatch("b"); err == nil {
+		t.Error("batch left behind")
+	}
+	if _, err := s.GetJob("new"); err == nil {
+		t.Error("member left behind")
+	}
+}
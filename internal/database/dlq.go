@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"queueCtl/internal/model"
+	"strings"
+	"time"
+)
+
+// retrySet is the SET clause that gives a dead job a fresh start: pending,
+// no attempts and no failure recorded. An expiry or deadline that has
+// already passed is cleared too, since it would send the job straight back
+// to the DLQ; one still ahead is kept.
+func retrySet(now time.Time) (string, []any) {
+	return `state = ?, attempts = 0, next_run_at = ?, updated_at = ?, exit_code = 0, failure_reason = null,
+		expires_at = case when expires_at <= ? then null else expires_at end,
+		deadline = case when deadline <= ? then null else deadline end`,
+		[]any{model.StatePending, now, now, now, now}
+}
+
+// RetryDeadJobs moves every dead job matching filter back to pending, as
+// retrySet describes, and returns their IDs. The filter's states are
+// ignored.
+func (s *Store) RetryDeadJobs(filter JobFilter) ([]string, error) {
+	filter.States = []string{model.StateDead}
+	where, args := filterWhere(filter)
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	set, setArgs := retrySet(now)
+	statement := `update jobs set ` + set + `
+		where ` + strings.Join(where, " and ") + ` returning id, queue`
+	rows, err := tx.Query(statement, append(setArgs, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	var events []*model.Event
+	ids := []string{}
+	for rows.Next() {
+		ev := &model.Event{At: now, Type: model.EventRequeued, State: model.StatePending}
+		if err := rows.Scan(&ev.JobID, &ev.Queue); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, ev.JobID)
+		events = append(events, ev)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, ev := range events {
+		if err := recordEvent(tx, ev); err != nil {
+			return nil, err
+		}
+	}
+	return ids, tx.Commit()
+}
+
+// PurgeJobs deletes every job matching filter together with its attempt
+// history, in one transaction, and returns the deleted IDs. A filter with
+// no conditions deletes every job. A non-zero filter.Limit deletes at most
+// that many jobs, least recently updated first, so large purges can be
+// split into short transactions.
+func (s *Store) PurgeJobs(filter JobFilter) ([]string, error) {
+	where, args := filterWhere(filter)
+	cond := ""
+	if len(where) > 0 {
+		cond = ` where ` + strings.Join(where, " and ")
+	}
+	if filter.Limit > 0 {
+		cond = ` where id in (select id from jobs` + cond + ` order by updated_at, id limit ?)`
+		args = append(args, filter.Limit)
+	}
+
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`delete from job_attempts where job_id in (select id from jobs`+cond+`)`, args...); err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(`delete from jobs`+cond+` returning id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := collectIDs(rows)
+	if err != nil {
+		return nil, err
+	}
+	return ids, tx.Commit()
+}
+
+// idRows is the subset of *sql.Rows collectIDs needs.
+type idRows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+	Close() error
+}
+
+// collectIDs reads a single id column and closes rows.
+func collectIDs(rows idRows) ([]string, error) {
+	defer rows.Close()
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
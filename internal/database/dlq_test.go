@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"queueCtl/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRetryDeadJobs(t *testing.T) {
+	s := newTestStore(t)
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	enqueue(t, s,
+		&model.Job{ID: "mail-1", Queue: "mail", ExpiresAt: &future, Deadline: &future},
+		&model.Job{ID: "mail-2", Queue: "mail"},
+		&model.Job{ID: "other", Queue: "other"},
+	)
+	claimAll(t, s, "w1")
+	for _, id := range []string{"mail-1", "mail-2", "other"} {
+		finish(t, s, id, model.StateDead)
+	}
+	enqueue(t, s, &model.Job{ID: "mail-pending", Queue: "mail"})
+	if _, err := s.Db.Exec(`update jobs set exit_code = 2, failure_reason = ?, expires_at = ?, deadline = ? where id = 'mail-2'`,
+		model.FailureExitCode, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := s.RetryDeadJobs(JobFilter{Queue: "mail", States: []string{model.StatePending}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"mail-1", "mail-2"}) {
+		t.Fatalf("retried %v, want the dead jobs of the mail queue", ids)
+	}
+	if got := state(t, s, "other"); got != model.StateDead {
+		t.Errorf("other is %s, want it left dead", got)
+	}
+
+	// A retried job starts over, without the expiry and deadline that
+	// have already passed.
+	kept, err := s.GetJob("mail-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.ExpiresAt == nil || kept.Deadline == nil {
+		t.Errorf("mail-1 lost its future expiry or deadline: %+v", kept)
+	}
+	job, err := s.GetJob("mail-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != model.StatePending || job.Attempts != 0 || job.ExitCode != 0 || job.FailureReason != "" ||
+		job.ExpiresAt != nil || job.Deadline != nil {
+		t.Errorf("retried job = %+v", job)
+	}
+	events, err := s.ListEvents(EventFilter{Types: []string{model.EventRequeued}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Queue != "mail" || events[0].State != model.StatePending {
+		t.Errorf("requeued events = %+v", events)
+	}
+
+	if got := claimAll(t, s, "w1"); len(got) != 3 {
+		t.Errorf("claimed %v after the retry, want both retried jobs and mail-pending", got)
+	}
+	if ids, err := s.RetryDeadJobs(JobFilter{}); err != nil || len(ids) != 1 || ids[0] != "other" {
+		t.Errorf("retrying every dead job = %v, %v", ids, err)
+	}
+}
+
+func TestPurgeJobs(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "a"}, &model.Job{ID: "b"}, &model.Job{ID: "c"}, &model.Job{ID: "keep", Queue: "q"})
+	for i, id := range []string{"c", "a", "b"} {
+		if _, err := s.Db.Exec(`update jobs set updated_at = ? where id = ?`, time.Now().Add(time.Duration(i-10)*time.Minute), id); err != nil {
+			t.Fatal(err)
+		}
+		a := &model.Attempt{JobID: id, Attempt: 1, WorkerID: "w1", StartedAt: time.Now(), FinishedAt: time.Now()}
+		if err := s.RecordAttempt(a, "default"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A limit purges the least recently updated jobs first.
+	ids, err := s.PurgeJobs(JobFilter{Queue: "default", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []string{"a", "c"}) {
+		t.Errorf("purged %v, want the two least recently updated jobs", ids)
+	}
+	if ids, err := s.PurgeJobs(JobFilter{Queue: "default"}); err != nil || !slices.Equal(ids, []string{"b"}) {
+		t.Errorf("second purge = %v, %v, want b", ids, err)
+	}
+	if ids, err := s.PurgeJobs(JobFilter{Queue: "default"}); err != nil || len(ids) != 0 {
+		t.Errorf("purge of nothing = %v, %v", ids, err)
+	}
+
+	attempts, err := s.AttemptsFor([]string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attempts) != 0 {
+		t.Errorf("attempts left behind: %v", attempts)
+	}
+	if got := state(t, s, "keep"); got != model.StatePending {
+		t.Errorf("keep is %s", got)
+	}
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrNotFound is returned when no job has the requested ID.
+	ErrNotFound = errors.New("job not found")
+	// ErrDuplicate is returned when a job with the same ID already exists.
+	ErrDuplicate = errors.New("job already exists")
+	// ErrInvalidState is returned when a job exists but its state does not
+	// allow the requested change.
+	ErrInvalidState = errors.New("job is not in a valid state for this operation")
+	// ErrLeaseLost is returned when a worker saves the outcome of a job
+	// whose lease ran out and that another worker has since claimed.
+	ErrLeaseLost = errors.New("job lease lost to another worker")
+)
+
+// isUniqueViolation reports whether err is a primary key or unique
+// constraint failure.
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"queueCtl/internal/model"
+	"slices"
+	"strings"
+	"time"
+)
+
+// eventOutputBytes is how much of an attempt's output its output event
+// keeps.
+const eventOutputBytes = 4096
+
+func (s *Store) initEvents() error {
+	createEventTable := `create table if not exists events(
+		id integer primary key autoincrement,
+		at DATETIME not null,
+		type text not null,
+		job_id text not null,
+		queue text not null,
+		state text not null,
+		attempt integer not null default 0,
+		worker_id text,
+		exit_code integer not null default 0,
+		failure_reason text,
+		next_run_at DATETIME,
+		output text
+	);
+	create index if not exists events_at on events(at);`
+	if _, err := s.Db.Exec(createEventTable); err != nil {
+		return err
+	}
+	return s.migrate("events", eventMigrations)
+}
+
+var eventMigrations = []columnMigration{
+	{"follow_up_id", "text"},
+}
+
+// jobEvent describes job having just had an event of type typ.
+func jobEvent(typ string, job *model.Job, at time.Time) *model.Event {
+	ev := &model.Event{
+		At:            at,
+		Type:          typ,
+		JobID:         job.ID,
+		Queue:         job.Queue,
+		State:         job.State,
+		Attempt:       job.Attempts,
+		WorkerID:      job.WorkerID,
+		ExitCode:      job.ExitCode,
+		FailureReason: job.FailureReason,
+	}
+	if ev.Queue == "" {
+		ev.Queue = model.DefaultQueue
+	}
+	if typ == model.EventClaimed {
+		// The outcome of the previous attempt is not news here.
+		ev.ExitCode, ev.FailureReason = 0, ""
+	}
+	if typ == model.EventRetryScheduled {
+		next := job.NextRunAt
+		ev.NextRunAt = &next
+	}
+	return ev
+}
+
+// recordEvent appends ev to the event log. It runs inside the transaction
+// that made the change it describes.
+func recordEvent(db execer, ev *model.Event) error {
+	output := ev.Output
+	if len(output) > eventOutputBytes {
+		output = output[len(output)-eventOutputBytes:]
+	}
+	_, err := db.Exec(`insert into events (at, type, job_id, queue, state, attempt, worker_id, exit_code, failure_reason, next_run_at, output, follow_up_id)
+		values (?,?,?,?,?,?,?,?,?,?,?,?)`,
+		ev.At, ev.Type, ev.JobID, ev.Queue, ev.State, ev.Attempt, nullString(ev.WorkerID), ev.ExitCode,
+		nullString(ev.FailureReason), nullTime(ev.NextRunAt), nullString(output), nullString(ev.FollowUpID))
+	return err
+}
+
+// connExecer runs statements on a dedicated connection, for code that
+// manages its transaction by hand.
+type connExecer struct {
+	ctx  context.Context
+	conn *sql.Conn
+}
+
+func (c connExecer) Exec(query string, args ...any) (sql.Result, error) {
+	return c.conn.ExecContext(c.ctx, query, args...)
+}
+
+// EventFilter selects events. Zero fields match everything.
+type EventFilter struct {
+	// After returns only events recorded after the event with this ID.
+	After int64
+	JobID string
+	Queue string
+	Types []string
+	// Limit caps the number of events returned; 0 means no cap.
+	Limit int
+	// Newest returns the last Limit events rather than the first.
+	Newest bool
+}
+
+// ListEvents returns the events matching filter, oldest first.
+func (s *Store) ListEvents(filter EventFilter) ([]model.Event, error) {
+	where := []string{"id > ?"}
+	args := []any{filter.After}
+	if filter.JobID != "" {
+		where = append(where, "job_id = ?")
+		args = append(args, filter.JobID)
+	}
+	if filter.Queue != "" {
+		where = append(where, "queue = ?")
+		args = append(args, filter.Queue)
+	}
+	if len(filter.Types) > 0 {
+		where = append(where, "type in (?"+strings.Repeat(",?", len(filter.Types)-1)+")")
+		for _, t := range filter.Types {
+			args = append(args, t)
+		}
+	}
+	query := `select id, at, type, job_id, queue, state, attempt, coalesce(worker_id, ''), exit_code,
+			coalesce(failure_reason, ''), next_run_at, coalesce(output, ''), coalesce(follow_up_id, '')
+		from events where ` + strings.Join(where, " and ") + ` order by id`
+	if filter.Newest {
+		query += ` desc`
+	}
+	if filter.Limit > 0 {
+		query += ` limit ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.Db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := []model.Event{}
+	for rows.Next() {
+		var ev model.Event
+		var nextRunAt sql.NullTime
+		if err := rows.Scan(&ev.ID, &ev.At, &ev.Type, &ev.JobID, &ev.Queue, &ev.State, &ev.Attempt, &ev.WorkerID,
+			&ev.ExitCode, &ev.FailureReason, &nextRunAt, &ev.Output, &ev.FollowUpID); err != nil {
+			return nil, err
+		}
+		if nextRunAt.Valid {
+			ev.NextRunAt = &nextRunAt.Time
+		}
+		events = append(events, ev)
+	}
+	if filter.Newest {
+		slices.Reverse(events)
+	}
+	return events, rows.Err()
+}
+
+// LastEventID returns the ID of the newest event, or 0 when there is none.
+func (s *Store) LastEventID() (int64, error) {
+	var id int64
+	err := s.Db.QueryRow(`select coalesce(max(id), 0) from events`).Scan(&id)
+	return id, err
+}
+
+// PruneEvents deletes the events recorded before cutoff and returns how
+// many there were.
+func (s *Store) PruneEvents(cutoff time.Time) (int64, error) {
+	res, err := s.Db.Exec(`delete from events where at < ?`, cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
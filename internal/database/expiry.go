@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"fmt"
+	"queueCtl/internal/model"
+	"time"
+)
+
+// expiryWhere is the claim condition that keeps a job from starting once
+// it has expired or its deadline has passed.
+func expiryWhere(now time.Time) (string, []any) {
+	return `
+			AND NOT (state = ? AND expires_at IS NOT NULL AND expires_at <= ?)
+			AND (deadline IS NULL OR deadline > ?)`, []any{model.StatePending, now, now}
+}
+
+// ExpireJobs settles the jobs that can no longer run: a pending job past
+// its expires_at becomes expired, and a pending, failed or stale processing
+// job past its deadline is abandoned to the DLQ with the deadline_exceeded
+// reason. followUps, when not nil, returns the jobs to enqueue for each
+// settled job; they are inserted in the same transaction, as UpdateJob does.
+// It returns the settled jobs.
+func (s *Store) ExpireJobs(now time.Time, followUps func(*model.Job) []*model.Job) ([]*model.Job, error) {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`select `+jobColumns+` from jobs where
+		(state = ? and expires_at is not null and expires_at <= ?)
+		or (state in (?, ?) and deadline is not null and deadline <= ?)
+		or (state = ? and `+leaseStart("jobs")+` <= ? and deadline is not null and deadline <= ?)
+		order by created_at`,
+		model.StatePending, now,
+		model.StatePending, model.StateFailed, now,
+		model.StateProcessing, now.Add(-LeaseTimeout), now)
+	if err != nil {
+		return nil, err
+	}
+	var jobs []*model.Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var settled []*model.Job
+	for _, job := range jobs {
+		from := job.State
+		if job.State == model.StatePending && job.ExpiresAt != nil && !job.ExpiresAt.After(now) {
+			job.State, job.FailureReason = model.StateExpired, model.FailureExpired
+		} else {
+			job.State, job.FailureReason = model.StateDead, model.FailureDeadline
+		}
+		job.UpdatedAt = now
+		res, err := tx.Exec(`update jobs set state = ?, failure_reason = ?, updated_at = ? where id = ? and state = ?`,
+			job.State, job.FailureReason, now, job.ID, from)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		if err := recordEvent(tx, jobEvent(model.StateEvent(job.State), job, now)); err != nil {
+			return nil, err
+		}
+		if followUps != nil {
+			if err := enqueueFollowUps(tx, job, followUps(job)); err != nil {
+				return nil, err
+			}
+		}
+		if job.BatchID != "" {
+			if err := finishBatch(tx, job.BatchID, now); err != nil {
+				return nil, fmt.Errorf("finishing batch %s: %w", job.BatchID, err)
+			}
+		}
+		settled = append(settled, job)
+	}
+	return settled, tx.Commit()
+}
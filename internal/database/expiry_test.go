@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"queueCtl/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestClaimSkipsExpiredJobs(t *testing.T) {
+	s := newTestStore(t)
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	enqueue(t, s,
+		&model.Job{ID: "expired", ExpiresAt: &past},
+		&model.Job{ID: "past-deadline", Deadline: &past},
+		&model.Job{ID: "fresh", ExpiresAt: &future, Deadline: &future},
+		&model.Job{ID: "retrying", ExpiresAt: &past},
+	)
+	// expires_at only holds back a job that has not started yet.
+	if _, err := s.Db.Exec(`update jobs set state = ?, next_run_at = ? where id = 'retrying'`, model.StateFailed, past); err != nil {
+		t.Fatal(err)
+	}
+	if got := claimAll(t, s, "w1"); !slices.Equal(got, []string{"fresh", "retrying"}) {
+		t.Errorf("claimed %v, want fresh and retrying", got)
+	}
+}
+
+func TestExpireJobs(t *testing.T) {
+	s := newTestStore(t)
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	enqueue(t, s,
+		&model.Job{ID: "expired", ExpiresAt: &past},
+		&model.Job{ID: "failed-past-deadline", Deadline: &future},
+		&model.Job{ID: "stale-past-deadline", Deadline: &future},
+		&model.Job{ID: "running-past-deadline", Deadline: &future},
+		&model.Job{ID: "pending-in-time", ExpiresAt: &future, Deadline: &future},
+	)
+	claimAll(t, s, "w1")
+	finish(t, s, "failed-past-deadline", model.StateFailed)
+	expireLease(t, s, "stale-past-deadline")
+	finish(t, s, "pending-in-time", model.StatePending)
+	// The deadlines pass after the jobs were claimed.
+	if _, err := s.Db.Exec(`update jobs set deadline = ? where id like '%-past-deadline'`, past); err != nil {
+		t.Fatal(err)
+	}
+
+	var asked []string
+	followUps := func(job *model.Job) []*model.Job {
+		asked = append(asked, job.ID)
+		f := &model.Job{ID: model.FollowUpID(job.ID, job.State), Command: "true"}
+		if err := f.Prepare(time.Now(), 3); err != nil {
+			t.Fatal(err)
+		}
+		return []*model.Job{f}
+	}
+	settled, err := s.ExpireJobs(time.Now(), followUps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][2]string{
+		"expired":              {model.StateExpired, model.FailureExpired},
+		"failed-past-deadline": {model.StateDead, model.FailureDeadline},
+		"stale-past-deadline":  {model.StateDead, model.FailureDeadline},
+	}
+	if len(settled) != len(want) {
+		t.Errorf("settled %d jobs, want %d", len(settled), len(want))
+	}
+	for _, job := range settled {
+		if w, ok := want[job.ID]; !ok || job.State != w[0] || job.FailureReason != w[1] {
+			t.Errorf("settled %s as %s (%s)", job.ID, job.State, job.FailureReason)
+		}
+	}
+	for id, w := range want {
+		if got := state(t, s, id); got != w[0] {
+			t.Errorf("%s is %s, want %s", id, got, w[0])
+		}
+		if got := state(t, s, id+".on_failure"); got != model.StatePending {
+			t.Errorf("follow-up of %s is %s, want pending", id, got)
+		}
+		events, err := s.ListEvents(EventFilter{JobID: id, Types: []string{model.StateEvent(w[0])}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].FailureReason != w[1] {
+			t.Errorf("%s events = %+v, want one %s event", id, events, w[0])
+		}
+	}
+	if got := state(t, s, "running-past-deadline"); got != model.StateProcessing {
+		t.Errorf("a job still running past its deadline is %s, want it left to its worker", got)
+	}
+	if got := state(t, s, "pending-in-time"); got != model.StatePending {
+		t.Errorf("pending-in-time is %s", got)
+	}
+
+	// Settled jobs stay settled.
+	if again, err := s.ExpireJobs(time.Now(), followUps); err != nil || len(again) != 0 {
+		t.Errorf("second pass settled %v, %v", again, err)
+	}
+	if len(asked) != len(want) {
+		t.Errorf("asked for the follow-ups of %v", asked)
+	}
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"fmt"
+	"queueCtl/internal/model"
+	"time"
+)
+
+// ConflictStrategy decides what ImportJobs does with a job or batch whose
+// ID is already in the database, or a throttle for a queue or key that
+// already has one.
+type ConflictStrategy string
+
+const (
+	// ConflictFail aborts the whole import.
+	ConflictFail ConflictStrategy = "fail"
+	// ConflictSkip keeps the existing job.
+	ConflictSkip ConflictStrategy = "skip"
+	// ConflictOverwrite replaces the existing job and its attempt history.
+	ConflictOverwrite ConflictStrategy = "overwrite"
+)
+
+// ConflictStrategies lists the valid strategies.
+var ConflictStrategies = []ConflictStrategy{ConflictFail, ConflictSkip, ConflictOverwrite}
+
+// ImportResult lists the job IDs an import wrote, by outcome.
+type ImportResult struct {
+	Created  []string `json:"created"`
+	Replaced []string `json:"replaced"`
+	Skipped  []string `json:"skipped"`
+	// Requeued are jobs that were processing in the source and were put
+	// back to pending, since no worker here is running them.
+	Requeued []string `json:"requeued"`
+
+	// Batches lists the batch IDs, by outcome.
+	Batches ImportOutcome `json:"batches"`
+	// Throttles lists the throttles, as scope:key, by outcome.
+	Throttles ImportOutcome `json:"throttles"`
+}
+
+// ImportOutcome lists the IDs of the records of one kind that an import
+// wrote, by outcome.
+type ImportOutcome struct {
+	Created  []string `json:"created"`
+	Replaced []string `json:"replaced"`
+	Skipped  []string `json:"skipped"`
+}
+
+func newImportOutcome() ImportOutcome {
+	return ImportOutcome{Created: []string{}, Replaced: []string{}, Skipped: []string{}}
+}
+
+// ImportJobs writes throttles, batches with their counts and callback, and
+// then jobs with every stored field and their attempt history, in one
+// transaction, resolving conflicts with strategy. With ConflictFail the
+// first conflict rolls everything back and returns an error wrapping
+// ErrDuplicate.
+func (s *Store) ImportJobs(throttles []model.Throttle, batches []model.Batch, records []model.JobRecord, strategy ConflictStrategy) (*ImportResult, error) {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result := &ImportResult{Created: []string{}, Replaced: []string{}, Skipped: []string{}, Requeued: []string{},
+		Batches: newImportOutcome(), Throttles: newImportOutcome()}
+	for i := range throttles {
+		t := &throttles[i]
+		name := t.Scope + ":" + t.Key
+		inserted, err := insertThrottle(tx, t)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case inserted:
+			result.Throttles.Created = append(result.Throttles.Created, name)
+		case strategy == ConflictSkip:
+			result.Throttles.Skipped = append(result.Throttles.Skipped, name)
+		case strategy == ConflictOverwrite:
+			if _, err := tx.Exec(`delete from throttles where scope = ? and key = ?`, t.Scope, t.Key); err != nil {
+				return nil, err
+			}
+			if _, err := insertThrottle(tx, t); err != nil {
+				return nil, err
+			}
+			result.Throttles.Replaced = append(result.Throttles.Replaced, name)
+		default:
+			return nil, fmt.Errorf("%w: throttle for %s %q", ErrDuplicate, t.Scope, t.Key)
+		}
+	}
+	for i := range batches {
+		batch := &batches[i]
+		inserted, err := insertBatch(tx, batch)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case inserted:
+			result.Batches.Created = append(result.Batches.Created, batch.ID)
+		case strategy == ConflictSkip:
+			result.Batches.Skipped = append(result.Batches.Skipped, batch.ID)
+		case strategy == ConflictOverwrite:
+			if _, err := tx.Exec(`delete from batches where id = ?`, batch.ID); err != nil {
+				return nil, err
+			}
+			if _, err := insertBatch(tx, batch); err != nil {
+				return nil, err
+			}
+			result.Batches.Replaced = append(result.Batches.Replaced, batch.ID)
+		default:
+			return nil, fmt.Errorf("%w: batch %s", ErrDuplicate, batch.ID)
+		}
+	}
+
+	now := time.Now()
+	for i := range records {
+		job := records[i].Job
+		if job.State == model.StateProcessing {
+			job.State = model.StatePending
+			job.Attempts = max(job.Attempts-1, 0)
+			job.WorkerID = ""
+			job.FailureReason = model.FailureInterrupted
+			job.NextRunAt = now
+			job.UpdatedAt = now
+			result.Requeued = append(result.Requeued, job.ID)
+		}
+
+		replaced := false
+		inserted, err := insertRecord(tx, &job)
+		if err != nil {
+			return nil, err
+		}
+		if !inserted {
+			switch strategy {
+			case ConflictSkip:
+				result.Skipped = append(result.Skipped, job.ID)
+				continue
+			case ConflictOverwrite:
+				if _, err := tx.Exec(`delete from job_attempts where job_id = ?`, job.ID); err != nil {
+					return nil, err
+				}
+				if _, err := tx.Exec(`delete from jobs where id = ?`, job.ID); err != nil {
+					return nil, err
+				}
+				if _, err := insertRecord(tx, &job); err != nil {
+					return nil, err
+				}
+				replaced = true
+			default:
+				return nil, fmt.Errorf("%w: %s", ErrDuplicate, job.ID)
+			}
+		}
+
+		for j := range records[i].Attempts {
+			if err := insertAttempt(tx, &records[i].Attempts[j]); err != nil {
+				return nil, err
+			}
+		}
+		if replaced {
+			result.Replaced = append(result.Replaced, job.ID)
+		} else {
+			result.Created = append(result.Created, job.ID)
+		}
+	}
+	return result, tx.Commit()
+}
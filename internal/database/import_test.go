@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"queueCtl/internal/model"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// record returns a job as a dump or archive would hold it, with one
+// attempt.
+func record(id, state string) model.JobRecord {
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	job := model.Job{ID: id, Queue: "imports", Type: model.TypeShell, Command: "echo " + id, State: state,
+		Attempts: 2, MaxRetries: 5, CreatedAt: at, UpdatedAt: at.Add(time.Minute), NextRunAt: at, WorkerID: "w9"}
+	attempt := model.Attempt{JobID: id, Attempt: 1, WorkerID: "w9", StartedAt: at, FinishedAt: at.Add(time.Second), ExitCode: 1, Output: id + "\n"}
+	return model.JobRecord{Job: job, Attempts: []model.Attempt{attempt}}
+}
+
+func TestImportJobsKeepsEveryField(t *testing.T) {
+	s := newTestStore(t)
+	r := record("full", model.StateFailed)
+	expires, deadline := r.Job.CreatedAt.Add(time.Hour), r.Job.CreatedAt.Add(2*time.Hour)
+	j := &r.Job
+	j.Payload = json.RawMessage(`{"n":1}`)
+	j.Output, j.ExitCode, j.FailureReason = "out\n", 3, model.FailureExitCode
+	j.Limits = &model.ResourceLimits{CPUSeconds: 5, MemoryMB: 64}
+	j.Env = map[string]string{"K": "v"}
+	j.OnSuccess = &model.Job{ID: "full.on_success", Command: "echo next"}
+	j.ParentID, j.ConcurrencyKey, j.LockKey = "parent", "tenant", "account"
+	j.ExpiresAt, j.Deadline = &expires, &deadline
+	j.Timeout, j.TraceParent = "5m", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	result, err := s.ImportJobs(nil, nil, []model.JobRecord{r}, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Created, []string{"full"}) {
+		t.Errorf("result = %+v", result)
+	}
+	got, err := s.GetJob("full")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.CreatedAt.Equal(j.CreatedAt) || !got.UpdatedAt.Equal(j.UpdatedAt) || !got.NextRunAt.Equal(j.NextRunAt) ||
+		!got.ExpiresAt.Equal(expires) || !got.Deadline.Equal(deadline) {
+		t.Errorf("times = %v %v %v %v %v", got.CreatedAt, got.UpdatedAt, got.NextRunAt, got.ExpiresAt, got.Deadline)
+	}
+	got.CreatedAt, got.UpdatedAt, got.NextRunAt, got.ExpiresAt, got.Deadline = j.CreatedAt, j.UpdatedAt, j.NextRunAt, j.ExpiresAt, j.Deadline
+	if !reflect.DeepEqual(got, j) {
+		t.Errorf("imported job\n%+v\nwant\n%+v", got, j)
+	}
+	attempts, err := s.AttemptsFor([]string{"full"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := attempts["full"]; len(a) != 1 || a[0].Output != "full\n" || a[0].ExitCode != 1 {
+		t.Errorf("attempts = %+v", a)
+	}
+}
+
+func TestImportJobsConflicts(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "taken", Command: "echo original"})
+	records := []model.JobRecord{record("new", model.StateCompleted), record("taken", model.StateDead)}
+
+	if _, err := s.ImportJobs(nil, nil, records, ConflictFail); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("fail strategy error = %v, want ErrDuplicate", err)
+	}
+	if _, err := s.GetJob("new"); err == nil {
+		t.Error("a failed import left a job behind")
+	}
+
+	result, err := s.ImportJobs(nil, nil, records, ConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Created, []string{"new"}) || !slices.Equal(result.Skipped, []string{"taken"}) {
+		t.Errorf("skip result = %+v", result)
+	}
+	if job, _ := s.GetJob("taken"); job.Command != "echo original" {
+		t.Errorf("skipped job replaced by %q", job.Command)
+	}
+
+	// Overwriting replaces the attempt history too.
+	for range 2 {
+		result, err = s.ImportJobs(nil, nil, records[1:], ConflictOverwrite)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(result.Replaced, []string{"taken"}) || len(result.Created) != 0 {
+		t.Errorf("overwrite result = %+v", result)
+	}
+	job, err := s.GetJob("taken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Command != "echo taken" || job.State != model.StateDead {
+		t.Errorf("overwritten job = %+v", job)
+	}
+	if attempts, _ := s.AttemptsFor([]string{"taken"}); len(attempts["taken"]) != 1 {
+		t.Errorf("attempts after overwriting twice = %+v", attempts["taken"])
+	}
+}
+
+func TestImportJobsRequeuesProcessing(t *testing.T) {
+	s := newTestStore(t)
+	result, err := s.ImportJobs(nil, nil, []model.JobRecord{record("running", model.StateProcessing)}, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Requeued, []string{"running"}) || !slices.Equal(result.Created, []string{"running"}) {
+		t.Errorf("result = %+v", result)
+	}
+	job, err := s.GetJob("running")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != model.StatePending || job.Attempts != 1 || job.WorkerID != "" || job.FailureReason != model.FailureInterrupted {
+		t.Errorf("imported running job = %+v", job)
+	}
+	if got := claim(t, s, "w1"); got != "running" {
+		t.Errorf("claimed %q, want the requeued job", got)
+	}
+}
+
+func TestImportBatches(t *testing.T) {
+	src := newTestStore(t)
+	createBatch(t, src, "b", model.PolicyFailOnDead, "b-1", "b-2")
+	claimAll(t, src, "w1")
+	finish(t, src, "b-1", model.StateCompleted)
+	batches, err := src.ListBatches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := src.ListJobs(JobFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []model.JobRecord
+	for _, job := range page.Jobs {
+		records = append(records, model.JobRecord{Job: job})
+	}
+
+	dst := newTestStore(t)
+	result, err := dst.ImportJobs(nil, batches, records, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Batches.Created, []string{"b"}) {
+		t.Errorf("result = %+v", result)
+	}
+	if batch := getBatch(t, dst, "b"); batch.State != model.BatchRunning ||
+		!maps.Equal(batch.Counts, map[string]int{model.StateCompleted: 1, model.StatePending: 1}) {
+		t.Errorf("imported batch = %+v", batch)
+	}
+
+	// The imported batch finishes with its last member and fires its
+	// callback.
+	claim(t, dst, "w1")
+	finish(t, dst, "b-2", model.StateCompleted)
+	if batch := getBatch(t, dst, "b"); batch.State != model.BatchSucceeded {
+		t.Errorf("batch = %+v, want succeeded", batch)
+	}
+	callback, err := dst.GetJob("b.callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if callback.Env["KEEP"] != "1" || callback.Env["QUEUECTL_BATCH_COMPLETED"] != "2" {
+		t.Errorf("callback env = %v", callback.Env)
+	}
+
+	if _, err := dst.ImportJobs(nil, batches, nil, ConflictFail); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("fail strategy error = %v, want ErrDuplicate", err)
+	}
+	if result, err := dst.ImportJobs(nil, batches, nil, ConflictSkip); err != nil || !slices.Equal(result.Batches.Skipped, []string{"b"}) {
+		t.Errorf("skip result = %+v, %v", result, err)
+	}
+	if getBatch(t, dst, "b").State != model.BatchSucceeded {
+		t.Error("skipped batch replaced")
+	}
+	if result, err := dst.ImportJobs(nil, batches, nil, ConflictOverwrite); err != nil || !slices.Equal(result.Batches.Replaced, []string{"b"}) {
+		t.Errorf("overwrite result = %+v, %v", result, err)
+	}
+	if batch := getBatch(t, dst, "b"); batch.State != model.BatchRunning || batch.Counts[model.StateCompleted] != 1 {
+		t.Errorf("overwritten batch = %+v", batch)
+	}
+}
+
+func TestImportThrottles(t *testing.T) {
+	src := newTestStore(t)
+	for _, th := range []*model.Throttle{
+		{Scope: model.ScopeQueue, Key: "q", MaxConcurrency: 1},
+		{Scope: model.ScopeKey, Key: "tenant", Rate: 2, Burst: 4},
+	} {
+		if err := src.SetThrottle(th); err != nil {
+			t.Fatal(err)
+		}
+	}
+	throttles, err := src.ListThrottles()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newTestStore(t)
+	result, err := dst.ImportJobs(throttles, nil, nil, ConflictFail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(result.Throttles.Created, []string{"key:tenant", "queue:q"}) {
+		t.Errorf("result = %+v", result)
+	}
+	imported, err := dst.ListThrottles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(imported, throttles) {
+		t.Errorf("imported throttles %+v, want %+v", imported, throttles)
+	}
+	// The imported concurrency cap holds.
+	enqueue(t, dst, &model.Job{ID: "a", Queue: "q"}, &model.Job{ID: "b", Queue: "q"})
+	if got := claimAll(t, dst, "w1"); len(got) != 1 {
+		t.Errorf("claimed %v from a queue capped at one", got)
+	}
+
+	changed := slices.Clone(throttles)
+	changed[1].MaxConcurrency = 5
+	if _, err := dst.ImportJobs(changed, nil, nil, ConflictFail); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("fail strategy error = %v, want ErrDuplicate", err)
+	}
+	if result, err := dst.ImportJobs(changed, nil, nil, ConflictSkip); err != nil || len(result.Throttles.Skipped) != 2 {
+		t.Errorf("skip result = %+v, %v", result, err)
+	}
+	if result, err := dst.ImportJobs(changed, nil, nil, ConflictOverwrite); err != nil || len(result.Throttles.Replaced) != 2 {
+		t.Errorf("overwrite result = %+v, %v", result, err)
+	}
+	if statuses, err := dst.ThrottleStatuses(); err != nil || statuses[1].MaxConcurrency != 5 {
+		t.Errorf("throttles after overwrite = %+v, %v", statuses, err)
+	}
+}
@@ -1,12 +1,96 @@
 package storage
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"queueCtl/internal/model"
+	"slices"
+	"strings"
+	"time"
 )
 
+// JobFilter selects jobs for ListJobs. Zero fields match everything.
+type JobFilter struct {
+	States          []string
+	Queue           string
+	IDPrefix        string
+	CommandContains string
+	// CommandGlob matches the command with SQLite GLOB syntax (*, ?, [..]).
+	CommandGlob   string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	MinAttempts   int
+	// MaxAttempts is nil when attempts are not bounded above.
+	MaxAttempts *int
+	// ExitCode is nil to match any exit code.
+	ExitCode *int
+
+	// SortBy is one of SortColumns; empty sorts by created_at. Ties are
+	// broken by job ID.
+	SortBy     string
+	Descending bool
+	// Limit caps the page size; zero returns every match in one page.
+	Limit int
+	// Cursor continues from the page that returned it as NextCursor. It is
+	// only valid with the same filter and sort.
+	Cursor string
+}
+
+// JobPage is one page of ListJobs results.
+type JobPage struct {
+	Jobs []model.Job
+	// NextCursor fetches the following page; empty on the last page.
+	NextCursor string
+}
+
+// SortColumns are the columns ListJobs can sort by.
+var SortColumns = []string{"created_at", "updated_at", "attempts", "id"}
+
+// pageCursor is the position after the last row of a page: that row's sort
+// value and ID. Timestamps are kept as stored text so they compare exactly.
+type pageCursor struct {
+	SortBy string `json:"s"`
+	Desc   bool   `json:"d"`
+	Value  any    `json:"v"`
+	ID     string `json:"id"`
+}
+
+func encodeCursor(c pageCursor) (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(token string) (pageCursor, error) {
+	var c pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&c)
+	}
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if n, ok := c.Value.(json.Number); ok {
+		i, err := n.Int64()
+		if err != nil {
+			return c, fmt.Errorf("invalid cursor: %w", err)
+		}
+		c.Value = i
+	}
+	return c, nil
+}
+
 func (s *Store) ListJobsByState(state string) ([]model.Job, error) {
-	statement := `select * from jobs where state=?`
+	statement := `select ` + jobColumns + ` from jobs where state=?`
 	rows, err := s.Db.Query(statement, state)
 	if err != nil {
 		return nil, err
@@ -14,30 +98,220 @@ func (s *Store) ListJobsByState(state string) ([]model.Job, error) {
 	defer rows.Close()
 
 	var jobs []model.Job
-	var outputStr sql.NullString
 	for rows.Next() {
-		var job model.Job
-		if err := rows.Scan(
-			&job.ID,
-			&job.Command,
-			&job.State,
-			&job.Attempts,
-			&job.MaxRetries,
-			&job.CreatedAt,
-			&job.UpdatedAt,
-			&job.NextRunAt,
-			&outputStr,
-		); err != nil {
+		job, err := scanJob(rows)
+		if err != nil {
 			return nil, err
 		}
-		if outputStr.Valid {
-			job.Output = outputStr.String
-		}	
-		jobs = append(jobs, job)
+		jobs = append(jobs, *job)
 	}
 	return jobs, nil
 }
 
+// GetJob returns the job with the given ID, or ErrNotFound.
+func (s *Store) GetJob(id string) (*model.Job, error) {
+	job, err := scanJob(s.Db.QueryRow(`select `+jobColumns+` from jobs where id=?`, id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return job, err
+}
+
+// ListJobs returns one page of the jobs matching filter.
+func (s *Store) ListJobs(filter JobFilter) (*JobPage, error) {
+	sortBy := filter.SortBy
+	if sortBy == "" {
+		sortBy = "created_at"
+	}
+	if !slices.Contains(SortColumns, sortBy) {
+		return nil, fmt.Errorf("cannot sort by %q (use %s)", sortBy, strings.Join(SortColumns, ", "))
+	}
+	// Timestamps are read back as their stored text for the cursor.
+	sortKey := sortBy
+	if strings.HasSuffix(sortBy, "_at") {
+		sortKey = `cast(` + sortBy + ` as text)`
+	}
+
+	where, args := filterWhere(filter)
+
+	if filter.Cursor != "" {
+		c, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if c.SortBy != sortBy || c.Desc != filter.Descending {
+			return nil, fmt.Errorf("invalid cursor: it was issued for a different sort order")
+		}
+		op := ">"
+		if filter.Descending {
+			op = "<"
+		}
+		where = append(where, `(`+sortBy+` `+op+` ? or (`+sortBy+` = ? and id `+op+` ?))`)
+		args = append(args, c.Value, c.Value, c.ID)
+	}
+
+	statement := `select ` + jobColumns + `, ` + sortKey + ` from jobs`
+	if len(where) > 0 {
+		statement += ` where ` + strings.Join(where, " and ")
+	}
+	dir := "asc"
+	if filter.Descending {
+		dir = "desc"
+	}
+	statement += ` order by ` + sortBy + ` ` + dir + `, id ` + dir
+	if filter.Limit > 0 {
+		// One extra row tells whether there is a next page.
+		statement += fmt.Sprintf(` limit %d`, filter.Limit+1)
+	}
+
+	rows, err := s.Db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &JobPage{}
+	var lastKey any
+	for rows.Next() {
+		if filter.Limit > 0 && len(page.Jobs) == filter.Limit {
+			c := pageCursor{SortBy: sortBy, Desc: filter.Descending, Value: lastKey, ID: page.Jobs[len(page.Jobs)-1].ID}
+			if page.NextCursor, err = encodeCursor(c); err != nil {
+				return nil, err
+			}
+			break
+		}
+		var key any
+		job, err := scanJob(withExtra(rows, &key))
+		if err != nil {
+			return nil, err
+		}
+		page.Jobs = append(page.Jobs, *job)
+		lastKey = key
+	}
+	return page, rows.Err()
+}
+
+// filterWhere builds the SQL conditions and arguments for every field of
+// filter except sorting and pagination.
+func filterWhere(filter JobFilter) ([]string, []any) {
+	var where []string
+	var args []any
+	if len(filter.States) > 0 {
+		where = append(where, `state in (?`+strings.Repeat(",?", len(filter.States)-1)+`)`)
+		for _, state := range filter.States {
+			args = append(args, state)
+		}
+	}
+	if filter.Queue != "" {
+		where = append(where, `queue = ?`)
+		args = append(args, filter.Queue)
+	}
+	if filter.IDPrefix != "" {
+		where = append(where, `substr(id, 1, length(?)) = ?`)
+		args = append(args, filter.IDPrefix, filter.IDPrefix)
+	}
+	if filter.CommandContains != "" {
+		where = append(where, `instr(command, ?) > 0`)
+		args = append(args, filter.CommandContains)
+	}
+	if filter.CommandGlob != "" {
+		where = append(where, `command glob ?`)
+		args = append(args, filter.CommandGlob)
+	}
+	for _, bound := range []struct {
+		cond string
+		t    time.Time
+	}{
+		{`created_at >= ?`, filter.CreatedAfter},
+		{`created_at < ?`, filter.CreatedBefore},
+		{`updated_at >= ?`, filter.UpdatedAfter},
+		{`updated_at < ?`, filter.UpdatedBefore},
+	} {
+		if !bound.t.IsZero() {
+			// Timestamps are stored as UTC text; the connection converts
+			// the bound to UTC so that it compares correctly.
+			where = append(where, bound.cond)
+			args = append(args, bound.t)
+		}
+	}
+	if filter.MinAttempts > 0 {
+		where = append(where, `attempts >= ?`)
+		args = append(args, filter.MinAttempts)
+	}
+	if filter.MaxAttempts != nil {
+		where = append(where, `attempts <= ?`)
+		args = append(args, *filter.MaxAttempts)
+	}
+	if filter.ExitCode != nil {
+		where = append(where, `exit_code = ?`)
+		args = append(args, *filter.ExitCode)
+	}
+
+	return where, args
+}
+
+// CountJobs returns how many jobs match filter, ignoring sorting and
+// pagination.
+func (s *Store) CountJobs(filter JobFilter) (int, error) {
+	where, args := filterWhere(filter)
+	statement := `select count(*) from jobs`
+	if len(where) > 0 {
+		statement += ` where ` + strings.Join(where, " and ")
+	}
+	var n int
+	err := s.Db.QueryRow(statement, args...).Scan(&n)
+	return n, err
+}
+
+// withExtra appends dest to the columns scanned by a rowScanner, for
+// queries that select more than jobColumns.
+func withExtra(row rowScanner, dest ...any) rowScanner {
+	return extraScanner{row, dest}
+}
+
+type extraScanner struct {
+	row   rowScanner
+	extra []any
+}
+
+func (e extraScanner) Scan(dest ...any) error {
+	return e.row.Scan(append(dest, e.extra...)...)
+}
+
+// TypeBacklog counts the jobs of one handler type waiting to run.
+type TypeBacklog struct {
+	Type    string    `json:"type"`
+	Waiting int       `json:"waiting"`
+	Oldest  time.Time `json:"oldest"`
+}
+
+// HandlerBacklog returns, for every job type other than shell, how many
+// jobs are pending or failed and when the oldest of them was enqueued. Only
+// pools that registered a handler for a type claim its jobs, so a type
+// nothing handles keeps growing here.
+func (s *Store) HandlerBacklog() ([]TypeBacklog, error) {
+	// The bare created_at comes from the row holding min(created_at), and
+	// keeps the column's type.
+	rows, err := s.Db.Query(`select type, count(*), min(created_at), created_at from jobs
+		where type != ? and state in (?, ?)
+		group by type order by type`,
+		model.TypeShell, model.StatePending, model.StateFailed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	backlog := []TypeBacklog{}
+	for rows.Next() {
+		var b TypeBacklog
+		var min any
+		if err := rows.Scan(&b.Type, &b.Waiting, &min, &b.Oldest); err != nil {
+			return nil, err
+		}
+		backlog = append(backlog, b)
+	}
+	return backlog, rows.Err()
+}
+
 // state -> count
 func (s *Store) GetJobStats() (map[string]int, error) {
 	statement := `select state, count(*) from jobs group by state;`
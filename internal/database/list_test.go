@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"fmt"
+	"queueCtl/internal/model"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pagedIDs lists every job matching filter, limit at a time, and returns
+// their IDs and the number of pages.
+func pagedIDs(t *testing.T, s *Store, filter JobFilter, limit int) ([]string, int) {
+	t.Helper()
+	var ids []string
+	filter.Limit = limit
+	for pages := 1; ; pages++ {
+		page, err := s.ListJobs(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Jobs) > limit {
+			t.Fatalf("page of %d jobs with limit %d", len(page.Jobs), limit)
+		}
+		for _, j := range page.Jobs {
+			ids = append(ids, j.ID)
+		}
+		if page.NextCursor == "" {
+			return ids, pages
+		}
+		if pages > 100 {
+			t.Fatal("pagination does not end")
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+func TestListJobsCursorPagination(t *testing.T) {
+	s := newTestStore(t)
+	var jobs []*model.Job
+	for i := range 11 {
+		jobs = append(jobs, &model.Job{ID: fmt.Sprintf("job-%02d", i)})
+	}
+	enqueue(t, s, jobs...)
+
+	// Ties in every sort column, and timestamps whose text has fractions
+	// of different lengths.
+	base := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	offsets := []time.Duration{0, 0, 100 * time.Millisecond, 50 * time.Millisecond, 150 * time.Millisecond,
+		time.Second, time.Second, 1500 * time.Millisecond, 2 * time.Second, 0, time.Second + time.Nanosecond}
+	for i, d := range offsets {
+		if _, err := s.Db.Exec(`update jobs set created_at = ?, updated_at = ?, attempts = ? where id = ?`,
+			base.Add(d), base.Add(-d), i%3, jobs[i].ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sortBy := range SortColumns {
+		for _, desc := range []bool{false, true} {
+			filter := JobFilter{SortBy: sortBy, Descending: desc}
+			all, err := s.ListJobs(filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if all.NextCursor != "" || len(all.Jobs) != len(jobs) {
+				t.Fatalf("%s desc=%v: unpaged listing has %d jobs and cursor %q", sortBy, desc, len(all.Jobs), all.NextCursor)
+			}
+			want := make([]string, len(all.Jobs))
+			for i, j := range all.Jobs {
+				want[i] = j.ID
+			}
+			if !sortedBy(all.Jobs, sortBy, desc) {
+				t.Errorf("%s desc=%v: unpaged order %v is not sorted", sortBy, desc, want)
+			}
+
+			for _, limit := range []int{1, 2, 3, 11} {
+				got, pages := pagedIDs(t, s, filter, limit)
+				if !slices.Equal(got, want) {
+					t.Errorf("%s desc=%v limit %d: paged %v, want %v", sortBy, desc, limit, got, want)
+				}
+				if wantPages := (len(want) + limit - 1) / limit; pages != wantPages {
+					t.Errorf("%s desc=%v limit %d: %d pages, want %d", sortBy, desc, limit, pages, wantPages)
+				}
+			}
+		}
+	}
+}
+
+// sortedBy reports whether jobs are in ListJobs order for sortBy.
+func sortedBy(jobs []model.Job, sortBy string, desc bool) bool {
+	return slices.IsSortedFunc(jobs, func(a, b model.Job) int {
+		var c int
+		switch sortBy {
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "updated_at":
+			c = a.UpdatedAt.Compare(b.UpdatedAt)
+		case "attempts":
+			c = a.Attempts - b.Attempts
+		}
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		if desc {
+			return -c
+		}
+		return c
+	})
+}
+
+func TestListJobsCursorWithFilter(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s,
+		&model.Job{ID: "a", Queue: "q"},
+		&model.Job{ID: "b"},
+		&model.Job{ID: "c", Queue: "q"},
+		&model.Job{ID: "d", Queue: "q"},
+	)
+	got, _ := pagedIDs(t, s, JobFilter{Queue: "q"}, 1)
+	if !slices.Equal(got, []string{"a", "c", "d"}) {
+		t.Errorf("paged %v, want a, c and d", got)
+	}
+
+	// Jobs enqueued after a page was read show up on later pages.
+	page, err := s.ListJobs(JobFilter{Queue: "q", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, s, &model.Job{ID: "e", Queue: "q"})
+	next, err := s.ListJobs(JobFilter{Queue: "q", Limit: 2, Cursor: page.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(next.Jobs) != 2 || next.Jobs[0].ID != "d" || next.Jobs[1].ID != "e" || next.NextCursor != "" {
+		t.Errorf("second page = %v, cursor %q, want d and e", next.Jobs, next.NextCursor)
+	}
+}
+
+func TestListJobsRejectsBadCursors(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "a"}, &model.Job{ID: "b"})
+	page, err := s.ListJobs(JobFilter{Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filter := range []JobFilter{
+		{Limit: 1, Cursor: page.NextCursor, SortBy: "id"},
+		{Limit: 1, Cursor: page.NextCursor, Descending: true},
+		{Limit: 1, Cursor: "not a cursor"},
+		{Limit: 1, Cursor: "e30"}, // {}
+	} {
+		if _, err := s.ListJobs(filter); err == nil || !strings.Contains(err.Error(), "cursor") {
+			t.Errorf("ListJobs(%+v) error = %v, want an invalid cursor", filter, err)
+		}
+	}
+	if _, err := s.ListJobs(JobFilter{SortBy: "command"}); err == nil {
+		t.Error("sorting by command succeeded")
+	}
+}
+
+func TestListJobsTimeBoundsInAnyZone(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "a"}, &model.Job{ID: "b"}, &model.Job{ID: "c"})
+	b, err := s.GetJob("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, zone := range []*time.Location{time.UTC, time.FixedZone("UTC-5", -5*3600), time.FixedZone("UTC+9", 9*3600)} {
+		bound := b.CreatedAt.In(zone)
+		after, err := s.ListJobs(JobFilter{CreatedAfter: bound})
+		if err != nil {
+			t.Fatal(err)
+		}
+		before, err := s.ListJobs(JobFilter{CreatedBefore: bound})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(after.Jobs) != 2 || after.Jobs[0].ID != "b" || len(before.Jobs) != 1 || before.Jobs[0].ID != "a" {
+			t.Errorf("%s: created after %v, before %v", zone, after.Jobs, before.Jobs)
+		}
+	}
+}
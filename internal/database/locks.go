@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"queueCtl/internal/model"
+	"time"
+)
+
+// lockWhere is the claim condition for lock keys: a job with a lock_key may
+// start only when no other job with that key holds a live lease, and no
+// unfinished job with that key was enqueued before it. A job waiting to
+// retry therefore keeps its place in line.
+func lockWhere(now time.Time) (string, []any) {
+	return `
+			AND (lock_key IS NULL OR NOT EXISTS (
+				SELECT 1 FROM jobs AS ahead
+				WHERE ahead.lock_key = jobs.lock_key AND ahead.id != jobs.id AND (
+					(ahead.state = ? AND ` + leaseStart("ahead") + ` > ?)
+					OR
+					(ahead.state IN (?, ?, ?) AND (ahead.created_at < jobs.created_at
+						OR (ahead.created_at = jobs.created_at AND ahead.id < jobs.id)))
+				)
+			))`, []any{
+		model.StateProcessing, now.Add(-LeaseTimeout),
+		model.StatePending, model.StateFailed, model.StateProcessing,
+	}
+}
+
+// LockHolder is a lock key and the job holding it.
+type LockHolder struct {
+	Key      string    `json:"key"`
+	JobID    string    `json:"job_id"`
+	WorkerID string    `json:"worker_id,omitempty"`
+	Since    time.Time `json:"since"`
+	// Waiting counts the unfinished jobs queued behind the holder.
+	Waiting int `json:"waiting"`
+}
+
+// ListLocks returns the lock keys currently held by a processing job with a
+// live lease, ordered by key.
+func (s *Store) ListLocks() ([]LockHolder, error) {
+	rows, err := s.Db.Query(`select h.lock_key, h.id, coalesce(h.worker_id, ''), h.updated_at,
+			(select count(*) from jobs w where w.lock_key = h.lock_key and w.id != h.id and w.state in (?, ?, ?))
+		from jobs h
+		where h.lock_key is not null and h.state = ? and `+leaseStart("h")+` > ?
+		order by h.lock_key, h.updated_at`,
+		model.StatePending, model.StateFailed, model.StateProcessing,
+		model.StateProcessing, time.Now().Add(-LeaseTimeout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	holders := []LockHolder{}
+	for rows.Next() {
+		var h LockHolder
+		if err := rows.Scan(&h.Key, &h.JobID, &h.WorkerID, &h.Since, &h.Waiting); err != nil {
+			return nil, err
+		}
+		holders = append(holders, h)
+	}
+	return holders, rows.Err()
+}
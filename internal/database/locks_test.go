@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"queueCtl/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestClaimLockKeyIsExclusive(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s,
+		&model.Job{ID: "a", LockKey: "deploy"},
+		&model.Job{ID: "b", Queue: "other", LockKey: "deploy"},
+		&model.Job{ID: "c"},
+		&model.Job{ID: "d", LockKey: "backup"},
+	)
+	if got := claimAll(t, s, "w1"); !slices.Equal(got, []string{"a", "c", "d"}) {
+		t.Fatalf("claimed %v, want a, c and d", got)
+	}
+
+	locks, err := s.ListLocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locks) != 2 || locks[0].Key != "backup" || locks[1].Key != "deploy" ||
+		locks[1].JobID != "a" || locks[1].WorkerID != "w1" || locks[1].Waiting != 1 {
+		t.Errorf("locks = %+v, want backup and deploy, held by a with b waiting", locks)
+	}
+
+	finish(t, s, "a", model.StateCompleted)
+	if got := claim(t, s, "w1"); got != "b" {
+		t.Errorf("claimed %q once a completed, want b", got)
+	}
+}
+
+func TestClaimLockKeyKeepsOrderAcrossRetries(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "a", LockKey: "k"}, &model.Job{ID: "b", LockKey: "k"})
+	claim(t, s, "w1")
+
+	// a waits for its retry; b stays behind it.
+	finish(t, s, "a", model.StateFailed)
+	if got := claim(t, s, "w1"); got != "" {
+		t.Fatalf("claimed %q while a waits to retry", got)
+	}
+	if _, err := s.Db.Exec(`update jobs set next_run_at = ? where id = 'a'`, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got := claim(t, s, "w1"); got != "a" {
+		t.Fatalf("claimed %q once a was due, want a", got)
+	}
+	finish(t, s, "a", model.StateDead)
+	if got := claim(t, s, "w1"); got != "b" {
+		t.Errorf("claimed %q once a was dead, want b", got)
+	}
+}
+
+func TestClaimLockKeyHeldByLaterJob(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "a", LockKey: "k"}, &model.Job{ID: "b", LockKey: "k"})
+	// b got the lock first, e.g. a was retried from the DLQ after b
+	// started: a waits for b to finish.
+	if _, err := s.Db.Exec(`update jobs set state = ?, updated_at = ? where id = 'b'`, model.StateProcessing, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := claim(t, s, "w1"); got != "" {
+		t.Fatalf("claimed %q while b holds the lock", got)
+	}
+
+	// b's worker is gone: a, first in line, takes the lock and b waits
+	// to be reclaimed.
+	expireLease(t, s, "b")
+	if got := claimAll(t, s, "w1"); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("claimed %v after b's lease ran out, want only a", got)
+	}
+}
+
+func TestClaimLockKeySameCreationTime(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "x2", LockKey: "k"}, &model.Job{ID: "x1", LockKey: "k"})
+	if _, err := s.Db.Exec(`update jobs set created_at = (select min(created_at) from jobs)`); err != nil {
+		t.Fatal(err)
+	}
+	if got := claimAll(t, s, "w1"); !slices.Equal(got, []string{"x1"}) {
+		t.Errorf("claimed %v, want only x1, which the ID puts first", got)
+	}
+}
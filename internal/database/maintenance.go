@@ -0,0 +1,16 @@
+package storage
+
+// Checkpoint copies the write-ahead log into the database file and
+// truncates it, so the WAL does not keep growing between automatic
+// checkpoints.
+func (s *Store) Checkpoint() error {
+	_, err := s.Db.Exec(`pragma wal_checkpoint(TRUNCATE)`)
+	return err
+}
+
+// Vacuum rebuilds the database file, returning pages freed by deleted jobs
+// to the filesystem. It holds the write lock for its whole run.
+func (s *Store) Vacuum() error {
+	_, err := s.Db.Exec(`vacuum`)
+	return err
+}
@@ -2,13 +2,56 @@ package storage
 
 import (
 	"database/sql"
+	"encoding/json"
+	"fmt"
 	"queueCtl/internal/model"
+	"sync/atomic"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type Store struct {
 	Db *sql.DB
+
+	// lockedSince is when claims started finding the database locked, in
+	// Unix nanoseconds, or 0.
+	lockedSince atomic.Int64
+}
+
+// jobColumns is the column list every job query selects, in the order
+// scanJob expects them.
+const jobColumns = `id, command, state, attempts, max_retries, created_at, updated_at, next_run_at, output,
+	limits, exit_code, failure_reason, type, payload, worker_id, queue, env, on_success, on_failure, parent_id, batch_id, concurrency_key, lock_key,
+	expires_at, deadline, traceparent, timeout`
+
+// columnMigration is a column added to a table after its first release.
+type columnMigration struct {
+	column     string
+	definition string
+}
+
+// jobMigrations are applied to existing databases on startup.
+var jobMigrations = []columnMigration{
+	{"limits", "text"},
+	{"exit_code", "integer not null default 0"},
+	{"failure_reason", "text"},
+	{"type", "text not null default 'shell'"},
+	{"payload", "text"},
+	{"worker_id", "text"},
+	{"queue", "text not null default 'default'"},
+	{"env", "text"},
+	{"on_success", "text"},
+	{"on_failure", "text"},
+	{"parent_id", "text"},
+	{"batch_id", "text"},
+	{"concurrency_key", "text"},
+	{"lock_key", "text"},
+	{"expires_at", "DATETIME"},
+	{"deadline", "DATETIME"},
+	{"traceparent", "text"},
+	{"timeout", "text"},
+	{"renewed_at", "DATETIME"},
 }
 
 func (s *Store)Init() error{
@@ -23,16 +66,73 @@ func (s *Store)Init() error{
 		next_run_at DATETIME,
 		output text
 	);`
-	_, err := s.Db.Exec(createJobTable)
-	return err
+	if _, err := s.Db.Exec(createJobTable); err != nil {
+		return err
+	}
+	if err := s.migrate("jobs", jobMigrations); err != nil {
+		return err
+	}
+	createJobIndexes := `create index if not exists jobs_state_created on jobs(state, created_at);
+	create index if not exists jobs_queue on jobs(queue);
+	create index if not exists jobs_batch on jobs(batch_id) where batch_id is not null;
+	create index if not exists jobs_lock on jobs(lock_key, created_at) where lock_key is not null;`
+	if _, err := s.Db.Exec(createJobIndexes); err != nil {
+		return err
+	}
+	if err := s.initAttempts(); err != nil {
+		return err
+	}
+	if err := s.initBatches(); err != nil {
+		return err
+	}
+	if err := s.initThrottles(); err != nil {
+		return err
+	}
+	if err := s.initEvents(); err != nil {
+		return err
+	}
+	if err := s.initAudit(); err != nil {
+		return err
+	}
+	return s.convertTimesToUTC()
 }
 
+// migrate adds any of the given columns that the table does not have yet.
+func (s *Store) migrate(table string, columns []columnMigration) error {
+	rows, err := s.Db.Query(`select name from pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
 
-func NewStore(dbPath string)(*Store,error){
-	db, err:= sql.Open("sqlite3",dbPath+"?_journal_mode=WAL")
-	if err!= nil{
-		return nil, err
+	for _, c := range columns {
+		if existing[c.column] {
+			continue
+		}
+		if _, err := s.Db.Exec(`alter table ` + table + ` add column ` + c.column + ` ` + c.definition); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+
+func NewStore(dbPath string)(*Store,error){
+	// Wait for a competing writer rather than failing straight away with
+	// "database is locked".
+	db := sql.OpenDB(utcConnector{dsn: dbPath + "?_journal_mode=WAL&_busy_timeout=5000&_loc=auto"})
 
 	if err:= db.Ping(); err!= nil{
 		return nil,err
@@ -47,12 +147,196 @@ func NewStore(dbPath string)(*Store,error){
 }
 
 func (s *Store)CreateJob(job *model.Job) error{
-	statement := `insert into jobs (
-		id, command, state, attempts, max_retries, created_at, updated_at, next_run_at
-		) Values (?,?,?,?,?,?,?,?);`
-	_,err := s.Db.Exec(statement,job.ID,job.Command,job.State,job.Attempts,job.MaxRetries,job.CreatedAt,job.UpdatedAt,job.NextRunAt)
+	tx, err := s.Db.Begin()
 	if err!=nil{
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+	if _, err := insertNewJob(tx, job, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// insertNewJob inserts a freshly prepared job and records its enqueued
+// event. With orIgnore a job whose ID is taken is silently left out and
+// false is returned; otherwise that is an ErrDuplicate error.
+func insertNewJob(db execer, job *model.Job, orIgnore bool) (bool, error) {
+	limits, err := marshalLimits(job.Limits)
+	if err != nil {
+		return false, err
+	}
+	jobType := job.Type
+	if jobType == "" {
+		jobType = model.TypeShell
+	}
+	queue := job.Queue
+	if queue == "" {
+		queue = model.DefaultQueue
+	}
+	var payload sql.NullString
+	if len(job.Payload) > 0 {
+		payload = sql.NullString{String: string(job.Payload), Valid: true}
+	}
+	env, onSuccess, onFailure, err := marshalChain(job)
+	if err != nil {
+		return false, err
+	}
+	verb := "insert"
+	if orIgnore {
+		verb = "insert or ignore"
+	}
+	statement := verb + ` into jobs (
+		id, command, state, attempts, max_retries, created_at, updated_at, next_run_at, limits, type, payload, queue,
+		env, on_success, on_failure, parent_id, batch_id, concurrency_key, lock_key, expires_at, deadline, traceparent, timeout
+		) Values (?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?,?);`
+	res, err := db.Exec(statement,job.ID,job.Command,job.State,job.Attempts,job.MaxRetries,job.CreatedAt,job.UpdatedAt,job.NextRunAt,limits,jobType,payload,queue,
+		env, onSuccess, onFailure, nullString(job.ParentID), nullString(job.BatchID), nullString(job.ConcurrencyKey), nullString(job.LockKey),
+		nullTime(job.ExpiresAt), nullTime(job.Deadline), nullString(job.TraceParent), nullString(job.Timeout))
+	if err!=nil{
+		if isUniqueViolation(err) {
+			return false, fmt.Errorf("%w: %s", ErrDuplicate, job.ID)
+		}
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	ev := jobEvent(model.EventEnqueued, job, job.CreatedAt)
+	ev.Queue = queue
+	return true, recordEvent(db, ev)
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanJob reads a row selected with jobColumns.
+func scanJob(row rowScanner) (*model.Job, error) {
+	var job model.Job
+	var nextRunAt, expiresAt, deadline sql.NullTime
+	var output, limits, failureReason, payload, workerID, env, onSuccess, onFailure, parentID, batchID, concurrencyKey, lockKey, traceParent, timeout sql.NullString
+	if err := row.Scan(
+		&job.ID,
+		&job.Command,
+		&job.State,
+		&job.Attempts,
+		&job.MaxRetries,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&nextRunAt,
+		&output,
+		&limits,
+		&job.ExitCode,
+		&failureReason,
+		&job.Type,
+		&payload,
+		&workerID,
+		&job.Queue,
+		&env,
+		&onSuccess,
+		&onFailure,
+		&parentID,
+		&batchID,
+		&concurrencyKey,
+		&lockKey,
+		&expiresAt,
+		&deadline,
+		&traceParent,
+		&timeout,
+	); err != nil {
+		return nil, err
+	}
+	if nextRunAt.Valid {
+		job.NextRunAt = nextRunAt.Time
+	}
+	job.Output = output.String
+	job.FailureReason = failureReason.String
+	job.WorkerID = workerID.String
+	job.ParentID = parentID.String
+	job.BatchID = batchID.String
+	job.ConcurrencyKey = concurrencyKey.String
+	job.LockKey = lockKey.String
+	job.TraceParent = traceParent.String
+	job.Timeout = timeout.String
+	if expiresAt.Valid {
+		job.ExpiresAt = &expiresAt.Time
+	}
+	if deadline.Valid {
+		job.Deadline = &deadline.Time
+	}
+	for _, field := range []struct {
+		column sql.NullString
+		dest   any
+	}{
+		{env, &job.Env},
+		{onSuccess, &job.OnSuccess},
+		{onFailure, &job.OnFailure},
+	} {
+		if field.column.Valid && field.column.String != "" {
+			if err := json.Unmarshal([]byte(field.column.String), field.dest); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if payload.Valid {
+		job.Payload = json.RawMessage(payload.String)
+	}
+	if limits.Valid && limits.String != "" {
+		job.Limits = &model.ResourceLimits{}
+		if err := json.Unmarshal([]byte(limits.String), job.Limits); err != nil {
+			return nil, err
+		}
+	}
+	return &job, nil
+}
+
+// marshalLimits encodes per-job limits for the limits column, storing NULL
+// when the job has none.
+func marshalLimits(limits *model.ResourceLimits) (sql.NullString, error) {
+	if limits == nil || limits.IsZero() {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(limits)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// marshalChain encodes a job's env and follow-up templates for their
+// columns, storing NULL for those it does not have.
+func marshalChain(job *model.Job) (env, onSuccess, onFailure sql.NullString, err error) {
+	encode := func(v any, empty bool) (sql.NullString, error) {
+		if empty {
+			return sql.NullString{}, nil
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return sql.NullString{}, err
+		}
+		return sql.NullString{String: string(data), Valid: true}, nil
+	}
+	if env, err = encode(job.Env, len(job.Env) == 0); err != nil {
+		return
+	}
+	if onSuccess, err = encode(job.OnSuccess, job.OnSuccess == nil); err != nil {
+		return
+	}
+	onFailure, err = encode(job.OnFailure, job.OnFailure == nil)
+	return
+}
+
+// nullString stores an empty string as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullTime stores a nil time as NULL.
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
 }
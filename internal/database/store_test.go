@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"path/filepath"
+	"queueCtl/internal/model"
+	"testing"
+	"time"
+)
+
+// newTestStore opens a fresh database in a temporary directory.
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := NewStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Db.Close() })
+	return s
+}
+
+// enqueue prepares and inserts jobs as the enqueue command does. They are
+// created a second apart, in order, after the jobs already in the store
+// and an hour ago, so that they are due and their claim order is fixed.
+func enqueue(t *testing.T, s *Store, jobs ...*model.Job) {
+	t.Helper()
+	var n int
+	if err := s.Db.QueryRow(`select count(*) from jobs`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now().Add(-time.Hour)
+	for i, j := range jobs {
+		if j.Command == "" && (j.Type == "" || j.Type == model.TypeShell) {
+			j.Command = "true"
+		}
+		if err := j.Prepare(start.Add(time.Duration(n+i)*time.Second), 3); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.CreateJob(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// claim claims a shell job for worker and returns its ID, or "" when no
+// job can start.
+func claim(t *testing.T, s *Store, worker string) string {
+	t.Helper()
+	job, err := s.FindAndLock(worker, []string{model.TypeShell})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job == nil {
+		return ""
+	}
+	if job.State != model.StateProcessing || job.WorkerID != worker {
+		t.Fatalf("claimed job %s is %s for %q", job.ID, job.State, job.WorkerID)
+	}
+	return job.ID
+}
+
+// finish moves a processing job to state, as a worker does after running
+// it.
+func finish(t *testing.T, s *Store, id, state string) {
+	t.Helper()
+	job, err := s.GetJob(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.State = state
+	job.UpdatedAt = time.Now()
+	if state == model.StateFailed {
+		job.NextRunAt = job.UpdatedAt.Add(time.Minute)
+	}
+	if err := s.UpdateJob(job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expireLease makes the lease of a processing job look abandoned by its
+// worker.
+func expireLease(t *testing.T, s *Store, id string) {
+	t.Helper()
+	stale := time.Now().Add(-LeaseTimeout - time.Second)
+	if _, err := s.Db.Exec(`update jobs set updated_at = ?, renewed_at = ? where id = ?`, stale, stale, id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// state returns the state of the job with the given ID.
+func state(t *testing.T, s *Store, id string) string {
+	t.Helper()
+	st, err := s.GetJobState(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+// claimAll claims jobs for worker until none can start and returns their
+// IDs.
+func claimAll(t *testing.T, s *Store, worker string) []string {
+	t.Helper()
+	var ids []string
+	for id := claim(t, s, worker); id != ""; id = claim(t, s, worker) {
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -0,0 +1,256 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"queueCtl/internal/model"
+	"strings"
+	"time"
+)
+
+func (s *Store) initThrottles() error {
+	createThrottleTable := `create table if not exists throttles(
+		scope text not null,
+		key text not null,
+		max_concurrency integer not null default 0,
+		rate real not null default 0,
+		burst integer not null default 0,
+		tokens real not null default 0,
+		refilled_at DATETIME not null,
+		primary key (scope, key)
+	);
+	create index if not exists jobs_processing on jobs(state, updated_at) where state = 'processing';`
+	_, err := s.Db.Exec(createThrottleTable)
+	return err
+}
+
+// queryer is satisfied by *sql.DB, *sql.Tx and *sql.Conn.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// SetThrottle creates or replaces the throttle for t's scope and key. The
+// bucket starts full.
+func (s *Store) SetThrottle(t *model.Throttle) error {
+	if t.Scope != model.ScopeQueue && t.Scope != model.ScopeKey {
+		return fmt.Errorf("unknown throttle scope %q", t.Scope)
+	}
+	t.Tokens = float64(t.Burst)
+	t.RefilledAt = time.Now()
+	_, err := s.Db.Exec(`insert or replace into throttles (scope, key, max_concurrency, rate, burst, tokens, refilled_at)
+		values (?,?,?,?,?,?,?)`, t.Scope, t.Key, t.MaxConcurrency, t.Rate, t.Burst, t.Tokens, t.RefilledAt)
+	return err
+}
+
+// DeleteThrottle removes a throttle.
+func (s *Store) DeleteThrottle(scope, key string) error {
+	res, err := s.Db.Exec(`delete from throttles where scope = ? and key = ?`, scope, key)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: throttle for %s %q", ErrNotFound, scope, key)
+	}
+	return nil
+}
+
+// ListThrottles returns every throttle with its bucket as last stored.
+func (s *Store) ListThrottles() ([]model.Throttle, error) {
+	throttles, err := loadThrottles(context.Background(), s.Db)
+	if throttles == nil && err == nil {
+		throttles = []model.Throttle{}
+	}
+	return throttles, err
+}
+
+// insertThrottle inserts a throttle as ListThrottles returned it, unless
+// its queue or key already has one. It reports whether the throttle was
+// inserted.
+func insertThrottle(db execer, t *model.Throttle) (bool, error) {
+	res, err := db.Exec(`insert or ignore into throttles (scope, key, max_concurrency, rate, burst, tokens, refilled_at)
+		values (?,?,?,?,?,?,?)`, t.Scope, t.Key, t.MaxConcurrency, t.Rate, t.Burst, t.Tokens, t.RefilledAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// loadThrottles returns every throttle.
+func loadThrottles(ctx context.Context, q queryer) ([]model.Throttle, error) {
+	rows, err := q.QueryContext(ctx, `select scope, key, max_concurrency, rate, burst, tokens, refilled_at
+		from throttles order by scope, key`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var throttles []model.Throttle
+	for rows.Next() {
+		var t model.Throttle
+		if err := rows.Scan(&t.Scope, &t.Key, &t.MaxConcurrency, &t.Rate, &t.Burst, &t.Tokens, &t.RefilledAt); err != nil {
+			return nil, err
+		}
+		throttles = append(throttles, t)
+	}
+	return throttles, rows.Err()
+}
+
+// runningCounts counts the jobs holding a live lease, per queue and per
+// concurrency key.
+func runningCounts(ctx context.Context, q queryer, now time.Time) (byQueue, byKey map[string]int, err error) {
+	rows, err := q.QueryContext(ctx, `select queue, coalesce(concurrency_key, ''), count(*) from jobs
+		where state = ? and `+leaseStart("jobs")+` > ? group by 1, 2`, model.StateProcessing, now.Add(-LeaseTimeout))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	byQueue, byKey = make(map[string]int), make(map[string]int)
+	for rows.Next() {
+		var queue, key string
+		var n int
+		if err := rows.Scan(&queue, &key, &n); err != nil {
+			return nil, nil, err
+		}
+		byQueue[queue] += n
+		if key != "" {
+			byKey[key] += n
+		}
+	}
+	return byQueue, byKey, rows.Err()
+}
+
+// throttleBlock explains why t lets no job start at now, or returns "".
+func throttleBlock(t *model.Throttle, running int, now time.Time) string {
+	if t.MaxConcurrency > 0 && running >= t.MaxConcurrency {
+		return "concurrency"
+	}
+	if t.Rate > 0 && t.Available(now) < 1 {
+		return "rate"
+	}
+	return ""
+}
+
+// blockedByThrottles returns the queues and concurrency keys whose
+// throttles let no job start at now.
+func blockedByThrottles(ctx context.Context, q queryer, now time.Time) (queues, keys []any, throttles []model.Throttle, err error) {
+	throttles, err = loadThrottles(ctx, q)
+	if err != nil || len(throttles) == 0 {
+		return nil, nil, throttles, err
+	}
+	byQueue, byKey, err := runningCounts(ctx, q, now)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for i := range throttles {
+		t := &throttles[i]
+		if t.Scope == model.ScopeQueue && throttleBlock(t, byQueue[t.Key], now) != "" {
+			queues = append(queues, t.Key)
+		}
+		if t.Scope == model.ScopeKey && throttleBlock(t, byKey[t.Key], now) != "" {
+			keys = append(keys, t.Key)
+		}
+	}
+	return queues, keys, throttles, nil
+}
+
+// throttleWhere is the claim condition excluding blocked queues and keys.
+func throttleWhere(queues, keys []any) (string, []any) {
+	var where []string
+	var args []any
+	if len(queues) > 0 {
+		where = append(where, `queue NOT IN (?`+strings.Repeat(",?", len(queues)-1)+`)`)
+		args = append(args, queues...)
+	}
+	if len(keys) > 0 {
+		where = append(where, `(concurrency_key IS NULL OR concurrency_key NOT IN (?`+strings.Repeat(",?", len(keys)-1)+`))`)
+		args = append(args, keys...)
+	}
+	if len(where) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(where, " AND "), args
+}
+
+// consumeTokens takes one token from each rate-limited throttle that
+// applies to job.
+func consumeTokens(ctx context.Context, q queryer, throttles []model.Throttle, job *model.Job, now time.Time) error {
+	for i := range throttles {
+		t := &throttles[i]
+		applies := (t.Scope == model.ScopeQueue && t.Key == job.Queue) ||
+			(t.Scope == model.ScopeKey && job.ConcurrencyKey != "" && t.Key == job.ConcurrencyKey)
+		if !applies || t.Rate <= 0 {
+			continue
+		}
+		if _, err := q.ExecContext(ctx, `update throttles set tokens = ?, refilled_at = ? where scope = ? and key = ?`,
+			t.Available(now)-1, now, t.Scope, t.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ThrottleStatus is a throttle with its current load.
+type ThrottleStatus struct {
+	model.Throttle
+	Running int `json:"running"`
+	// Waiting counts the jobs that are due to run under this throttle.
+	Waiting int `json:"waiting"`
+	// Blocked is "concurrency" or "rate" while the throttle lets no job
+	// start, and empty otherwise. Waiting jobs are then throttled.
+	Blocked string `json:"blocked,omitempty"`
+}
+
+// ThrottleStatuses reports every throttle's load.
+func (s *Store) ThrottleStatuses() ([]ThrottleStatus, error) {
+	ctx := context.Background()
+	now := time.Now()
+	throttles, err := loadThrottles(ctx, s.Db)
+	if err != nil || len(throttles) == 0 {
+		return []ThrottleStatus{}, err
+	}
+	byQueue, byKey, err := runningCounts(ctx, s.Db, now)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.Db.Query(`select queue, coalesce(concurrency_key, ''), count(*) from jobs
+		where state = ? or (state = ? and next_run_at <= ?) group by 1, 2`,
+		model.StatePending, model.StateFailed, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	waitingQueue, waitingKey := make(map[string]int), make(map[string]int)
+	for rows.Next() {
+		var queue, key string
+		var n int
+		if err := rows.Scan(&queue, &key, &n); err != nil {
+			return nil, err
+		}
+		waitingQueue[queue] += n
+		if key != "" {
+			waitingKey[key] += n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]ThrottleStatus, 0, len(throttles))
+	for _, t := range throttles {
+		st := ThrottleStatus{Throttle: t}
+		if t.Scope == model.ScopeQueue {
+			st.Running, st.Waiting = byQueue[t.Key], waitingQueue[t.Key]
+		} else {
+			st.Running, st.Waiting = byKey[t.Key], waitingKey[t.Key]
+		}
+		st.Blocked = throttleBlock(&t, st.Running, now)
+		if t.Rate > 0 {
+			st.Tokens = t.Available(now)
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
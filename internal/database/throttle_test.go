@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"queueCtl/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestClaimQueueConcurrency(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SetThrottle(&model.Throttle{Scope: model.ScopeQueue, Key: "slow", MaxConcurrency: 1}); err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, s,
+		&model.Job{ID: "slow-1", Queue: "slow"},
+		&model.Job{ID: "slow-2", Queue: "slow"},
+		&model.Job{ID: "fast-1"},
+	)
+
+	if got := claimAll(t, s, "w1"); !slices.Equal(got, []string{"slow-1", "fast-1"}) {
+		t.Fatalf("claimed %v, want slow-1 and fast-1 past the throttled slow-2", got)
+	}
+	statuses, err := s.ThrottleStatuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || statuses[0].Running != 1 || statuses[0].Waiting != 1 || statuses[0].Blocked != "concurrency" {
+		t.Errorf("status = %+v, want 1 running, 1 waiting, blocked by concurrency", statuses)
+	}
+
+	finish(t, s, "slow-1", model.StateCompleted)
+	if got := claim(t, s, "w1"); got != "slow-2" {
+		t.Errorf("claimed %q once slow-1 completed, want slow-2", got)
+	}
+}
+
+func TestClaimKeyConcurrencyAcrossQueues(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SetThrottle(&model.Throttle{Scope: model.ScopeKey, Key: "api", MaxConcurrency: 2}); err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, s,
+		&model.Job{ID: "a", Queue: "emails", ConcurrencyKey: "api"},
+		&model.Job{ID: "b", Queue: "reports", ConcurrencyKey: "api"},
+		&model.Job{ID: "c", Queue: "emails", ConcurrencyKey: "api"},
+		&model.Job{ID: "d", Queue: "emails"},
+	)
+
+	if got := claimAll(t, s, "w1"); !slices.Equal(got, []string{"a", "b", "d"}) {
+		t.Fatalf("claimed %v, want a, b and d", got)
+	}
+	finish(t, s, "b", model.StateFailed)
+	if got := claim(t, s, "w1"); got != "c" {
+		t.Errorf("claimed %q once b failed, want c", got)
+	}
+}
+
+func TestClaimStaleLeaseDoesNotCount(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SetThrottle(&model.Throttle{Scope: model.ScopeQueue, Key: "default", MaxConcurrency: 1}); err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, s, &model.Job{ID: "a"}, &model.Job{ID: "b"})
+	if got := claim(t, s, "w1"); got != "a" {
+		t.Fatalf("claimed %q, want a", got)
+	}
+	if got := claim(t, s, "w2"); got != "" {
+		t.Fatalf("claimed %q while a holds the only slot", got)
+	}
+
+	// w1 is gone: its job is reclaimed, and the throttle does not count it
+	// twice.
+	expireLease(t, s, "a")
+	if got := claim(t, s, "w2"); got != "a" {
+		t.Fatalf("claimed %q after a's lease ran out, want a", got)
+	}
+	if got := claim(t, s, "w2"); got != "" {
+		t.Errorf("claimed %q while the reclaimed a holds the only slot", got)
+	}
+}
+
+func TestClaimRateLimit(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SetThrottle(&model.Throttle{Scope: model.ScopeQueue, Key: "default", Rate: 0.01, Burst: 2}); err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, s, &model.Job{ID: "a"}, &model.Job{ID: "b"}, &model.Job{ID: "c"})
+
+	if got := claimAll(t, s, "w1"); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("claimed %v, want the burst of a and b", got)
+	}
+	statuses, err := s.ThrottleStatuses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st := statuses[0]; st.Blocked != "rate" || st.Tokens >= 1 || st.Waiting != 1 {
+		t.Errorf("status = %+v, want blocked by rate with an empty bucket", st)
+	}
+
+	// A token refills every 100s at 0.01/s.
+	if _, err := s.Db.Exec(`update throttles set refilled_at = ?`, time.Now().Add(-101*time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if got := claim(t, s, "w1"); got != "c" {
+		t.Errorf("claimed %q once a token refilled, want c", got)
+	}
+	if got := claim(t, s, "w1"); got != "" {
+		t.Errorf("claimed %q with the bucket empty again", got)
+	}
+}
+
+func TestClaimKeyRateLimitSkipsOtherJobs(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SetThrottle(&model.Throttle{Scope: model.ScopeKey, Key: "api", Rate: 0.01, Burst: 1}); err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, s,
+		&model.Job{ID: "a", ConcurrencyKey: "api"},
+		&model.Job{ID: "b", ConcurrencyKey: "api"},
+		&model.Job{ID: "c"},
+		&model.Job{ID: "d", ConcurrencyKey: "other"},
+	)
+	if got := claimAll(t, s, "w1"); !slices.Equal(got, []string{"a", "c", "d"}) {
+		t.Errorf("claimed %v, want a, c and d", got)
+	}
+}
+
+func TestDeleteThrottle(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SetThrottle(&model.Throttle{Scope: model.ScopeQueue, Key: "default", MaxConcurrency: 1}); err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, s, &model.Job{ID: "a"}, &model.Job{ID: "b"})
+	claim(t, s, "w1")
+	if err := s.DeleteThrottle(model.ScopeQueue, "default"); err != nil {
+		t.Fatal(err)
+	}
+	if got := claim(t, s, "w1"); got != "b" {
+		t.Errorf("claimed %q once the throttle was deleted, want b", got)
+	}
+	if err := s.DeleteThrottle(model.ScopeQueue, "default"); err == nil {
+		t.Error("deleting a missing throttle succeeded")
+	}
+	if err := s.SetThrottle(&model.Throttle{Scope: "host", Key: "x"}); err == nil {
+		t.Error("a throttle with an unknown scope was saved")
+	}
+}
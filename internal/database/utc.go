@@ -0,0 +1,159 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// The SQLite driver writes a time as text in the time's own location, and
+// that text is what comparisons and ORDER BY see. Times written either side
+// of a DST change would then not sort in time order, so the store writes
+// every time in UTC. Times are read back in the local time zone.
+
+// utcConnector opens SQLite connections that write times in UTC.
+type utcConnector struct {
+	dsn string
+}
+
+func (c utcConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.Driver().Open(c.dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &utcConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+func (c utcConnector) Driver() driver.Driver {
+	return &sqlite3.SQLiteDriver{}
+}
+
+// utcConn is a SQLite connection that converts time arguments to UTC.
+type utcConn struct {
+	*sqlite3.SQLiteConn
+}
+
+// CheckNamedValue converts times, and valuers yielding times, to UTC. The
+// usual conversion then applies.
+func (c *utcConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if v, ok := nv.Value.(driver.Valuer); ok {
+		value, err := v.Value()
+		if err != nil {
+			return err
+		}
+		nv.Value = value
+	}
+	switch t := nv.Value.(type) {
+	case time.Time:
+		nv.Value = t.UTC()
+	case *time.Time:
+		if t != nil {
+			nv.Value = t.UTC()
+		}
+	}
+	return driver.ErrSkip
+}
+
+// sqliteConn returns the SQLite connection behind a raw driver connection.
+func sqliteConn(conn any) (*sqlite3.SQLiteConn, bool) {
+	if c, ok := conn.(*utcConn); ok {
+		return c.SQLiteConn, true
+	}
+	c, ok := conn.(*sqlite3.SQLiteConn)
+	return c, ok
+}
+
+// utcVersion is the user_version of databases whose times are all in UTC.
+const utcVersion = 1
+
+// utcColumns are the time columns rewritten in UTC when an older database
+// is opened. The audit log is append-only and keeps its times as written.
+var utcColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"jobs", []string{"created_at", "updated_at", "next_run_at", "expires_at", "deadline", "renewed_at"}},
+	{"job_attempts", []string{"started_at", "finished_at"}},
+	{"batches", []string{"created_at", "finished_at"}},
+	{"events", []string{"at", "next_run_at"}},
+	{"throttles", []string{"refilled_at"}},
+}
+
+// convertTimesToUTC rewrites the times of a database written before times
+// were stored in UTC.
+func (s *Store) convertTimesToUTC() error {
+	var version int
+	if err := s.Db.QueryRow(`pragma user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version >= utcVersion {
+		return nil
+	}
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, t := range utcColumns {
+		if err := convertTableToUTC(tx, t.table, t.columns); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`pragma user_version = %d`, utcVersion)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func convertTableToUTC(tx *sql.Tx, table string, columns []string) error {
+	var list, set string
+	for i, c := range columns {
+		if i > 0 {
+			list += ", "
+			set += ", "
+		}
+		list += c
+		set += c + " = ?"
+	}
+	rows, err := tx.Query(`select rowid, ` + list + ` from ` + table)
+	if err != nil {
+		return err
+	}
+	type row struct {
+		id    int64
+		times []sql.NullTime
+	}
+	var all []row
+	for rows.Next() {
+		r := row{times: make([]sql.NullTime, len(columns))}
+		dest := []any{&r.id}
+		for i := range r.times {
+			dest = append(dest, &r.times[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, r := range all {
+		args := make([]any, 0, len(columns)+1)
+		for _, t := range r.times {
+			args = append(args, t)
+		}
+		args = append(args, r.id)
+		if _, err := tx.Exec(`update `+table+` set `+set+` where rowid = ?`, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,129 +1,393 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"queueCtl/internal/model"
+	"strings"
 	"time"
 )
 
-func (s *Store) FindAndLock() (*model.Job, error) {
+// LeaseTimeout is how long a processing job's lease lasts. The worker
+// running a job renews it with RenewLease; once it runs out, the worker is
+// taken to be gone and another one may claim the job again.
+const LeaseTimeout = 5 * time.Minute
+
+// leaseStart is the SQL expression for when the lease of a processing job
+// in table last started: its last renewal, or else its claim.
+func leaseStart(table string) string {
+	return "coalesce(" + table + ".renewed_at, " + table + ".updated_at)"
+}
+
+// CountLeased counts the processing jobs holding a live lease: the jobs
+// some worker is running.
+func (s *Store) CountLeased() (int, error) {
+	var n int
+	err := s.Db.QueryRow(`select count(*) from jobs where state = ? and `+leaseStart("jobs")+` > ?`,
+		model.StateProcessing, time.Now().Add(-LeaseTimeout)).Scan(&n)
+	return n, err
+}
+
+// FindAndLock claims the oldest runnable job whose type is one of types on
+// behalf of the worker identified by workerID. Jobs whose queue or
+// concurrency key is held back by a throttle, or whose lock key is taken,
+// are skipped. The throttles and locks are checked, and tokens taken, in
+// the same write transaction as the claim, so the limits hold across every
+// worker sharing the database.
+func (s *Store) FindAndLock(workerID string, types []string) (*model.Job, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+	ctx := context.Background()
+	conn, err := s.Db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		if isLocked(err) {
+			s.noteLocked(true)
+			slog.Debug("database busy, claim skipped", "worker_id", workerID)
+			return nil, nil // Not an error, just try again later
+		}
+		return nil, err
+	}
+	s.noteLocked(false)
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, "ROLLBACK")
+		}
+	}()
+
+	now := time.Now()
+	blockedQueues, blockedKeys, throttles, err := blockedByThrottles(ctx, conn, now)
+	if err != nil {
+		return nil, err
+	}
+	throttled, throttleArgs := throttleWhere(blockedQueues, blockedKeys)
+	locked, lockArgs := lockWhere(now)
+	expired, expiryArgs := expiryWhere(now)
+
 	findSQL := `
 	UPDATE jobs SET
 		state = ?,
 		updated_at = ?,
+		renewed_at = ?,
+		worker_id = ?,
+		output = null,
 		attempts = attempts + 1
 	WHERE id = (
 		SELECT id FROM jobs
 		WHERE
+			(
 			state = ?
 			OR
 			(state = ? AND next_run_at <= ?)
 			OR
-			(state = ? AND updated_at <= ?)
+			(state = ? AND ` + leaseStart("jobs") + ` <= ?)
+			)
+			AND type IN (?` + strings.Repeat(",?", len(types)-1) + `)` + expired + throttled + locked + `
 		ORDER BY created_at ASC
 		LIMIT 1
 	)
-	RETURNING id, command, state, attempts, max_retries, created_at, updated_at, next_run_at, output
+	RETURNING ` + jobColumns + `
 	`
-	const JobTimeout = 5 * time.Minute
 
-	var job model.Job
-	var nextRunAtStr sql.NullString
-	var outputStr sql.NullString
-	now := time.Now()
-
-	err := s.Db.QueryRow(findSQL,
+	args := []any{
 		model.StateProcessing, // SET state
 		now,                    // SET updated_at
+		now,                    // SET renewed_at
+		workerID,               // SET worker_id
 		
 		model.StatePending,    // WHERE state = 'pending'
 		model.StateFailed,     // OR state = 'failed'
 		now,
 		model.StateProcessing, // OR state = 'processing'
-		now.Add(-JobTimeout),
-	).Scan(
-		&job.ID,
-		&job.Command,
-		&job.State,
-		&job.Attempts,
-		&job.MaxRetries,
-		&job.CreatedAt,
-		&job.UpdatedAt,
-		&nextRunAtStr,
-		&outputStr,
-	)
+		now.Add(-LeaseTimeout),
+	}
+	for _, t := range types {
+		args = append(args, t)
+	}
+	args = append(args, expiryArgs...)
+	args = append(args, throttleArgs...)
+	args = append(args, lockArgs...)
 
+	job, err := scanJob(conn.QueryRowContext(ctx, findSQL, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if isLocked(err) {
+			s.noteLocked(true)
+			slog.Debug("database busy, claim skipped", "worker_id", workerID)
 			return nil, nil
-		} else {
-			if err.Error() == "database is locked" {
-				return nil, nil // Not an error, just try again later
-			}
-			return nil, err
 		}
+		return nil, err
 	}
-
-	if nextRunAtStr.Valid {
-		// This layout matches the format SQLite is storing: "YYYY-MM-DD HH:MM:SS.NNNNNNNNN+ZZ:ZZ"
-		const sqliteTimeLayout = time.RFC3339Nano
-
-		t, err := time.Parse(sqliteTimeLayout, nextRunAtStr.String)
-		if err == nil {
-			job.NextRunAt = t
-		}
-		if err != nil {
-			log.Println(err)
+	if err := consumeTokens(ctx, conn, throttles, job, now); err != nil {
+		return nil, err
+	}
+	if err := recordEvent(connExecer{ctx, conn}, jobEvent(model.EventClaimed, job, now)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		if isLocked(err) {
+			s.noteLocked(true)
+			slog.Debug("database busy, claim abandoned", "worker_id", workerID, "job_id", job.ID)
+			return nil, nil
 		}
+		return nil, err
 	}
-	if outputStr.Valid {
-		job.Output = outputStr.String
+	committed = true
+	slog.Debug("job claimed", "worker_id", workerID, "job_id", job.ID, "queue", job.Queue, "attempt", job.Attempts)
+	return job, nil
+}
+
+// isLocked reports whether err is SQLite giving up on a busy database.
+func isLocked(err error) bool {
+	return strings.Contains(err.Error(), "database is locked")
+}
+
+// noteLocked records whether a claim found the database locked.
+func (s *Store) noteLocked(locked bool) {
+	if !locked {
+		s.lockedSince.Store(0)
+		return
 	}
+	s.lockedSince.CompareAndSwap(0, time.Now().UnixNano())
+}
 
-	return &job, nil
+// LockedSince returns when this process's claims started finding the
+// database locked, every one of them since; it is zero once a claim gets
+// the write lock.
+func (s *Store) LockedSince() time.Time {
+	if ns := s.lockedSince.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
 }
 
-// UpdateJob saves all fields of a job after execution.
-func (s *Store) UpdateJob(job *model.Job) error {
+// UpdateJob saves all fields of a job after execution. It only applies
+// while the job is still processing (or already in the new state), so a job
+// canceled mid-run is not brought back; that case returns ErrInvalidState.
+// It also only applies while job.WorkerID still holds the job: a worker
+// whose lease ran out and whose job another worker took over gets
+// ErrLeaseLost, and the attempt that now owns the job keeps its outcome.
+// followUps are enqueued in the same transaction, so they exist exactly
+// when the new state does; see enqueueFollowUps.
+func (s *Store) UpdateJob(job *model.Job, followUps ...*model.Job) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	updateSQL := `UPDATE jobs SET 
 	                  state = ?, 
 	                  attempts = ?, 
 	                  updated_at = ?, 
 	                  next_run_at = ?,
-					  output = ?
-	              WHERE id = ?`
-	_, err := s.Db.Exec(updateSQL,
+					  output = ?,
+					  exit_code = ?,
+					  failure_reason = ?
+	              WHERE id = ? AND state IN (?, ?) AND worker_id = ?`
+	res, err := tx.Exec(updateSQL,
 		job.State,
 		job.Attempts,
 		job.UpdatedAt,
 		job.NextRunAt,
 		job.Output,
+		job.ExitCode,
+		job.FailureReason,
 		job.ID,
+		model.StateProcessing,
+		job.State,
+		job.WorkerID,
 	)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
+		tx.Rollback()
+		var workerID string
+		err := s.Db.QueryRow(`SELECT coalesce(worker_id, '') FROM jobs WHERE id = ?`, job.ID).Scan(&workerID)
+		if err == nil && workerID != job.WorkerID {
+			return fmt.Errorf("%w: job '%s' is now held by %q", ErrLeaseLost, job.ID, workerID)
+		}
+		return s.explainMiss(job.ID, model.StateProcessing)
+	}
+	if err := recordEvent(tx, jobEvent(model.StateEvent(job.State), job, job.UpdatedAt)); err != nil {
+		return err
+	}
+	if err := enqueueFollowUps(tx, job, followUps); err != nil {
+		return err
+	}
+	if job.BatchID != "" && model.IsTerminal(job.State) {
+		if err := finishBatch(tx, job.BatchID, time.Now()); err != nil {
+			return fmt.Errorf("finishing batch %s: %w", job.BatchID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// RenewLease restarts the lease of a job that workerID is running. It does
+// nothing once the job has left processing or been claimed by another
+// worker. updated_at keeps the time of the claim.
+func (s *Store) RenewLease(jobID, workerID string) error {
+	_, err := s.Db.Exec(`update jobs set renewed_at = ? where id = ? and state = ? and worker_id = ?`,
+		time.Now(), jobID, model.StateProcessing, workerID)
+	return err
+}
+
+// SaveOutput stores the output a job has written so far, while workerID is
+// running it.
+func (s *Store) SaveOutput(jobID, workerID, output string) error {
+	_, err := s.Db.Exec(`update jobs set output = ? where id = ? and state = ? and worker_id = ?`,
+		output, jobID, model.StateProcessing, workerID)
 	return err
 }
 
+// enqueueFollowUps enqueues the follow-ups of parent, which just reached
+// its new state. After a retry from the DLQ the default follow-up ID can
+// be taken by the follow-up of an earlier attempt; the follow-up then gets
+// the first free ID with ".2", ".3"... appended. A follow-up whose template
+// names a taken ID is not enqueued, which is logged and recorded as a
+// follow_up_skipped event of parent.
+func enqueueFollowUps(tx *sql.Tx, parent *model.Job, followUps []*model.Job) error {
+	for _, f := range followUps {
+		if base := model.FollowUpID(parent.ID, parent.State); f.ID == base {
+			id, err := freeID(tx, base)
+			if err != nil {
+				return err
+			}
+			f.ID = id
+		}
+		inserted, err := insertNewJob(tx, f, true)
+		if err != nil {
+			return fmt.Errorf("enqueueing follow-up %s: %w", f.ID, err)
+		}
+		if inserted {
+			slog.Info("follow-up enqueued", "job_id", parent.ID, "follow_up_id", f.ID)
+			continue
+		}
+		slog.Warn("follow-up not enqueued, a job with its ID already exists", "job_id", parent.ID, "follow_up_id", f.ID)
+		ev := jobEvent(model.EventFollowUpSkipped, parent, f.CreatedAt)
+		ev.FollowUpID = f.ID
+		if err := recordEvent(tx, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// freeID returns base, or when a job already has that ID, the first of
+// base.2, base.3... that is free.
+func freeID(tx *sql.Tx, base string) (string, error) {
+	id := base
+	for n := 2; ; n++ {
+		var taken bool
+		if err := tx.QueryRow(`select exists(select 1 from jobs where id = ?)`, id).Scan(&taken); err != nil {
+			return "", err
+		}
+		if !taken {
+			return id, nil
+		}
+		id = fmt.Sprintf("%s.%d", base, n)
+	}
+}
+
+// RetryDeadJob moves a dead job back to pending, as retrySet describes.
 func (s *Store) RetryDeadJob(jobID string) error {
-	// We reset the state, attempts, and next_run_at time
-	sql := `UPDATE jobs SET state = ?, attempts = 0, next_run_at = ?
-	        WHERE id = ? AND state = ?`
-	
-	res, err := s.Db.Exec(sql,
-		model.StatePending,
-		time.Now(),
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	set, args := retrySet(now)
+	statement := `UPDATE jobs SET ` + set + `
+	        WHERE id = ? AND state = ?
+	        RETURNING queue`
+	var queue string
+	err = tx.QueryRow(statement, append(args, jobID, model.StateDead)...).Scan(&queue)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return s.explainMiss(jobID, "dead")
+	}
+	if err != nil {
+		return err
+	}
+	ev := &model.Event{At: now, Type: model.EventRequeued, JobID: jobID, Queue: queue, State: model.StatePending}
+	if err := recordEvent(tx, ev); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CancelJob stops a job from running again. Pending and failed jobs are
+// canceled straight away; a processing job is marked canceled and the
+// worker running it kills it when it next checks, which may be after it has
+// already finished.
+func (s *Store) CancelJob(jobID string) error {
+	tx, err := s.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	statement := `UPDATE jobs SET state = ?, updated_at = ?
+	        WHERE id = ? AND state IN (?, ?, ?)
+	        RETURNING coalesce(batch_id, ''), queue`
+	var batchID, queue string
+	err = tx.QueryRow(statement,
+		model.StateCanceled,
+		now,
 		jobID,
-		model.StateDead,
-	)
+		model.StatePending,
+		model.StateFailed,
+		model.StateProcessing,
+	).Scan(&batchID, &queue)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return s.explainMiss(jobID, "pending, failed or processing")
+	}
 	if err != nil {
 		return err
 	}
+	ev := &model.Event{At: now, Type: model.EventCanceled, JobID: jobID, Queue: queue, State: model.StateCanceled}
+	if err := recordEvent(tx, ev); err != nil {
+		return err
+	}
+	if batchID != "" {
+		if err := finishBatch(tx, batchID, now); err != nil {
+			return fmt.Errorf("finishing batch %s: %w", batchID, err)
+		}
+	}
+	return tx.Commit()
+}
 
-	rowsAffected, _ := res.RowsAffected()
-	if rowsAffected == 0 {
-		return fmt.Errorf("no job found with ID '%s' in the dead state", jobID)
+// GetJobState returns only the state of a job, for cheap polling.
+func (s *Store) GetJobState(jobID string) (string, error) {
+	var state string
+	err := s.Db.QueryRow(`SELECT state FROM jobs WHERE id = ?`, jobID).Scan(&state)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("%w: %s", ErrNotFound, jobID)
 	}
-	
-	return nil
+	return state, err
+}
+
+// explainMiss turns an update that matched no rows into ErrNotFound or
+// ErrInvalidState.
+func (s *Store) explainMiss(jobID, wanted string) error {
+	state, err := s.GetJobState(jobID)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: job '%s' is %s, expected %s", ErrInvalidState, jobID, state, wanted)
 }
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"queueCtl/internal/model"
+	"testing"
+	"time"
+)
+
+func TestClaimOrderAndTypes(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s,
+		&model.Job{ID: "a"},
+		&model.Job{ID: "email", Type: "email"},
+		&model.Job{ID: "b"},
+	)
+	if got := claim(t, s, "w1"); got != "a" {
+		t.Fatalf("claimed %q, want the oldest job a", got)
+	}
+	job, err := s.FindAndLock("w2", []string{"email"})
+	if err != nil || job == nil || job.ID != "email" || job.Attempts != 1 {
+		t.Fatalf("claimed %+v, %v for the email type", job, err)
+	}
+	if job, err := s.FindAndLock("w2", nil); job != nil || err != nil {
+		t.Errorf("claimed %+v, %v with no types", job, err)
+	}
+
+	// A failed job waits for next_run_at.
+	finish(t, s, "a", model.StateFailed)
+	if got := claimAll(t, s, "w1"); len(got) != 1 || got[0] != "b" {
+		t.Errorf("claimed %v, want only b while a waits to retry", got)
+	}
+}
+
+func TestRenewLease(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "a"})
+	claim(t, s, "w1")
+	claimed, err := s.GetJob("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The job outlives LeaseTimeout while its worker renews the lease.
+	expireLease(t, s, "a")
+	if err := s.RenewLease("a", "w1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := claim(t, s, "w2"); got != "" {
+		t.Fatalf("w2 claimed %q, whose lease w1 renewed", got)
+	}
+	if n, err := s.CountLeased(); err != nil || n != 1 {
+		t.Errorf("CountLeased = %d, %v, want 1", n, err)
+	}
+	renewed, err := s.GetJob("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !renewed.UpdatedAt.Before(claimed.UpdatedAt) {
+		t.Errorf("updated_at moved to %v on renewal", renewed.UpdatedAt)
+	}
+
+	// Once the lease runs out, another worker takes the job over and the
+	// first one can no longer renew it.
+	expireLease(t, s, "a")
+	if n, _ := s.CountLeased(); n != 0 {
+		t.Errorf("CountLeased = %d with the lease run out", n)
+	}
+	if got := claim(t, s, "w2"); got != "a" {
+		t.Fatalf("w2 claimed %q, want a", got)
+	}
+	expireLease(t, s, "a")
+	if err := s.RenewLease("a", "w1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := claim(t, s, "w3"); got != "a" {
+		t.Errorf("w3 claimed %q, want a: w1 renewed a lease it no longer held", got)
+	}
+}
+
+func TestUpdateJobAfterLosingLease(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "a"})
+	claim(t, s, "w1")
+	stalled, err := s.GetJob("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expireLease(t, s, "a")
+	if got := claim(t, s, "w2"); got != "a" {
+		t.Fatalf("w2 claimed %q, want a", got)
+	}
+
+	// w1 wakes up and tries to save the outcome of its attempt.
+	stalled.State, stalled.Output, stalled.UpdatedAt = model.StateCompleted, "stale\n", time.Now()
+	if err := s.UpdateJob(stalled); !errors.Is(err, ErrLeaseLost) {
+		t.Fatalf("UpdateJob from the stalled worker = %v, want ErrLeaseLost", err)
+	}
+	job, err := s.GetJob("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != model.StateProcessing || job.WorkerID != "w2" || job.Attempts != 2 || job.Output != "" {
+		t.Errorf("job after the stale update = %+v", job)
+	}
+	finish(t, s, "a", model.StateFailed)
+	if got := state(t, s, "a"); got != model.StateFailed {
+		t.Errorf("a is %s, want w2's outcome", got)
+	}
+}
+
+func TestSaveOutput(t *testing.T) {
+	s := newTestStore(t)
+	enqueue(t, s, &model.Job{ID: "a"})
+	claim(t, s, "w1")
+	if err := s.SaveOutput("a", "w1", "half way\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveOutput("a", "w2", "not mine\n"); err != nil {
+		t.Fatal(err)
+	}
+	if job, _ := s.GetJob("a"); job.Output != "half way\n" {
+		t.Errorf("output = %q, want w1's", job.Output)
+	}
+
+	// A new attempt starts with no output.
+	finish(t, s, "a", model.StateFailed)
+	if _, err := s.Db.Exec(`update jobs set next_run_at = ?`, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	claim(t, s, "w1")
+	if job, _ := s.GetJob("a"); job.Output != "" {
+		t.Errorf("output = %q after a new claim", job.Output)
+	}
+}
+
+func TestLockedSince(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits out the busy timeout")
+	}
+	path := filepath.Join(t.TempDir(), "queue.db")
+	s, err := NewStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Db.Close()
+	enqueue(t, s, &model.Job{ID: "a"})
+
+	// Another process holds the write lock past the busy timeout.
+	other, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	conn, err := other.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now()
+	if got := claim(t, s, "w1"); got != "" {
+		t.Fatalf("claimed %q through the lock", got)
+	}
+	since := s.LockedSince()
+	if since.Before(before) || since.After(time.Now()) {
+		t.Errorf("LockedSince = %v, want the time of the blocked claim", since)
+	}
+	if got := claim(t, s, "w1"); got != "" || !s.LockedSince().Equal(since) {
+		t.Errorf("second blocked claim moved LockedSince to %v", s.LockedSince())
+	}
+
+	if _, err := conn.ExecContext(context.Background(), "ROLLBACK"); err != nil {
+		t.Fatal(err)
+	}
+	if got := claim(t, s, "w1"); got != "a" || !s.LockedSince().IsZero() {
+		t.Errorf("claimed %q with LockedSince %v once the lock was released", got, s.LockedSince())
+	}
+}
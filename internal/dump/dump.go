@@ -0,0 +1,294 @@
+// Package dump reads and writes the portable queue dump: JSON lines with a
+// header, the configuration, one line per throttle and per batch, one line
+// per job with its attempt history, and an end marker that records how many jobs came before it, so a truncated
+// dump is detected. The format does not depend on SQLite and is the way to
+// move a queue between hosts or storage backends.
+package dump
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"queueCtl/internal/config"
+	"queueCtl/internal/model"
+	"slices"
+	"time"
+)
+
+// Format identifies a queuectl dump in its header.
+const Format = "queuectl-dump"
+
+// Version is the dump version this package writes. Dumps with a higher
+// version are rejected. Version 2 added batch and throttle records.
+const Version = 2
+
+// Record kinds, in the order they appear in a dump.
+const (
+	KindHeader   = "header"
+	KindConfig   = "config"
+	KindThrottle = "throttle"
+	KindBatch    = "batch"
+	KindJob      = "job"
+	KindEnd      = "end"
+)
+
+// Record is one line of a dump. Which fields are set depends on Kind.
+type Record struct {
+	Kind string `json:"kind"`
+
+	// header
+	Format     string     `json:"format,omitempty"`
+	Version    int        `json:"version,omitempty"`
+	ExportedAt *time.Time `json:"exported_at,omitempty"`
+
+	// config
+	Config *config.Config `json:"config,omitempty"`
+	// Redacted is set when the notifier secrets and tracing headers in
+	// Config were replaced by placeholders.
+	Redacted bool `json:"redacted,omitempty"`
+
+	// throttle
+	Throttle *model.Throttle `json:"throttle,omitempty"`
+
+	// batch
+	Batch *model.Batch `json:"batch,omitempty"`
+
+	// job
+	Job      *model.Job      `json:"job,omitempty"`
+	Attempts []model.Attempt `json:"attempts,omitempty"`
+
+	// end
+	Jobs *int `json:"jobs,omitempty"`
+}
+
+// Writer writes a dump.
+type Writer struct {
+	enc  *json.Encoder
+	jobs int
+}
+
+// NewWriter writes the header to w and returns a Writer for the rest.
+func NewWriter(w io.Writer, exportedAt time.Time) (*Writer, error) {
+	dw := &Writer{enc: json.NewEncoder(w)}
+	return dw, dw.enc.Encode(Record{Kind: KindHeader, Format: Format, Version: Version, ExportedAt: &exportedAt})
+}
+
+// WriteConfig writes the configuration, marked as redacted if its secrets
+// were taken out. It must come before any job.
+func (w *Writer) WriteConfig(cfg *config.Config, redacted bool) error {
+	return w.enc.Encode(Record{Kind: KindConfig, Config: cfg, Redacted: redacted})
+}
+
+// WriteThrottle writes one throttle with its bucket.
+func (w *Writer) WriteThrottle(t model.Throttle) error {
+	return w.enc.Encode(Record{Kind: KindThrottle, Throttle: &t})
+}
+
+// WriteBatch writes one batch, with the members counted on it and its
+// callback. Batches must come before the jobs.
+func (w *Writer) WriteBatch(b model.Batch) error {
+	return w.enc.Encode(Record{Kind: KindBatch, Batch: &b})
+}
+
+// WriteJob writes one job and its attempts.
+func (w *Writer) WriteJob(r model.JobRecord) error {
+	w.jobs++
+	return w.enc.Encode(Record{Kind: KindJob, Job: &r.Job, Attempts: r.Attempts})
+}
+
+// Close writes the end marker. It returns the number of jobs written.
+func (w *Writer) Close() (int, error) {
+	n := w.jobs
+	return n, w.enc.Encode(Record{Kind: KindEnd, Jobs: &n})
+}
+
+// Dump is a fully read and validated dump.
+type Dump struct {
+	Version    int
+	ExportedAt time.Time
+	// Config is nil when the dump has no config record.
+	Config *config.Config
+	// ConfigRedacted is set when Config holds placeholders instead of
+	// secrets.
+	ConfigRedacted bool
+	Throttles      []model.Throttle
+	Batches        []model.Batch
+	Jobs           []model.JobRecord
+}
+
+// Read reads and validates a whole dump. Errors name the offending line.
+func Read(r io.Reader) (*Dump, error) {
+	var d Dump
+	seen := make(map[string]bool)
+	seenBatches := make(map[string]bool)
+	seenThrottles := make(map[[2]string]bool)
+	ended := false
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if ended {
+			return nil, fmt.Errorf("line %d: data after the end marker", line)
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if line == 1 && rec.Kind != KindHeader {
+			return nil, errors.New("line 1: not a queuectl dump (no header)")
+		}
+
+		switch rec.Kind {
+		case KindHeader:
+			if line != 1 {
+				return nil, fmt.Errorf("line %d: unexpected header", line)
+			}
+			if rec.Format != Format {
+				return nil, fmt.Errorf("line 1: unknown format %q", rec.Format)
+			}
+			if rec.Version < 1 || rec.Version > Version {
+				return nil, fmt.Errorf("line 1: dump version %d is not supported (this queuectl reads up to %d)", rec.Version, Version)
+			}
+			d.Version = rec.Version
+			if rec.ExportedAt != nil {
+				d.ExportedAt = *rec.ExportedAt
+			}
+		case KindConfig:
+			if rec.Config == nil {
+				return nil, fmt.Errorf("line %d: config record without config", line)
+			}
+			d.Config = rec.Config
+			d.ConfigRedacted = rec.Redacted
+		case KindThrottle:
+			if rec.Throttle == nil {
+				return nil, fmt.Errorf("line %d: throttle record without throttle", line)
+			}
+			if err := validateThrottle(rec.Throttle); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			target := [2]string{rec.Throttle.Scope, rec.Throttle.Key}
+			if seenThrottles[target] {
+				return nil, fmt.Errorf("line %d: throttle for %s %q appears twice", line, rec.Throttle.Scope, rec.Throttle.Key)
+			}
+			seenThrottles[target] = true
+			d.Throttles = append(d.Throttles, *rec.Throttle)
+		case KindBatch:
+			if rec.Batch == nil {
+				return nil, fmt.Errorf("line %d: batch record without batch", line)
+			}
+			if err := validateBatch(rec.Batch); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if seenBatches[rec.Batch.ID] {
+				return nil, fmt.Errorf("line %d: batch %s appears twice", line, rec.Batch.ID)
+			}
+			seenBatches[rec.Batch.ID] = true
+			d.Batches = append(d.Batches, *rec.Batch)
+		case KindJob:
+			if rec.Job == nil {
+				return nil, fmt.Errorf("line %d: job record without job", line)
+			}
+			if err := validateJob(rec.Job, rec.Attempts); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if seen[rec.Job.ID] {
+				return nil, fmt.Errorf("line %d: job %s appears twice", line, rec.Job.ID)
+			}
+			seen[rec.Job.ID] = true
+			d.Jobs = append(d.Jobs, model.JobRecord{Job: *rec.Job, Attempts: rec.Attempts})
+		case KindEnd:
+			if rec.Jobs == nil || *rec.Jobs != len(d.Jobs) {
+				return nil, fmt.Errorf("line %d: end marker does not match the %d job(s) read", line, len(d.Jobs))
+			}
+			ended = true
+		default:
+			return nil, fmt.Errorf("line %d: unknown record kind %q", line, rec.Kind)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if line == 0 {
+		return nil, errors.New("dump is empty")
+	}
+	if !ended {
+		return nil, errors.New("dump is truncated (no end marker)")
+	}
+	return &d, nil
+}
+
+// validateThrottle checks that a dumped throttle could have been set with
+// 'throttle set'.
+func validateThrottle(t *model.Throttle) error {
+	switch {
+	case t.Scope != model.ScopeQueue && t.Scope != model.ScopeKey:
+		return fmt.Errorf("throttle: unknown scope %q", t.Scope)
+	case t.Key == "":
+		return fmt.Errorf("throttle for %s has no key", t.Scope)
+	case t.MaxConcurrency < 0 || t.Rate < 0 || t.Burst < 0:
+		return fmt.Errorf("throttle for %s %q: negative limit", t.Scope, t.Key)
+	case t.MaxConcurrency == 0 && t.Rate == 0:
+		return fmt.Errorf("throttle for %s %q: no rate or concurrency limit", t.Scope, t.Key)
+	case t.Rate > 0 && t.Burst == 0:
+		return fmt.Errorf("throttle for %s %q: rate without a burst", t.Scope, t.Key)
+	}
+	return nil
+}
+
+// validateBatch checks that a dumped batch could have been written by a
+// queue.
+func validateBatch(b *model.Batch) error {
+	switch {
+	case b.ID == "":
+		return errors.New("batch has no id")
+	case b.State != model.BatchRunning && b.State != model.BatchSucceeded && b.State != model.BatchFailed:
+		return fmt.Errorf("batch %s: unknown state %q", b.ID, b.State)
+	case !slices.Contains(model.BatchPolicies, b.Policy):
+		return fmt.Errorf("batch %s: unknown policy %q", b.ID, b.Policy)
+	case b.Total < 0:
+		return fmt.Errorf("batch %s: negative total", b.ID)
+	case b.CreatedAt.IsZero():
+		return fmt.Errorf("batch %s: no created_at", b.ID)
+	case b.Callback != nil && b.Callback.Command == "" && (b.Callback.Type == "" || b.Callback.Type == model.TypeShell):
+		return fmt.Errorf("batch %s: shell callback without a command", b.ID)
+	}
+	for state, n := range b.Counts {
+		if !model.IsTerminal(state) || n < 0 || n > b.Total {
+			return fmt.Errorf("batch %s: invalid count %d of %s members", b.ID, n, state)
+		}
+	}
+	return nil
+}
+
+// validateJob checks that a dumped job could have been written by a queue.
+func validateJob(job *model.Job, attempts []model.Attempt) error {
+	switch {
+	case job.ID == "":
+		return errors.New("job has no id")
+	case !slices.Contains(model.States, job.State):
+		return fmt.Errorf("job %s: unknown state %q", job.ID, job.State)
+	case job.Type == "":
+		return fmt.Errorf("job %s: no type", job.ID)
+	case job.Type == model.TypeShell && job.Command == "":
+		return fmt.Errorf("job %s: shell job without a command", job.ID)
+	case job.Queue == "":
+		return fmt.Errorf("job %s: no queue", job.ID)
+	case job.Attempts < 0 || job.MaxRetries < 0:
+		return fmt.Errorf("job %s: negative attempts or max_retries", job.ID)
+	case job.CreatedAt.IsZero():
+		return fmt.Errorf("job %s: no created_at", job.ID)
+	}
+	for _, a := range attempts {
+		if a.JobID != job.ID {
+			return fmt.Errorf("job %s: attempt belongs to job %q", job.ID, a.JobID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package dump
+
+import (
+	"bytes"
+	"queueCtl/internal/config"
+	"queueCtl/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testJob(id string) model.Job {
+	at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	return model.Job{ID: id, Queue: model.DefaultQueue, Type: model.TypeShell, Command: "echo " + id,
+		State: model.StateCompleted, Attempts: 1, MaxRetries: 3, CreatedAt: at, UpdatedAt: at, NextRunAt: at}
+}
+
+func TestWriteAndRead(t *testing.T) {
+	exportedAt := time.Date(2024, 3, 11, 8, 30, 0, 0, time.UTC)
+	cfg := config.NewConfig()
+	cfg.MaxRetries = 7
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, exportedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteConfig(cfg, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteThrottle(model.Throttle{Scope: model.ScopeQueue, Key: "emails", Rate: 5, Burst: 5, Tokens: 2, RefilledAt: exportedAt}); err != nil {
+		t.Fatal(err)
+	}
+	batch := model.Batch{ID: "b", State: model.BatchRunning, Policy: model.PolicyFailOnDead, Total: 2, CreatedAt: exportedAt,
+		Callback: &model.Job{ID: "b.callback", Command: "echo done"}, Counts: map[string]int{model.StateCompleted: 1}}
+	if err := w.WriteBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	a := testJob("a")
+	a.Env = map[string]string{"K": "v"}
+	attempt := model.Attempt{JobID: "a", Attempt: 1, WorkerID: "w1", StartedAt: a.CreatedAt, FinishedAt: a.UpdatedAt, Output: "a\n"}
+	for _, r := range []model.JobRecord{{Job: a, Attempts: []model.Attempt{attempt}}, {Job: testJob("b")}} {
+		if err := w.WriteJob(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := w.Close(); err != nil || n != 2 {
+		t.Fatalf("Close = %d, %v", n, err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 7 {
+		t.Errorf("dump has %d lines, want header, config, a throttle, a batch, two jobs and end", lines)
+	}
+
+	d, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Version != Version || !d.ExportedAt.Equal(exportedAt) || d.Config == nil || d.Config.MaxRetries != 7 || !d.ConfigRedacted {
+		t.Errorf("dump = %+v", d)
+	}
+	if len(d.Jobs) != 2 || d.Jobs[0].Job.Env["K"] != "v" || len(d.Jobs[0].Attempts) != 1 ||
+		d.Jobs[0].Attempts[0].Output != "a\n" || d.Jobs[1].Job.Command != "echo b" {
+		t.Errorf("jobs = %+v", d.Jobs)
+	}
+	if len(d.Batches) != 1 || d.Batches[0].Callback.Command != "echo done" || d.Batches[0].Counts[model.StateCompleted] != 1 {
+		t.Errorf("batches = %+v", d.Batches)
+	}
+	if len(d.Throttles) != 1 || d.Throttles[0].Key != "emails" || d.Throttles[0].Tokens != 2 || !d.Throttles[0].RefilledAt.Equal(exportedAt) {
+		t.Errorf("throttles = %+v", d.Throttles)
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	header := `{"kind":"header","format":"queuectl-dump","version":1}`
+	job := `{"kind":"job","job":{"id":"a","queue":"default","type":"shell","command":"true","state":"pending","attempts":0,"max_retries":3,"created_at":"2024-03-10T12:00:00Z"}}`
+	batch := `{"kind":"batch","batch":{"id":"b","state":"running","policy":"fail_on_dead","total":1,"created_at":"2024-03-10T12:00:00Z"}}`
+	throttle := `{"kind":"throttle","throttle":{"scope":"queue","key":"emails","rate":5,"burst":5}}`
+	end := func(n string) string { return `{"kind":"end","jobs":` + n + `}` }
+	tests := []struct {
+		name, dump, want string
+	}{
+		{"empty", "", "empty"},
+		{"no header", job + "\n" + end("1"), "line 1: not a queuectl dump"},
+		{"other format", `{"kind":"header","format":"other","version":1}`, `unknown format "other"`},
+		{"newer version", `{"kind":"header","format":"queuectl-dump","version":3}`, "version 3 is not supported"},
+		{"truncated", header + "\n" + job, "truncated"},
+		{"end count", header + "\n" + job + "\n" + end("2"), "line 3: end marker does not match the 1 job(s)"},
+		{"after end", header + "\n" + end("0") + "\n" + job, "line 3: data after the end marker"},
+		{"second header", header + "\n" + header, "line 2: unexpected header"},
+		{"duplicate job", header + "\n" + job + "\n" + job + "\n" + end("2"), "line 3: job a appears twice"},
+		{"duplicate batch", header + "\n" + batch + "\n" + batch + "\n" + end("0"), "line 3: batch b appears twice"},
+		{"unknown policy", header + "\n" + strings.Replace(batch, "fail_on_dead", "retry", 1), `unknown policy "retry"`},
+		{"count over total", header + "\n" + strings.Replace(batch, `"total":1,`, `"total":1,"counts":{"dead":2},`, 1), "invalid count 2 of dead"},
+		{"duplicate throttle", header + "\n" + throttle + "\n" + throttle + "\n" + end("0"), `line 3: throttle for queue "emails" appears twice`},
+		{"throttle scope", header + "\n" + strings.Replace(throttle, `"queue"`, `"type"`, 1), `unknown scope "type"`},
+		{"rate without burst", header + "\n" + strings.Replace(throttle, `,"burst":5`, "", 1), "rate without a burst"},
+		{"unknown kind", header + "\n" + `{"kind":"secret"}`, `line 2: unknown record kind "secret"`},
+		{"bad json", header + "\n{", "line 2:"},
+		{"unknown state", header + "\n" + strings.Replace(job, `"pending"`, `"sleeping"`, 1), `unknown state "sleeping"`},
+		{"no command", header + "\n" + strings.Replace(job, `"command":"true",`, "", 1), "shell job without a command"},
+		{"no created_at", header + "\n" + strings.Replace(job, `,"created_at":"2024-03-10T12:00:00Z"`, "", 1), "no created_at"},
+		{"foreign attempt", header + "\n" + strings.Replace(job, `}}`, `},"attempts":[{"job_id":"b","attempt":1}]}`, 1), `attempt belongs to job "b"`},
+	}
+	for _, tt := range tests {
+		_, err := Read(strings.NewReader(tt.dump))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+
+	// Blank lines are allowed; a dump without a config has none.
+	d, err := Read(strings.NewReader(header + "\n\n" + job + "\n" + end("1") + "\n\n"))
+	if err != nil || d.Config != nil || len(d.Jobs) != 1 {
+		t.Errorf("Read = %+v, %v", d, err)
+	}
+}
@@ -0,0 +1,52 @@
+// Package events streams the job lifecycle events recorded in the database
+// to followers: the events command and the server-sent events endpoint.
+// Every process sharing the database records events, so followers poll the
+// event log from a cursor, the ID of the last event they saw.
+package events
+
+import (
+	"context"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"time"
+)
+
+// PollInterval is how often a follower checks for new events.
+const PollInterval = 500 * time.Millisecond
+
+// pageSize is the most events a follower reads at once.
+const pageSize = 500
+
+// Follow calls send with each batch of events matching filter recorded
+// after filter.After, in order, until ctx is canceled or send fails. idle,
+// when not nil, is called after every poll that found nothing.
+func Follow(ctx context.Context, store *storage.Store, filter storage.EventFilter, send func([]model.Event) error, idle func() error) error {
+	filter.Limit = pageSize
+	filter.Newest = false
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for {
+		batch, err := store.ListEvents(filter)
+		if err != nil {
+			return err
+		}
+		if len(batch) > 0 {
+			if err := send(batch); err != nil {
+				return err
+			}
+			filter.After = batch[len(batch)-1].ID
+			if len(batch) == pageSize {
+				continue // more are waiting
+			}
+		} else if idle != nil {
+			if err := idle(); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *storage.Store {
+	t.Helper()
+	store, err := storage.NewStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Db.Close() })
+	return store
+}
+
+// enqueue creates a job with the given ID in queue, which records its
+// enqueued event.
+func enqueue(t *testing.T, store *storage.Store, id, queue string) {
+	t.Helper()
+	job := &model.Job{ID: id, Queue: queue, Command: "true"}
+	if err := job.Prepare(time.Now(), 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateJob(job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	store := newTestStore(t)
+	for i := range pageSize + 3 {
+		enqueue(t, store, fmt.Sprintf("old-%d", i), "q")
+	}
+	enqueue(t, store, "elsewhere", "other")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var got []model.Event
+	var batches, idles int
+	send := func(batch []model.Event) error {
+		batches++
+		got = append(got, batch...)
+		return nil
+	}
+	idle := func() error {
+		idles++
+		if idles == 1 {
+			enqueue(t, store, "new", "q")
+		} else {
+			cancel()
+		}
+		return nil
+	}
+	if err := Follow(ctx, store, storage.EventFilter{Queue: "q", After: 1}, send, idle); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != pageSize+3 || batches != 3 {
+		t.Fatalf("sent %d events in %d batches, want %d in 3", len(got), batches, pageSize+3)
+	}
+	for i, ev := range got {
+		if i > 0 && ev.ID <= got[i-1].ID {
+			t.Fatalf("event %d out of order", i)
+		}
+		if ev.Queue != "q" {
+			t.Errorf("sent an event of queue %s", ev.Queue)
+		}
+	}
+	if got[0].JobID != "old-1" || got[len(got)-1].JobID != "new" {
+		t.Errorf("sent %s to %s, want old-1 to new", got[0].JobID, got[len(got)-1].JobID)
+	}
+}
+
+func TestFollowStopsWhenSendFails(t *testing.T) {
+	store := newTestStore(t)
+	enqueue(t, store, "a", "q")
+	failed := fmt.Errorf("client went away")
+	err := Follow(context.Background(), store, storage.EventFilter{}, func([]model.Event) error { return failed }, nil)
+	if err != failed {
+		t.Errorf("Follow = %v, want the send error", err)
+	}
+}
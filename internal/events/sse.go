@@ -0,0 +1,111 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// keepAliveInterval is how often an idle stream gets a comment line, so
+// proxies do not close it.
+const keepAliveInterval = 15 * time.Second
+
+// Handler serves the event log as server-sent events. Each event is sent
+// with its ID, so a client that reconnects with the Last-Event-ID header
+// resumes where it stopped. Query parameters:
+//
+//	after  resume after this event ID; 0 replays every retained event
+//	       (default: only events recorded from now on)
+//	job    only events of this job
+//	queue  only events of jobs in this queue
+//	type   only these event types, comma separated
+func Handler(store *storage.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		query := r.URL.Query()
+		filter := storage.EventFilter{JobID: query.Get("job"), Queue: query.Get("queue")}
+		if types := query.Get("type"); types != "" {
+			filter.Types = strings.Split(types, ",")
+			for _, t := range filter.Types {
+				if !slices.Contains(model.EventTypes, t) {
+					http.Error(w, fmt.Sprintf("unknown event type %q", t), http.StatusBadRequest)
+					return
+				}
+			}
+		}
+		cursor := r.Header.Get("Last-Event-ID")
+		if cursor == "" {
+			cursor = query.Get("after")
+		}
+		if cursor != "" {
+			after, err := strconv.ParseInt(cursor, 10, 64)
+			if err != nil || after < 0 {
+				http.Error(w, fmt.Sprintf("invalid cursor %q", cursor), http.StatusBadRequest)
+				return
+			}
+			filter.After = after
+		} else {
+			last, err := store.LastEventID()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			filter.After = last
+		}
+
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("Connection", "keep-alive")
+		h.Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		// Tell the client where the stream starts, so it can resume even
+		// if no event arrives before it disconnects.
+		fmt.Fprintf(w, "retry: 2000\nid: %d\n\n", filter.After)
+		flusher.Flush()
+
+		lastWrite := time.Now()
+		send := func(batch []model.Event) error {
+			for _, ev := range batch {
+				data, err := json.Marshal(ev)
+				if err != nil {
+					return err
+				}
+				if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data); err != nil {
+					return err
+				}
+			}
+			flusher.Flush()
+			lastWrite = time.Now()
+			return nil
+		}
+		idle := func() error {
+			if time.Since(lastWrite) < keepAliveInterval {
+				return nil
+			}
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return err
+			}
+			flusher.Flush()
+			lastWrite = time.Now()
+			return nil
+		}
+		Follow(r.Context(), store, filter, send, idle)
+	})
+}
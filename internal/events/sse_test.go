@@ -0,0 +1,120 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"queueCtl/internal/model"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent is one event read from a stream.
+type sseEvent struct {
+	id, event string
+	data      model.Event
+}
+
+// stream opens the event stream at path and returns a function reading
+// its next event, skipping the opening cursor and comments.
+func stream(t *testing.T, srv *httptest.Server, path string, header http.Header) (*http.Response, func() sseEvent) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	lines := bufio.NewScanner(resp.Body)
+	next := func() sseEvent {
+		t.Helper()
+		var ev sseEvent
+		for lines.Scan() {
+			line := lines.Text()
+			switch {
+			case line == "" && ev.event != "":
+				return ev
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				ev.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ev.data); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		t.Fatalf("stream ended: %v", lines.Err())
+		return ev
+	}
+	return resp, next
+}
+
+func TestHandler(t *testing.T) {
+	store := newTestStore(t)
+	enqueue(t, store, "before", "q")
+	srv := httptest.NewServer(Handler(store))
+	// Registered first, so that it runs after the streams are closed.
+	t.Cleanup(srv.Close)
+
+	// By default only events recorded from now on are sent.
+	resp, next := stream(t, srv, "/?queue=q", nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	enqueue(t, store, "other-queue", "other")
+	enqueue(t, store, "after", "q")
+	ev := next()
+	if ev.event != model.EventEnqueued || ev.data.JobID != "after" || ev.id == "" {
+		t.Errorf("first event = %+v, want after's enqueued", ev)
+	}
+
+	// after=0 replays the log; Last-Event-ID resumes past the given event.
+	_, next = stream(t, srv, "/?after=0&type=enqueued", nil)
+	first := next()
+	if first.data.JobID != "before" {
+		t.Errorf("replay starts with %+v, want before", first)
+	}
+	_, next = stream(t, srv, "/?after=0", http.Header{"Last-Event-Id": {first.id}})
+	if ev := next(); ev.data.JobID != "other-queue" {
+		t.Errorf("resumed stream starts with %s, want other-queue", ev.data.JobID)
+	}
+}
+
+func TestHandlerRejects(t *testing.T) {
+	srv := httptest.NewServer(Handler(newTestStore(t)))
+	defer srv.Close()
+	for _, tt := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/?type=enqueued,exploded", http.StatusBadRequest},
+		{http.MethodGet, "/?after=-1", http.StatusBadRequest},
+		{http.MethodGet, "/?after=latest", http.StatusBadRequest},
+	} {
+		req, err := http.NewRequest(tt.method, srv.URL+tt.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.method, tt.path, resp.StatusCode, tt.want)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Package health tells a supervisor whether a worker pool is healthy: that
+// its database answers, that its workers are still looping, and that the
+// store is not persistently locked. A pool started with --listen serves the
+// checks at /healthz and /readyz.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"queueCtl/internal/database"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TickTimeout is how long an idle worker may go without polling for a
+	// job before it counts as wedged. Workers poll every second.
+	TickTimeout = 30 * time.Second
+	// BusyGrace is how long a worker may run one job past the job's
+	// timeout before it counts as wedged: by then the job should long have
+	// been killed.
+	BusyGrace = time.Minute
+	// LockTimeout is how long the database may stay locked against every
+	// claim before the store counts as persistently locked.
+	LockTimeout = 30 * time.Second
+	// dbTimeout bounds the database check.
+	dbTimeout = 2 * time.Second
+)
+
+// Check names.
+const (
+	CheckDatabase = "database"
+	CheckWorkers  = "workers"
+	CheckLock     = "store_lock"
+	CheckShutdown = "shutdown"
+)
+
+// Report is the outcome of a set of checks, served as JSON.
+type Report struct {
+	// Status is "ok" when every check passed, "fail" otherwise.
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// CheckResult is the outcome of one check.
+type CheckResult struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Healthy reports whether every check passed.
+func (r Report) Healthy() bool {
+	return r.Status == "ok"
+}
+
+type workerState struct {
+	lastTick  time.Time
+	busySince time.Time
+	jobID     string
+	// busyLimit is how long the job may run.
+	busyLimit time.Duration
+}
+
+// Monitor collects the state the checks look at. Workers report to it as
+// they loop; a nil *Monitor ignores every report.
+type Monitor struct {
+	store *storage.Store
+
+	mu       sync.Mutex
+	workers  map[int]*workerState
+	stopping bool
+}
+
+// NewMonitor returns a Monitor checking store.
+func NewMonitor(store *storage.Store) *Monitor {
+	return &Monitor{store: store, workers: make(map[int]*workerState)}
+}
+
+// Tick records that worker is alive and idle, polling for work.
+func (m *Monitor) Tick(worker int) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.workers[worker] = &workerState{lastTick: time.Now()}
+}
+
+// Busy records that worker started running job, which may run for timeout.
+func (m *Monitor) Busy(worker int, jobID string, timeout time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	m.workers[worker] = &workerState{lastTick: now, busySince: now, jobID: jobID, busyLimit: timeout + BusyGrace}
+}
+
+// Stopping records that the pool is shutting down; it is no longer ready.
+func (m *Monitor) Stopping() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopping = true
+}
+
+// Live runs the checks that say whether the pool is wedged and should be
+// restarted: its workers and the store lock.
+func (m *Monitor) Live() Report {
+	now := time.Now()
+	return newReport(m.checkWorkers(now), m.checkLock(now))
+}
+
+// Ready runs the checks that say whether the pool can do work: the
+// liveness checks, the database, and that it is not shutting down.
+func (m *Monitor) Ready(ctx context.Context) Report {
+	now := time.Now()
+	m.mu.Lock()
+	stopping := m.stopping
+	m.mu.Unlock()
+	shutdown := CheckResult{Name: CheckShutdown, OK: !stopping}
+	if stopping {
+		shutdown.Detail = "the pool is shutting down"
+	}
+	return newReport(m.checkDatabase(ctx), m.checkWorkers(now), m.checkLock(now), shutdown)
+}
+
+func newReport(checks ...CheckResult) Report {
+	r := Report{Status: "ok", Checks: checks}
+	for _, c := range checks {
+		if !c.OK {
+			r.Status = "fail"
+		}
+	}
+	return r
+}
+
+// checkDatabase runs a query against the jobs table.
+func (m *Monitor) checkDatabase(ctx context.Context) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, dbTimeout)
+	defer cancel()
+	var n int
+	if err := m.store.Db.QueryRowContext(ctx, `select count(*) from (select 1 from jobs limit 1)`).Scan(&n); err != nil {
+		return CheckResult{Name: CheckDatabase, Detail: err.Error()}
+	}
+	return CheckResult{Name: CheckDatabase, OK: true}
+}
+
+// checkWorkers fails when a worker has stopped polling, or has run one job
+// for well over its timeout.
+func (m *Monitor) checkWorkers(now time.Time) CheckResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.workers) == 0 {
+		return CheckResult{Name: CheckWorkers, Detail: "no worker has started"}
+	}
+	ids := make([]int, 0, len(m.workers))
+	for id := range m.workers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var stuck []string
+	for _, id := range ids {
+		w := m.workers[id]
+		switch {
+		case !w.busySince.IsZero() && now.Sub(w.busySince) > w.busyLimit:
+			stuck = append(stuck, fmt.Sprintf("worker %d has run job %s for %s", id, w.jobID, now.Sub(w.busySince).Round(time.Second)))
+		case w.busySince.IsZero() && now.Sub(w.lastTick) > TickTimeout:
+			stuck = append(stuck, fmt.Sprintf("worker %d last polled %s ago", id, now.Sub(w.lastTick).Round(time.Second)))
+		}
+	}
+	if len(stuck) > 0 {
+		return CheckResult{Name: CheckWorkers, Detail: strings.Join(stuck, "; ")}
+	}
+	return CheckResult{Name: CheckWorkers, OK: true, Detail: fmt.Sprintf("%d workers polling", len(ids))}
+}
+
+// checkLock fails when every claim has found the database locked for
+// longer than LockTimeout.
+func (m *Monitor) checkLock(now time.Time) CheckResult {
+	since := m.store.LockedSince()
+	if !since.IsZero() && now.Sub(since) > LockTimeout {
+		return CheckResult{Name: CheckLock, Detail: fmt.Sprintf("the database has been locked for %s", now.Sub(since).Round(time.Second))}
+	}
+	return CheckResult{Name: CheckLock, OK: true}
+}
+
+// Handler serves the liveness report at /healthz and the readiness report
+// at /readyz, with status 200 when healthy and 503 otherwise.
+func Handler(m *Monitor) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, m.Live())
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, m.Ready(r.Context()))
+	})
+	return mux
+}
+
+func writeReport(w http.ResponseWriter, r Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if !r.Healthy() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(r)
+}
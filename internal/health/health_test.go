@@ -0,0 +1,151 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"queueCtl/internal/database"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestMonitor(t *testing.T) *Monitor {
+	t.Helper()
+	store, err := storage.NewStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Db.Close() })
+	return NewMonitor(store)
+}
+
+// check returns the result of the named check in r.
+func check(t *testing.T, r Report, name string) CheckResult {
+	t.Helper()
+	for _, c := range r.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("report has no %s check: %+v", name, r)
+	return CheckResult{}
+}
+
+func TestWorkersCheck(t *testing.T) {
+	m := newTestMonitor(t)
+	if c := check(t, m.Live(), CheckWorkers); c.OK || c.Detail != "no worker has started" {
+		t.Errorf("before any worker started: %+v", c)
+	}
+
+	m.Tick(1)
+	m.Busy(2, "long", time.Hour)
+	if r := m.Live(); !r.Healthy() || check(t, r, CheckWorkers).Detail != "2 workers polling" {
+		t.Errorf("live report = %+v", r)
+	}
+
+	// A worker that stopped polling, or runs a job well past its timeout,
+	// is wedged.
+	m.mu.Lock()
+	m.workers[1].lastTick = time.Now().Add(-TickTimeout - time.Minute)
+	m.workers[2].busySince = time.Now().Add(-time.Hour - BusyGrace - time.Minute)
+	m.mu.Unlock()
+	r := m.Live()
+	c := check(t, r, CheckWorkers)
+	if r.Healthy() || c.OK {
+		t.Fatalf("live report = %+v, want the workers check failed", r)
+	}
+	for _, want := range []string{"worker 1 last polled 1m30s ago", "worker 2 has run job long for 1h2m0s"} {
+		if !strings.Contains(c.Detail, want) {
+			t.Errorf("detail %q lacks %q", c.Detail, want)
+		}
+	}
+
+	// A busy worker is not expected to poll.
+	m.Busy(1, "short", time.Minute)
+	m.mu.Lock()
+	m.workers[1].lastTick = time.Now().Add(-TickTimeout - time.Minute)
+	m.mu.Unlock()
+	m.Tick(2)
+	if r := m.Live(); !r.Healthy() {
+		t.Errorf("live report = %+v", r)
+	}
+}
+
+func TestReady(t *testing.T) {
+	m := newTestMonitor(t)
+	m.Tick(1)
+	r := m.Ready(context.Background())
+	if !r.Healthy() || len(r.Checks) != 4 {
+		t.Fatalf("ready report = %+v", r)
+	}
+
+	m.Stopping()
+	if c := check(t, m.Ready(context.Background()), CheckShutdown); c.OK {
+		t.Errorf("shutdown check = %+v while stopping", c)
+	}
+	if !m.Live().Healthy() {
+		t.Error("a stopping pool is reported dead")
+	}
+
+	m.store.Db.Close()
+	if c := check(t, m.Ready(context.Background()), CheckDatabase); c.OK || c.Detail == "" {
+		t.Errorf("database check = %+v with the database closed", c)
+	}
+}
+
+func TestNilMonitorIgnoresReports(t *testing.T) {
+	var m *Monitor
+	m.Tick(1)
+	m.Busy(1, "a", time.Minute)
+	m.Stopping()
+}
+
+func TestHandler(t *testing.T) {
+	m := newTestMonitor(t)
+	srv := httptest.NewServer(Handler(m))
+	defer srv.Close()
+
+	get := func(path string) (int, Report) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s Content-Type = %q", path, ct)
+		}
+		var r Report
+		if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, r
+	}
+
+	if code, r := get("/healthz"); code != http.StatusServiceUnavailable || r.Status != "fail" {
+		t.Errorf("/healthz before any worker = %d %+v", code, r)
+	}
+	m.Tick(1)
+	if code, r := get("/healthz"); code != http.StatusOK || r.Status != "ok" || len(r.Checks) != 2 {
+		t.Errorf("/healthz = %d %+v", code, r)
+	}
+	if code, _ := get("/readyz"); code != http.StatusOK {
+		t.Errorf("/readyz = %d", code)
+	}
+	m.Stopping()
+	if code, _ := get("/readyz"); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz while stopping = %d", code)
+	}
+
+	resp, err := http.Post(srv.URL+"/healthz", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST /healthz = %d", resp.StatusCode)
+	}
+}
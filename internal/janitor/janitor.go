@@ -0,0 +1,207 @@
+// Package janitor removes finished jobs and old lifecycle events once their
+// retention period has passed, and keeps the database file compact.
+package janitor
+
+import (
+	"context"
+	"log/slog"
+	"queueCtl/internal/archive"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"time"
+)
+
+// BatchSize is how many jobs one delete transaction removes. Small batches
+// keep the write lock short so workers can still claim jobs during a large
+// cleanup.
+const BatchSize = 200
+
+// batchPause is how long Collect waits between batches, giving waiting
+// writers a chance to take the lock.
+const batchPause = 50 * time.Millisecond
+
+// StateResult is what one collection did for one state.
+type StateResult struct {
+	State   string    `json:"state"`
+	Cutoff  time.Time `json:"cutoff"`
+	Removed int       `json:"removed"`
+}
+
+// Collect removes jobs, with their attempt history, that have been in a
+// state of retention for longer than its period. They are moved to the
+// archive in archiveDir when it is set and deleted otherwise. With dryRun
+// it only counts them. States are visited in lifecycle order.
+func Collect(ctx context.Context, store *storage.Store, retention map[string]time.Duration, archiveDir string, now time.Time, dryRun bool) ([]StateResult, error) {
+	var results []StateResult
+	var arch *archive.Archive
+	if archiveDir != "" && !dryRun {
+		var err error
+		if arch, err = archive.Open(archiveDir); err != nil {
+			return nil, err
+		}
+	}
+	for _, state := range model.States {
+		period, ok := retention[state]
+		if !ok {
+			continue
+		}
+		result := StateResult{State: state, Cutoff: now.Add(-period)}
+		filter := storage.JobFilter{States: []string{state}, UpdatedBefore: result.Cutoff}
+
+		if dryRun {
+			n, err := store.CountJobs(filter)
+			if err != nil {
+				return results, err
+			}
+			result.Removed = n
+			results = append(results, result)
+			continue
+		}
+
+		if arch != nil {
+			n, _, err := arch.Move(ctx, store, filter, BatchSize)
+			result.Removed = n
+			results = append(results, result)
+			if err != nil {
+				return results, err
+			}
+			continue
+		}
+
+		filter.Limit = BatchSize
+		for {
+			ids, err := store.PurgeJobs(filter)
+			result.Removed += len(ids)
+			if err != nil {
+				results = append(results, result)
+				return results, err
+			}
+			if len(ids) < BatchSize {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				results = append(results, result)
+				return results, ctx.Err()
+			case <-time.After(batchPause):
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// recheckInterval is how soon Run looks at the configuration again while
+// gc_interval is invalid.
+const recheckInterval = time.Minute
+
+// Janitor applies the configured retention periodically while a worker
+// pool runs. It reads the configuration through Config before each pass,
+// so a reload takes effect from the next one.
+type Janitor struct {
+	Store  *storage.Store
+	Config *config.Live
+
+	lastVacuum time.Time
+}
+
+func New(store *storage.Store, cfg *config.Live) *Janitor {
+	return &Janitor{Store: store, Config: cfg, lastVacuum: time.Now()}
+}
+
+// logger returns the logger for janitor messages.
+func logger() *slog.Logger {
+	return slog.With("component", "janitor")
+}
+
+// Run collects every GC interval until ctx is canceled. After a collection
+// that removed jobs it checkpoints the WAL, and it vacuums once the vacuum
+// interval has passed. While gc_interval is invalid it collects nothing.
+func (j *Janitor) Run(ctx context.Context) {
+	invalid := ""
+	for {
+		gcInterval := j.Config.Get().GCInterval
+		wait, err := config.ParseDuration(gcInterval)
+		valid := err == nil && wait > 0
+		if !valid {
+			if invalid != gcInterval {
+				logger().Error("invalid gc_interval, garbage collection paused", "gc_interval", gcInterval)
+			}
+			invalid, wait = gcInterval, recheckInterval
+		} else {
+			invalid = ""
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if valid {
+			j.pass(ctx)
+		}
+	}
+}
+
+// pass runs one collection with the current configuration, and whatever
+// maintenance it calls for.
+func (j *Janitor) pass(ctx context.Context) {
+	cfg := j.Config.Get()
+	retention, err := cfg.RetentionPeriods()
+	if err != nil {
+		logger().Error("garbage collection skipped", "err", err)
+	}
+	removed := 0
+	if len(retention) > 0 {
+		results, err := Collect(ctx, j.Store, retention, cfg.ArchiveDir, time.Now(), false)
+		for _, r := range results {
+			if r.Removed > 0 {
+				verb := "removed"
+				if cfg.ArchiveDir != "" {
+					verb = "archived"
+				}
+				logger().Info("jobs "+verb, "count", r.Removed, "state", r.State, "updated_before", r.Cutoff.Format(time.RFC3339))
+			}
+			removed += r.Removed
+		}
+		if err != nil && ctx.Err() == nil {
+			logger().Error("garbage collection failed", "err", err)
+		}
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	if cfg.EventRetention != "" {
+		if period, err := config.ParseDuration(cfg.EventRetention); err != nil || period <= 0 {
+			logger().Error("invalid event_retention, events are kept", "event_retention", cfg.EventRetention)
+		} else if n, err := j.Store.PruneEvents(time.Now().Add(-period)); err != nil {
+			logger().Error("pruning events failed", "err", err)
+		} else if n > 0 {
+			logger().Info("events pruned", "count", n, "older_than", cfg.EventRetention)
+			removed += int(n)
+		}
+	}
+
+	var vacuumEvery time.Duration
+	if cfg.VacuumInterval != "" {
+		if vacuumEvery, err = config.ParseDuration(cfg.VacuumInterval); err != nil {
+			logger().Error("invalid vacuum_interval, vacuum skipped", "vacuum_interval", cfg.VacuumInterval)
+		}
+	}
+
+	// Deletes and vacuums both leave a large WAL behind.
+	checkpoint := removed > 0
+	if vacuumEvery > 0 && time.Since(j.lastVacuum) >= vacuumEvery {
+		j.lastVacuum = time.Now()
+		if err := j.Store.Vacuum(); err != nil {
+			logger().Error("vacuum failed", "err", err)
+		}
+		checkpoint = true
+	}
+	if checkpoint {
+		if err := j.Store.Checkpoint(); err != nil {
+			logger().Error("WAL checkpoint failed", "err", err)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package janitor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"queueCtl/internal/archive"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"testing"
+	"time"
+)
+
+// newStore returns a fresh store holding n jobs in each of the given
+// states, last updated age ago, with IDs like "completed-7".
+func newStore(t *testing.T, n int, age time.Duration, states ...string) *storage.Store {
+	t.Helper()
+	store, err := storage.NewStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Db.Close() })
+	addJobs(t, store, n, age, states...)
+	return store
+}
+
+func addJobs(t *testing.T, store *storage.Store, n int, age time.Duration, states ...string) {
+	t.Helper()
+	at := time.Now().Add(-age)
+	for _, state := range states {
+		for i := range n {
+			job := &model.Job{ID: fmt.Sprintf("%s-%d-%d", state, age/time.Hour, i), Command: "true"}
+			if err := job.Prepare(at, 3); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.CreateJob(job); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := store.Db.Exec(`update jobs set state = ? where state = ?`, state, model.StatePending); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func removed(results []StateResult) map[string]int {
+	m := make(map[string]int)
+	for _, r := range results {
+		m[r.State] = r.Removed
+	}
+	return m
+}
+
+func TestCollect(t *testing.T) {
+	store := newStore(t, BatchSize+5, 48*time.Hour, model.StateCompleted, model.StateDead)
+	addJobs(t, store, 3, 2*time.Hour, model.StateCompleted)
+	retention := map[string]time.Duration{model.StateCompleted: 24 * time.Hour, model.StateDead: 72 * time.Hour}
+	now := time.Now()
+
+	results, err := Collect(context.Background(), store, retention, "", now, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := removed(results); got[model.StateCompleted] != BatchSize+5 || got[model.StateDead] != 0 {
+		t.Errorf("dry run would remove %v", got)
+	}
+	if results[0].State != model.StateCompleted || !results[0].Cutoff.Equal(now.Add(-24*time.Hour)) {
+		t.Errorf("first result = %+v", results[0])
+	}
+	if n, _ := store.CountJobs(storage.JobFilter{}); n != 2*(BatchSize+5)+3 {
+		t.Fatalf("dry run removed jobs: %d left", n)
+	}
+
+	// More jobs than one batch deletes are removed over several.
+	results, err = Collect(context.Background(), store, retention, "", now, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := removed(results); got[model.StateCompleted] != BatchSize+5 || got[model.StateDead] != 0 {
+		t.Errorf("removed %v", got)
+	}
+	for state, want := range map[string]int{model.StateCompleted: 3, model.StateDead: BatchSize + 5} {
+		if n, _ := store.CountJobs(storage.JobFilter{States: []string{state}}); n != want {
+			t.Errorf("%d %s jobs left, want %d", n, state, want)
+		}
+	}
+}
+
+func TestCollectIntoArchive(t *testing.T) {
+	store := newStore(t, 3, 48*time.Hour, model.StateCompleted, model.StateFailed)
+	dir := t.TempDir()
+	retention := map[string]time.Duration{model.StateCompleted: time.Hour}
+
+	results, err := Collect(context.Background(), store, retention, dir, time.Now(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := removed(results); len(got) != 1 || got[model.StateCompleted] != 3 {
+		t.Errorf("archived %v", got)
+	}
+	found, err := (&archive.Archive{Dir: dir}).Search(archive.Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 3 || found[0].Job.State != model.StateCompleted {
+		t.Errorf("archive holds %d jobs: %+v", len(found), found)
+	}
+	if n, _ := store.CountJobs(storage.JobFilter{}); n != 3 {
+		t.Errorf("%d jobs left, want the failed ones", n)
+	}
+}
+
+func TestCollectStopsWhenCanceled(t *testing.T) {
+	store := newStore(t, 2*BatchSize+1, 48*time.Hour, model.StateCompleted)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err := Collect(ctx, store, map[string]time.Duration{model.StateCompleted: time.Hour}, "", time.Now(), false)
+	if err != context.Canceled {
+		t.Fatalf("Collect error = %v, want context.Canceled", err)
+	}
+	if got := removed(results)[model.StateCompleted]; got != BatchSize {
+		t.Errorf("removed %d jobs, want one batch", got)
+	}
+}
+
+func TestPassUsesReloadedRetention(t *testing.T) {
+	store := newStore(t, 2, 48*time.Hour, model.StateCompleted)
+	cfg := config.NewConfig()
+	live := config.NewLive(cfg)
+	j := New(store, live)
+
+	j.pass(context.Background())
+	if n, _ := store.CountJobs(storage.JobFilter{}); n != 2 {
+		t.Fatalf("%d jobs left without a retention period, want 2", n)
+	}
+
+	reloaded := *cfg
+	reloaded.Retention = map[string]string{model.StateCompleted: "1d"}
+	live.Set(&reloaded)
+	j.pass(context.Background())
+	if n, _ := store.CountJobs(storage.JobFilter{}); n != 0 {
+		t.Errorf("%d jobs left after reloading retention-completed=1d, want 0", n)
+	}
+}
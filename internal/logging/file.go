@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// File is a log file that rotates itself once it grows past a size:
+// queuectl.log is renamed to queuectl.log.1, the older backups shift up
+// by one, and the oldest beyond the backup count is removed.
+type File struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenFile opens path for appending. maxSize is in bytes; 0 disables
+// rotation.
+func OpenFile(path string, maxSize int64, maxBackups int) (*File, error) {
+	lf := &File{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := lf.open(); err != nil {
+		return nil, err
+	}
+	return lf, nil
+}
+
+func (lf *File) open() error {
+	f, err := os.OpenFile(lf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	lf.f, lf.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first when p would take the file past its
+// maximum size. A single record is never split across files.
+func (lf *File) Write(p []byte) (int, error) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	if lf.maxSize > 0 && lf.size > 0 && lf.size+int64(len(p)) > lf.maxSize {
+		if err := lf.rotate(); err != nil {
+			// Keep logging to the current file rather than losing records.
+			fmt.Fprintf(os.Stderr, "queuectl: log rotation failed: %v\n", err)
+		}
+	}
+	n, err := lf.f.Write(p)
+	lf.size += int64(n)
+	return n, err
+}
+
+func (lf *File) rotate() error {
+	if err := lf.f.Close(); err != nil {
+		return err
+	}
+	if lf.maxBackups > 0 {
+		os.Remove(lf.backup(lf.maxBackups))
+		for i := lf.maxBackups - 1; i >= 1; i-- {
+			os.Rename(lf.backup(i), lf.backup(i+1))
+		}
+		if err := os.Rename(lf.path, lf.backup(1)); err != nil {
+			lf.open()
+			return err
+		}
+	} else if err := os.Truncate(lf.path, 0); err != nil {
+		lf.open()
+		return err
+	}
+	return lf.open()
+}
+
+func (lf *File) backup(n int) string {
+	return fmt.Sprintf("%s.%d", lf.path, n)
+}
+
+// Close closes the current file.
+func (lf *File) Close() error {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	return lf.f.Close()
+}
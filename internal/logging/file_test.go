@@ -0,0 +1,94 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFileRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queuectl.log")
+	if err := os.WriteFile(path, []byte("old 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Room for two 6-byte records per file, counting what is already
+	// there.
+	f, err := OpenFile(path, 12, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range []string{"rec 2\n", "rec 3\n", "rec 4\n", "rec 5\n", "rec 6\n", "rec 7\n", "rec 8\n"} {
+		if _, err := f.Write([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		path:        "rec 7\nrec 8\n",
+		path + ".1": "rec 5\nrec 6\n",
+		path + ".2": "rec 3\nrec 4\n",
+	}
+	for p, content := range want {
+		if got := readFile(t, p); got != content {
+			t.Errorf("%s = %q, want %q", filepath.Base(p), got, content)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("kept more backups than asked for")
+	}
+}
+
+func TestFileWithoutBackupsTruncates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queuectl.log")
+	f, err := OpenFile(path, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// A record larger than the limit is still written whole.
+	for _, record := range []string{"first\n", strings.Repeat("x", 20) + "\n", "last\n"} {
+		if _, err := f.Write([]byte(record)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := readFile(t, path); got != "last\n" {
+		t.Errorf("log = %q, want only the last record", got)
+	}
+	if matches, _ := filepath.Glob(path + ".*"); len(matches) != 0 {
+		t.Errorf("backups written: %v", matches)
+	}
+}
+
+func TestFileWithoutMaxSizeNeverRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queuectl.log")
+	f, err := OpenFile(path, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	for range 100 {
+		f.Write([]byte("a line that would fill a small file\n"))
+	}
+	if got := strings.Count(readFile(t, path), "\n"); got != 100 {
+		t.Errorf("log has %d lines, want 100", got)
+	}
+}
+
+func TestOpenFileFails(t *testing.T) {
+	if _, err := OpenFile(filepath.Join(t.TempDir(), "missing", "queuectl.log"), 0, 0); err == nil {
+		t.Error("opened a log file in a missing directory")
+	}
+}
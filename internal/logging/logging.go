@@ -0,0 +1,72 @@
+// Package logging sets up the structured logger every queuectl command
+// writes to: its level, its format (text or JSON lines) and where it goes,
+// standard error or a size-rotated file.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Formats are the values accepted by --log-format.
+var Formats = []string{"text", "json"}
+
+// Options configure the logger.
+type Options struct {
+	// Level is debug, info, warn or error.
+	Level string
+	// Format is text or json.
+	Format string
+	// File, when set, receives the log instead of standard error.
+	File string
+	// MaxSizeMB is the size at which File is rotated; 0 disables rotation.
+	MaxSizeMB int
+	// MaxBackups is how many rotated files are kept.
+	MaxBackups int
+}
+
+// ParseLevel parses a --log-level value.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q (use debug, info, warn or error)", s)
+	}
+	return level, nil
+}
+
+// Setup installs the logger described by opts as the default slog logger,
+// which also receives anything written with the log package. The returned
+// function closes the log file, if there is one.
+func Setup(opts Options) (func() error, error) {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	var w io.Writer = os.Stderr
+	closer := func() error { return nil }
+	if opts.File != "" {
+		f, err := OpenFile(opts.File, int64(opts.MaxSizeMB)<<20, opts.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		w, closer = f, f.Close
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch strings.ToLower(opts.Format) {
+	case "text", "":
+		handler = slog.NewTextHandler(w, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(w, handlerOpts)
+	default:
+		closer()
+		return nil, fmt.Errorf("invalid log format %q (use %s)", opts.Format, strings.Join(Formats, " or "))
+	}
+	slog.SetDefault(slog.New(handler))
+	return closer, nil
+}
@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{"debug": slog.LevelDebug, "info": slog.LevelInfo, "WARN": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLevel(in); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel accepted loud")
+	}
+}
+
+func TestSetup(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	path := filepath.Join(t.TempDir(), "queuectl.log")
+
+	closeLog, err := Setup(Options{Level: "warn", Format: "json", File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.Info("not logged")
+	slog.Warn("job failed", "job_id", "a")
+	if err := closeLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, path)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("log = %q, want only the warning", lines)
+	}
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatal(err)
+	}
+	if rec["level"] != "WARN" || rec["msg"] != "job failed" || rec["job_id"] != "a" {
+		t.Errorf("record = %v", rec)
+	}
+
+	for _, opts := range []Options{{Level: "info", Format: "xml"}, {Level: "chatty"}} {
+		if _, err := Setup(opts); err == nil {
+			t.Errorf("Setup(%+v) succeeded", opts)
+		}
+	}
+}
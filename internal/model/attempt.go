@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// Attempt records a single execution of a job by a worker.
+type Attempt struct {
+    JobID             string    `json:"job_id"`
+    Attempt           int       `json:"attempt"`
+    WorkerID          string    `json:"worker_id"`
+    StartedAt         time.Time `json:"started_at"`
+    FinishedAt        time.Time `json:"finished_at"`
+    ExitCode          int       `json:"exit_code"`
+    FailureReason     string    `json:"failure_reason,omitempty"`
+    Output            string    `json:"output,omitempty"`
+    LeftoverProcesses []string  `json:"leftover_processes,omitempty"`
+}
+
+// JobRecord is a job with its full attempt history, the unit that is
+// archived and restored.
+type JobRecord struct {
+    Job      Job       `json:"job"`
+    Attempts []Attempt `json:"attempts,omitempty"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+// Sources an audited action can come from.
+const (
+    AuditSourceCLI = "cli"
+    AuditSourceAPI = "api"
+)
+
+// Outcomes of an audited action.
+const (
+    AuditOK    = "ok"
+    AuditError = "error"
+)
+
+// AuditEntry records who performed an administrative action, from where,
+// and how it ended. Entries are never changed once written.
+type AuditEntry struct {
+    ID int64     `json:"id"`
+    At time.Time `json:"at"`
+    // User and Host identify the OS account and machine the action ran on.
+    User string `json:"user"`
+    Host string `json:"host"`
+    PID  int    `json:"pid"`
+    // Source is cli for queuectl commands, api for the Go client.
+    Source string `json:"source"`
+    // Action names what was done, such as "config set" or "dlq retry".
+    Action  string   `json:"action"`
+    Args    []string `json:"args"`
+    Outcome string   `json:"outcome"`
+    // Error is why the action failed, when Outcome is error.
+    Error string `json:"error,omitempty"`
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+// Batch states. A batch is running until every member job is in a final
+// state.
+const (
+    BatchRunning   = "running"
+    BatchSucceeded = "succeeded"
+    BatchFailed    = "failed"
+)
+
+// Batch policies decide whether members that did not complete fail the
+// batch.
+const (
+    // PolicyFailOnDead fails the batch if any member is dead, canceled or
+    // expired.
+    PolicyFailOnDead = "fail_on_dead"
+    // PolicyIgnoreDead lets the batch succeed once every member finished,
+    // however it finished.
+    PolicyIgnoreDead = "ignore_dead"
+)
+
+// BatchPolicies lists the valid policies.
+var BatchPolicies = []string{PolicyFailOnDead, PolicyIgnoreDead}
+
+// Batch groups jobs that are tracked together. When the last member
+// reaches a final state the batch finishes and its callback is enqueued.
+type Batch struct {
+    ID         string    `json:"id"`
+    State      string    `json:"state"`
+    Policy     string    `json:"policy"`
+    Total      int       `json:"total"`
+    CreatedAt  time.Time `json:"created_at"`
+    FinishedAt time.Time `json:"finished_at,omitempty"`
+    // Callback is the job enqueued when the batch finishes, if any.
+    Callback *Job `json:"callback,omitempty"`
+    // Counts is the number of members in each job state.
+    Counts map[string]int `json:"counts"`
+}
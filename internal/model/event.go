@@ -0,0 +1,59 @@
+package model
+
+import "time"
+
+// Event types recorded as a job moves through its lifecycle.
+const (
+    EventEnqueued       = "enqueued"
+    EventClaimed        = "claimed"
+    EventOutput         = "output"
+    EventRetryScheduled = "retry_scheduled"
+    EventRequeued       = "requeued"
+    EventCompleted      = "completed"
+    EventDead           = "dead"
+    EventCanceled       = "canceled"
+    EventExpired        = "expired"
+    // EventFollowUpSkipped is recorded for a job whose follow-up was not
+    // enqueued because a job with the follow-up's ID already exists.
+    EventFollowUpSkipped = "follow_up_skipped"
+)
+
+// EventTypes lists every event type.
+var EventTypes = []string{
+    EventEnqueued, EventClaimed, EventOutput, EventRetryScheduled, EventRequeued,
+    EventCompleted, EventDead, EventCanceled, EventExpired, EventFollowUpSkipped,
+}
+
+// StateEvent returns the event type recorded when a job enters state.
+func StateEvent(state string) string {
+    switch state {
+    case StateFailed:
+        return EventRetryScheduled
+    case StatePending:
+        return EventRequeued
+    case StateProcessing:
+        return EventClaimed
+    }
+    return state // completed, dead, canceled and expired name their event
+}
+
+// Event is one step in a job's lifecycle. IDs increase in the order events
+// were recorded, so the last ID seen is a cursor to resume from.
+type Event struct {
+    ID    int64     `json:"id"`
+    At    time.Time `json:"at"`
+    Type  string    `json:"type"`
+    JobID string    `json:"job_id"`
+    Queue string    `json:"queue"`
+    // State is the job's state after the event.
+    State         string     `json:"state"`
+    Attempt       int        `json:"attempt,omitempty"`
+    WorkerID      string     `json:"worker_id,omitempty"`
+    ExitCode      int        `json:"exit_code,omitempty"`
+    FailureReason string     `json:"failure_reason,omitempty"`
+    NextRunAt     *time.Time `json:"next_run_at,omitempty"`
+    // Output is the end of an attempt's output, on output events.
+    Output string `json:"output,omitempty"`
+    // FollowUpID is the ID of the follow-up, on follow_up_skipped events.
+    FollowUpID string `json:"follow_up_id,omitempty"`
+}
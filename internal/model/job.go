@@ -1,6 +1,12 @@
 package model
 
-import "time"
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "queueCtl/internal/tracing"
+    "time"
+)
 
 const (
     StatePending    = "pending"
@@ -8,11 +14,55 @@ const (
     StateCompleted  = "completed"
     StateFailed     = "failed"
     StateDead       = "dead"
+    StateCanceled   = "canceled"
+    StateExpired    = "expired"
+)
+
+// States lists every job state in lifecycle order.
+var States = []string{StatePending, StateProcessing, StateFailed, StateCompleted, StateDead, StateCanceled, StateExpired}
+
+// IsTerminal reports whether a job in state will not run again on its own.
+func IsTerminal(state string) bool {
+    return state == StateCompleted || state == StateDead || state == StateCanceled || state == StateExpired
+}
+
+// TypeShell is the job type run as a shell command. Any other type is
+// dispatched to a Go handler registered with the worker.
+const TypeShell = "shell"
+
+// DefaultQueue is the queue a job joins when it does not name one.
+const DefaultQueue = "default"
+
+// Failure reasons recorded on a job when its last attempt did not succeed.
+const (
+    FailureExitCode      = "exit_code"
+    FailureKilledByLimit = "killed_by_limit"
+    FailureTimeout       = "timeout"
+    FailureInterrupted   = "interrupted"
+    FailureHandlerError  = "handler_error"
+    FailureNoHandler     = "no_handler"
+    FailureCanceled      = "canceled"
+    // FailureExpired: the job did not start before its expires_at.
+    FailureExpired = "expired"
+    // FailureDeadline: the job's deadline passed before it could succeed,
+    // and its remaining attempts were abandoned.
+    FailureDeadline = "deadline_exceeded"
 )
 
+// ResourceLimits caps what a job's process may consume. Zero means unlimited.
+type ResourceLimits struct {
+    CPUSeconds int `json:"cpu_seconds,omitempty"`
+    MemoryMB   int `json:"memory_mb,omitempty"`
+    OpenFiles  int `json:"open_files,omitempty"`
+    MaxProcs   int `json:"max_procs,omitempty"`
+}
+
 type Job struct {
     ID          string    `json:"id"`
-    Command     string    `json:"command"`
+    Queue         string          `json:"queue,omitempty"`
+    Type          string          `json:"type,omitempty"`
+    Command       string          `json:"command,omitempty"`
+    Payload       json.RawMessage `json:"payload,omitempty"`
     State       string    `json:"state"`
     Attempts    int       `json:"attempts"`
     MaxRetries  int       `json:"max_retries"`
@@ -20,4 +70,172 @@ type Job struct {
     UpdatedAt   time.Time `json:"updated_at"`
     NextRunAt   time.Time `json:"next_run_at"`
     Output      string    `json:"output,omitempty"`
-}
\ No newline at end of file
+    Limits        *ResourceLimits `json:"limits,omitempty"`
+    ExitCode      int             `json:"exit_code,omitempty"`
+    FailureReason string          `json:"failure_reason,omitempty"`
+    WorkerID      string          `json:"worker_id,omitempty"`
+
+    // Env holds extra environment variables for a shell job's command.
+    Env map[string]string `json:"env,omitempty"`
+    // OnSuccess and OnFailure are job templates enqueued when this job
+    // completes, or fails for the last time and moves to the DLQ.
+    OnSuccess *Job `json:"on_success,omitempty"`
+    OnFailure *Job `json:"on_failure,omitempty"`
+    // ParentID is the job whose on_success or on_failure enqueued this one.
+    ParentID string `json:"parent_id,omitempty"`
+    // BatchID is the batch this job belongs to, if any.
+    BatchID string `json:"batch_id,omitempty"`
+    // ConcurrencyKey groups jobs, across queues, that share a throttle.
+    ConcurrencyKey string `json:"concurrency_key,omitempty"`
+    // LockKey makes jobs mutually exclusive: at most one job with a given
+    // key is processing at a time, and they start in the order they were
+    // enqueued.
+    LockKey string `json:"lock_key,omitempty"`
+    // ExpiresAt is when the job expires if it has not started by then.
+    // TTL sets it relative to the enqueue time, e.g. "30m".
+    ExpiresAt *time.Time `json:"expires_at,omitempty"`
+    TTL       string     `json:"ttl,omitempty"`
+    // Deadline is when the job is abandoned, and moved to the DLQ, if it
+    // has not completed by then, however many retries it has left.
+    Deadline *time.Time `json:"deadline,omitempty"`
+    // Timeout limits each attempt, e.g. "2h". An attempt still running
+    // after it is killed and fails with the timeout reason. Empty uses the
+    // configured job_timeout.
+    Timeout string `json:"timeout,omitempty"`
+    // TraceParent is the W3C trace context of the producer that enqueued
+    // the job; the worker's spans for it join that trace.
+    TraceParent string `json:"traceparent,omitempty"`
+}
+
+// WithDefaults returns l with every unset limit taken from def. A nil
+// receiver yields def unchanged.
+func (l *ResourceLimits) WithDefaults(def ResourceLimits) ResourceLimits {
+    if l == nil {
+        return def
+    }
+    out := *l
+    if out.CPUSeconds == 0 {
+        out.CPUSeconds = def.CPUSeconds
+    }
+    if out.MemoryMB == 0 {
+        out.MemoryMB = def.MemoryMB
+    }
+    if out.OpenFiles == 0 {
+        out.OpenFiles = def.OpenFiles
+    }
+    if out.MaxProcs == 0 {
+        out.MaxProcs = def.MaxProcs
+    }
+    return out
+}
+
+// IsZero reports whether no limit is set.
+func (l ResourceLimits) IsZero() bool {
+    return l == ResourceLimits{}
+}
+
+// Prepare validates a job submitted for enqueueing and fills in the fields
+// the queue owns: state, timestamps, the parent and batch links and, when
+// unset, queue, type and max retries.
+func (j *Job) Prepare(now time.Time, defaultMaxRetries int) error {
+    if j.Queue == "" {
+        j.Queue = DefaultQueue
+    }
+    if j.Type == "" {
+        j.Type = TypeShell
+    }
+    if j.ID == "" {
+        return errors.New("job 'id' is empty")
+    }
+    if j.Type == TypeShell && j.Command == "" {
+        return errors.New("job 'command' is empty")
+    }
+
+    for name, t := range map[string]*Job{"on_success": j.OnSuccess, "on_failure": j.OnFailure} {
+        if t == nil {
+            continue
+        }
+        if err := t.validateTemplate(); err != nil {
+            return fmt.Errorf("job '%s': %w", name, err)
+        }
+    }
+
+    if err := j.validateTiming(); err != nil {
+        return fmt.Errorf("job %w", err)
+    }
+    if j.TTL != "" {
+        ttl, _ := time.ParseDuration(j.TTL)
+        expiresAt := now.Add(ttl)
+        j.ExpiresAt, j.TTL = &expiresAt, ""
+    }
+
+    // Only the queue links a job to its parent or batch.
+    j.ParentID = ""
+    j.BatchID = ""
+    j.State = StatePending
+    j.Attempts = 0
+    j.CreatedAt = now
+    j.UpdatedAt = now
+    j.NextRunAt = now
+
+    if j.MaxRetries == 0 {
+        j.MaxRetries = defaultMaxRetries
+    }
+    return nil
+}
+
+// validateTiming checks the trace context, ttl and timeout, which Prepare
+// only consumes when the job is enqueued.
+func (j *Job) validateTiming() error {
+    if j.TraceParent != "" {
+        if _, err := tracing.ParseTraceParent(j.TraceParent); err != nil {
+            return fmt.Errorf("'traceparent': %w", err)
+        }
+    }
+    if j.TTL != "" {
+        ttl, err := time.ParseDuration(j.TTL)
+        if err != nil || ttl <= 0 {
+            return fmt.Errorf("'ttl' %q is not a positive duration", j.TTL)
+        }
+        if j.ExpiresAt != nil {
+            return errors.New("has both 'ttl' and 'expires_at'")
+        }
+    }
+    if j.Timeout != "" {
+        if d, err := time.ParseDuration(j.Timeout); err != nil || d <= 0 {
+            return fmt.Errorf("'timeout' %q is not a positive duration", j.Timeout)
+        }
+    }
+    return nil
+}
+
+// validateTemplate checks a follow-up job template, and the templates
+// nested in it, without filling anything in; that happens when it is
+// enqueued.
+func (j *Job) validateTemplate() error {
+    if (j.Type == "" || j.Type == TypeShell) && j.Command == "" {
+        return errors.New("'command' is empty")
+    }
+    if err := j.validateTiming(); err != nil {
+        return err
+    }
+    for name, t := range map[string]*Job{"on_success": j.OnSuccess, "on_failure": j.OnFailure} {
+        if t == nil {
+            continue
+        }
+        if err := t.validateTemplate(); err != nil {
+            return fmt.Errorf("'%s': %w", name, err)
+        }
+    }
+    return nil
+}
+
+// FollowUpID returns the default ID of the follow-up enqueued when the job
+// with the given ID reaches state: the ID with ".on_success" appended once
+// it completed, ".on_failure" once it is dead.
+func FollowUpID(jobID, state string) string {
+    if state == StateCompleted {
+        return jobID + ".on_success"
+    }
+    return jobID + ".on_failure"
+}
@@ -0,0 +1,75 @@
+package model
+
+import (
+    "fmt"
+    "testing"
+    "time"
+)
+
+func TestResourceLimitsWithDefaults(t *testing.T) {
+    def := ResourceLimits{CPUSeconds: 60, MemoryMB: 512, OpenFiles: 1024, MaxProcs: 64}
+
+    var unset *ResourceLimits
+    if got := unset.WithDefaults(def); got != def {
+        t.Errorf("nil limits = %+v, want the defaults", got)
+    }
+    own := &ResourceLimits{MemoryMB: 128, MaxProcs: 8}
+    want := ResourceLimits{CPUSeconds: 60, MemoryMB: 128, OpenFiles: 1024, MaxProcs: 8}
+    if got := own.WithDefaults(def); got != want {
+        t.Errorf("WithDefaults = %+v, want %+v", got, want)
+    }
+    if own.CPUSeconds != 0 {
+        t.Error("WithDefaults changed its receiver")
+    }
+    if got := own.WithDefaults(ResourceLimits{}); got != *own {
+        t.Errorf("WithDefaults with no defaults = %+v", got)
+    }
+    if !(ResourceLimits{}).IsZero() || own.IsZero() {
+        t.Error("IsZero is wrong")
+    }
+}
+
+func TestPrepareValidatesFollowUps(t *testing.T) {
+    tests := []struct {
+        name string
+        job  Job
+        want string
+    }{
+        {"no command", Job{ID: "a", Command: "true", OnSuccess: &Job{}}, "job 'on_success': 'command' is empty"},
+        {"handler follow-up", Job{ID: "a", Command: "true", OnFailure: &Job{Type: "email"}}, ""},
+        {"nested", Job{ID: "a", Command: "true", OnSuccess: &Job{Command: "true", OnFailure: &Job{Command: "true", Timeout: "-1s"}}},
+            "job 'on_success': 'on_failure': 'timeout' \"-1s\" is not a positive duration"},
+        {"ttl and expires_at", Job{ID: "a", Command: "true", OnFailure: &Job{Command: "true", TTL: "1m", ExpiresAt: &time.Time{}}},
+            "job 'on_failure': has both 'ttl' and 'expires_at'"},
+    }
+    for _, tt := range tests {
+        err := tt.job.Prepare(time.Now(), 3)
+        if got := fmt.Sprint(err); (tt.want == "" && err != nil) || (tt.want != "" && got != tt.want) {
+            t.Errorf("%s: Prepare error = %v, want %q", tt.name, err, tt.want)
+        }
+    }
+}
+
+func TestPrepareResetsQueueOwnedFields(t *testing.T) {
+    now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+    j := Job{ID: "a", Command: "true", State: StateDead, Attempts: 4, ParentID: "p", BatchID: "b",
+        OnSuccess: &Job{Command: "true"}}
+    if err := j.Prepare(now, 3); err != nil {
+        t.Fatal(err)
+    }
+    if j.State != StatePending || j.Attempts != 0 || j.ParentID != "" || j.BatchID != "" ||
+        j.Queue != DefaultQueue || j.Type != TypeShell || j.MaxRetries != 3 {
+        t.Errorf("prepared job = %+v", j)
+    }
+    if !j.CreatedAt.Equal(now) || !j.NextRunAt.Equal(now) || j.OnSuccess.State != "" {
+        t.Errorf("prepared job = %+v, template %+v", j, j.OnSuccess)
+    }
+}
+
+func TestFollowUpID(t *testing.T) {
+    for state, want := range map[string]string{StateCompleted: "a.on_success", StateDead: "a.on_failure"} {
+        if got := FollowUpID("a", state); got != want {
+            t.Errorf("FollowUpID(a, %s) = %q, want %q", state, got, want)
+        }
+    }
+}
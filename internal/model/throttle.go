@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Throttle scopes: a throttle applies to every job in a queue, or to every
+// job with a given concurrency key.
+const (
+    ScopeQueue = "queue"
+    ScopeKey   = "key"
+)
+
+// Throttle limits how fast and how many jobs of a queue or concurrency key
+// are started, across every worker sharing the database.
+type Throttle struct {
+    Scope string `json:"scope"`
+    Key   string `json:"key"`
+    // MaxConcurrency caps the jobs processing at once; 0 means no cap.
+    MaxConcurrency int `json:"max_concurrency,omitempty"`
+    // Rate is the number of job starts allowed per second, refilled
+    // continuously into a bucket of Burst tokens; 0 means no rate limit.
+    Rate  float64 `json:"rate,omitempty"`
+    Burst int     `json:"burst,omitempty"`
+
+    // Tokens is the bucket's level as of RefilledAt.
+    Tokens     float64   `json:"tokens"`
+    RefilledAt time.Time `json:"refilled_at"`
+}
+
+// Available returns the tokens in the bucket at now.
+func (t *Throttle) Available(now time.Time) float64 {
+    tokens := t.Tokens + now.Sub(t.RefilledAt).Seconds()*t.Rate
+    return min(tokens, float64(t.Burst))
+}
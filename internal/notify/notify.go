@@ -0,0 +1,345 @@
+// Package notify tells webhooks and local hook commands about job state
+// changes. Deliveries run in the background: a slow or failing endpoint
+// never holds up a worker, and failed deliveries are only logged.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"queueCtl/internal/config"
+	"queueCtl/internal/model"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EventJobState is the type of the event sent when a job changes state.
+const EventJobState = "job.state_changed"
+
+// Webhook request headers.
+const (
+	HeaderEvent     = "X-Queuectl-Event"
+	HeaderDelivery  = "X-Queuectl-Delivery"
+	HeaderTimestamp = "X-Queuectl-Timestamp"
+	// HeaderSignature is "sha256=" and the hex HMAC-SHA256, keyed with the
+	// notifier's secret, of the timestamp header, a dot and the body.
+	HeaderSignature = "X-Queuectl-Signature"
+)
+
+const (
+	// QueueSize is how many events may wait for delivery. Events beyond it
+	// are dropped, and logged, rather than making a worker wait.
+	QueueSize = 256
+	// MaxInFlight caps the deliveries running at once.
+	MaxInFlight = 8
+	// DefaultTimeout bounds one delivery attempt.
+	DefaultTimeout = 10 * time.Second
+	// outputTailBytes is how much of the job's output an event carries.
+	outputTailBytes = 4096
+)
+
+// Event is the JSON body of a notification.
+type Event struct {
+	ID    string    `json:"id"`
+	Type  string    `json:"type"`
+	State string    `json:"state"`
+	At    time.Time `json:"at"`
+	Job   JobInfo   `json:"job"`
+}
+
+// JobInfo is the part of a job an event carries.
+type JobInfo struct {
+	ID            string    `json:"id"`
+	Queue         string    `json:"queue"`
+	Type          string    `json:"type"`
+	Command       string    `json:"command,omitempty"`
+	Attempts      int       `json:"attempts"`
+	MaxRetries    int       `json:"max_retries"`
+	ExitCode      int       `json:"exit_code"`
+	FailureReason string    `json:"failure_reason,omitempty"`
+	NextRunAt     time.Time `json:"next_run_at,omitzero"`
+	WorkerID      string    `json:"worker_id,omitempty"`
+	// Output is the end of the job's output.
+	Output string `json:"output,omitempty"`
+}
+
+// NewEvent describes job having just entered its current state.
+func NewEvent(job *model.Job, at time.Time) Event {
+	info := JobInfo{
+		ID:            job.ID,
+		Queue:         job.Queue,
+		Type:          job.Type,
+		Command:       job.Command,
+		Attempts:      job.Attempts,
+		MaxRetries:    job.MaxRetries,
+		ExitCode:      job.ExitCode,
+		FailureReason: job.FailureReason,
+		WorkerID:      job.WorkerID,
+		Output:        job.Output,
+	}
+	if job.State == model.StateFailed {
+		info.NextRunAt = job.NextRunAt
+	}
+	if len(info.Output) > outputTailBytes {
+		info.Output = info.Output[len(info.Output)-outputTailBytes:]
+	}
+	return Event{ID: newID(), Type: EventJobState, State: job.State, At: at, Job: info}
+}
+
+// Matches reports whether n is triggered by ev.
+func Matches(n *config.Notifier, ev Event) bool {
+	return slices.Contains(n.States, ev.State) && (len(n.Queues) == 0 || slices.Contains(n.Queues, ev.Job.Queue))
+}
+
+// Dispatcher delivers events to the configured notifiers in the
+// background. A nil *Dispatcher ignores every event.
+type Dispatcher struct {
+	notifiers []config.Notifier
+	events    chan Event
+	ctx       context.Context
+	cancel    context.CancelFunc
+	inFlight  sync.WaitGroup
+	loopDone  chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// New starts a Dispatcher for notifiers. It returns nil when there are
+// none.
+func New(notifiers []config.Notifier) *Dispatcher {
+	if len(notifiers) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		notifiers: notifiers,
+		events:    make(chan Event, QueueSize),
+		ctx:       ctx,
+		cancel:    cancel,
+		loopDone:  make(chan struct{}),
+	}
+	go d.loop()
+	return d
+}
+
+// Notify queues an event for job's current state. It never blocks: when
+// the queue is full the event is dropped and logged.
+func (d *Dispatcher) Notify(job *model.Job) {
+	if d == nil {
+		return
+	}
+	ev := NewEvent(job, time.Now())
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	select {
+	case d.events <- ev:
+	default:
+		slog.Warn("notification queue full, event dropped", "component", "notify", "job_id", ev.Job.ID, "queue", ev.Job.Queue, "state", ev.State)
+	}
+}
+
+// Close stops accepting events and waits up to timeout for the queued and
+// running deliveries, then abandons the rest.
+func (d *Dispatcher) Close(timeout time.Duration) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.events)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		<-d.loopDone
+		d.inFlight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		slog.Warn("abandoning notification deliveries still pending", "component", "notify", "timeout", timeout.String())
+		d.cancel()
+		<-done
+	}
+	d.cancel()
+}
+
+func (d *Dispatcher) loop() {
+	defer close(d.loopDone)
+	slots := make(chan struct{}, MaxInFlight)
+	for ev := range d.events {
+		for i := range d.notifiers {
+			n := &d.notifiers[i]
+			if !Matches(n, ev) {
+				continue
+			}
+			slots <- struct{}{}
+			d.inFlight.Add(1)
+			go func() {
+				defer func() { <-slots; d.inFlight.Done() }()
+				if err := Deliver(d.ctx, n, ev); err != nil {
+					slog.Error("notification delivery failed", "component", "notify", "notifier", n.Name,
+						"job_id", ev.Job.ID, "queue", ev.Job.Queue, "state", ev.State, "err", err)
+				}
+			}()
+		}
+	}
+}
+
+// Deliver sends ev to n, trying again up to n.Retries times with an
+// exponential backoff. A webhook answering with a 4xx status other than
+// 408 and 429 is not retried.
+func Deliver(ctx context.Context, n *config.Notifier, ev Event) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	timeout := DefaultTimeout
+	if n.Timeout != "" {
+		if timeout, err = config.ParseDuration(n.Timeout); err != nil {
+			return err
+		}
+	}
+
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		var retry bool
+		if n.URL != "" {
+			retry, err = post(attemptCtx, n, ev, body)
+		} else {
+			retry, err = run(attemptCtx, n, ev, body)
+		}
+		cancel()
+		if err == nil || !retry || attempt >= n.Retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (giving up: %v)", err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends one webhook request. It reports whether a failure is worth
+// retrying.
+func post(ctx context.Context, n *config.Notifier, ev Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "queuectl")
+	req.Header.Set(HeaderEvent, ev.Type)
+	req.Header.Set(HeaderDelivery, ev.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if n.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(n.Secret, timestamp, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ue, ok := err.(*url.Error); ok {
+			ue.URL = RedactURL(ue.URL)
+		}
+		return true, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("%s answered %s", RedactURL(n.URL), resp.Status)
+}
+
+// Sign returns the HeaderSignature value for body sent at timestamp.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Redact returns n with its secret replaced by "***" and its URL passed
+// through RedactURL, for showing it to users.
+func Redact(n config.Notifier) config.Notifier {
+	if n.Secret != "" {
+		n.Secret = "***"
+	}
+	n.URL = RedactURL(n.URL)
+	return n
+}
+
+// RedactURL replaces the password and query values of a webhook URL, which
+// often carry a token, with "xxxxx" as url.URL.Redacted does. A URL that
+// does not parse is returned unchanged.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	if u.RawQuery != "" {
+		keys := slices.Sorted(maps.Keys(u.Query()))
+		for i, k := range keys {
+			keys[i] = url.QueryEscape(k) + "=xxxxx"
+		}
+		u.RawQuery = strings.Join(keys, "&")
+	}
+	return u.Redacted()
+}
+
+// run runs the hook command with the event on stdin and its main fields in
+// the environment.
+func run(ctx context.Context, n *config.Notifier, ev Event, body []byte) (bool, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", n.Command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"QUEUECTL_EVENT="+ev.Type,
+		"QUEUECTL_EVENT_ID="+ev.ID,
+		"QUEUECTL_JOB_ID="+ev.Job.ID,
+		"QUEUECTL_JOB_QUEUE="+ev.Job.Queue,
+		"QUEUECTL_JOB_STATE="+ev.State,
+		"QUEUECTL_JOB_FAILURE_REASON="+ev.Job.FailureReason,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if len(out) > 0 {
+			return true, fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+		}
+		return true, err
+	}
+	return false, nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,59 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// Flatten turns v into key/value rows, one per leaf field, with nested keys
+// joined by dots and list items indexed, e.g. "limits.cpu_seconds". Keys
+// keep the order of v's JSON encoding.
+func Flatten(v any) ([][]string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := decodeNode(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	var walk func(prefix string, n *node)
+	walk = func(prefix string, n *node) {
+		switch {
+		case n.kind == '{' && len(n.items) > 0:
+			for i, key := range n.keys {
+				walk(joinKey(prefix, key), n.items[i])
+			}
+		case n.kind == '[' && len(n.items) > 0:
+			for i, item := range n.items {
+				walk(joinKey(prefix, strconv.Itoa(i)), item)
+			}
+		case n.kind == '{':
+			rows = append(rows, []string{prefix, "{}"})
+		case n.kind == '[':
+			rows = append(rows, []string{prefix, "[]"})
+		case n.scalar == nil:
+			rows = append(rows, []string{prefix, ""})
+		default:
+			if s, ok := n.scalar.(string); ok {
+				rows = append(rows, []string{prefix, s})
+			} else {
+				rows = append(rows, []string{prefix, formatScalar(n.scalar)})
+			}
+		}
+	}
+	walk("", root)
+	return rows, nil
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
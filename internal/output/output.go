@@ -0,0 +1,121 @@
+// Package output renders command results as aligned tables or in
+// machine-readable formats.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+type Format string
+
+const (
+	Table Format = "table"
+	JSON  Format = "json"
+	JSONL Format = "jsonl"
+	YAML  Format = "yaml"
+	CSV   Format = "csv"
+)
+
+// Formats lists every supported format.
+var Formats = []Format{Table, JSON, JSONL, YAML, CSV}
+
+// ParseFormat validates a format name. "text" is accepted as an alias for
+// table.
+func ParseFormat(s string) (Format, error) {
+	if s == "" || s == "text" {
+		return Table, nil
+	}
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unknown output format %q (use %s)", s, strings.Join(names, ", "))
+}
+
+// Result is a command's output in a form every format can render.
+type Result struct {
+	// Columns and Rows are rendered by table and csv, in this order.
+	Columns []string
+	Rows    [][]string
+	// Records is rendered by json, jsonl and yaml. Each element of a slice
+	// becomes one JSONL line.
+	Records any
+	// Text, when set, replaces the aligned table in the table format, for
+	// output that is not naturally tabular.
+	Text func(w io.Writer) error
+}
+
+// Write renders r to w in format f.
+func Write(w io.Writer, f Format, r Result) error {
+	switch f {
+	case JSON:
+		data, err := json.MarshalIndent(r.Records, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case JSONL:
+		return writeJSONL(w, r.Records)
+	case YAML:
+		return WriteYAML(w, r.Records)
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(r.Columns); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(r.Rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	default:
+		if r.Text != nil {
+			return r.Text(w)
+		}
+		return WriteTable(w, r.Columns, r.Rows)
+	}
+}
+
+// WriteTable writes rows under a header with each column aligned.
+func WriteTable(w io.Writer, columns []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = strings.ToUpper(c)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			// Tabs and newlines would break the alignment.
+			cells[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(cell)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeJSONL(w io.Writer, records any) error {
+	enc := json.NewEncoder(w)
+	v := reflect.ValueOf(records)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return enc.Encode(records)
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := enc.Encode(v.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package output
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"": Table, "text": Table, "table": Table, "jsonl": JSONL, "csv": CSV} {
+		if got, err := ParseFormat(in); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("ParseFormat accepted xml")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	type row struct {
+		ID    string `json:"id"`
+		Count int    `json:"count"`
+	}
+	r := Result{
+		Columns: []string{"id", "note"},
+		Rows:    [][]string{{"a", "two\twords"}, {"bb", "line\nbreak, \"quoted\""}},
+		Records: []row{{"a", 1}, {"bb", 2}},
+	}
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{Table, "ID  NOTE\na   two words\nbb  line break, \"quoted\"\n"},
+		{CSV, "id,note\na,two\twords\nbb,\"line\nbreak, \"\"quoted\"\"\"\n"},
+		{JSON, "[\n  {\n    \"id\": \"a\",\n    \"count\": 1\n  },\n  {\n    \"id\": \"bb\",\n    \"count\": 2\n  }\n]\n"},
+		{JSONL, "{\"id\":\"a\",\"count\":1}\n{\"id\":\"bb\",\"count\":2}\n"},
+		{YAML, "- id: a\n  count: 1\n- id: bb\n  count: 2\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Write(&buf, tt.format, r); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s:\n got %q\nwant %q", tt.format, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestWriteSingleRecordAndText(t *testing.T) {
+	r := Result{
+		Records: map[string]int{"pending": 2},
+		Text: func(w io.Writer) error {
+			_, err := io.WriteString(w, "2 pending\n")
+			return err
+		},
+	}
+	for format, want := range map[Format]string{
+		JSONL: "{\"pending\":2}\n",
+		Table: "2 pending\n",
+	} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, r); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != want {
+			t.Errorf("%s = %q, want %q", format, buf.String(), want)
+		}
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	type limits struct {
+		MemoryMB int `json:"memory_mb"`
+	}
+	v := struct {
+		ID       string            `json:"id"`
+		Limits   limits            `json:"limits"`
+		Tags     []string          `json:"tags"`
+		Env      map[string]string `json:"env"`
+		Empty    []string          `json:"empty"`
+		Deadline *string           `json:"deadline"`
+		Ratio    float64           `json:"ratio"`
+		Done     bool              `json:"done"`
+	}{
+		ID:     "a",
+		Limits: limits{MemoryMB: 64},
+		Tags:   []string{"x", "y"},
+		Env:    map[string]string{},
+		Empty:  []string{},
+		Ratio:  0.5,
+		Done:   true,
+	}
+	rows, err := Flatten(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"id", "a"},
+		{"limits.memory_mb", "64"},
+		{"tags.0", "x"},
+		{"tags.1", "y"},
+		{"env", "{}"},
+		{"empty", "[]"},
+		{"deadline", ""},
+		{"ratio", "0.5"},
+		{"done", "true"},
+	}
+	if !slices.EqualFunc(rows, want, slices.Equal) {
+		t.Errorf("Flatten =\n%q\nwant\n%q", rows, want)
+	}
+}
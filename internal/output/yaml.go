@@ -0,0 +1,224 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// WriteYAML writes v as a YAML document. v is first encoded as JSON, so
+// json struct tags decide the keys and their order is preserved.
+func WriteYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := decodeNode(dec)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	writeNode(&b, node, 0, true)
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// node is a decoded JSON value that keeps object keys in document order.
+type node struct {
+	kind   byte // '{', '[' or 0 for scalars
+	keys   []string
+	items  []*node
+	scalar any
+}
+
+func decodeNode(dec *json.Decoder) (*node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return &node{scalar: tok}, nil
+	}
+
+	n := &node{kind: byte(delim)}
+	for dec.More() {
+		if n.kind == '{' {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key.(string))
+		}
+		item, err := decodeNode(dec)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, item)
+	}
+	// Consume the closing delimiter.
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// writeNode writes n at the given depth. When inline is set the first line
+// continues the current one, as after a "- " list marker.
+func writeNode(b *strings.Builder, n *node, indent int, inline bool) {
+	pad := strings.Repeat("  ", indent)
+	switch {
+	case n.kind == '{' && len(n.items) == 0:
+		b.WriteString("{}\n")
+	case n.kind == '[' && len(n.items) == 0:
+		b.WriteString("[]\n")
+	case n.kind == '{':
+		for i, key := range n.keys {
+			if i > 0 || !inline {
+				b.WriteString(pad)
+			}
+			b.WriteString(formatScalar(key) + ":")
+			writeChild(b, n.items[i], indent+1, false)
+		}
+	case n.kind == '[':
+		for i, item := range n.items {
+			if i > 0 || !inline {
+				b.WriteString(pad)
+			}
+			b.WriteString("-")
+			writeChild(b, item, indent+1, true)
+		}
+	default:
+		b.WriteString(formatScalar(n.scalar) + "\n")
+	}
+}
+
+// writeChild writes the value following a "key:" or, when afterDash is set,
+// a "-" marker.
+func writeChild(b *strings.Builder, n *node, indent int, afterDash bool) {
+	if n.kind != 0 && len(n.items) > 0 {
+		if afterDash {
+			b.WriteString(" ")
+			writeNode(b, n, indent, true)
+			return
+		}
+		b.WriteString("\n")
+		writeNode(b, n, indent, false)
+		return
+	}
+	if s, ok := n.scalar.(string); ok && strings.Contains(s, "\n") && !strings.ContainsAny(s[:1], " \t\n") && printable(s, "\n\t") {
+		writeBlock(b, s, indent)
+		return
+	}
+	b.WriteString(" ")
+	writeNode(b, n, indent, true)
+}
+
+// writeBlock writes a multi-line string as a literal block scalar.
+func writeBlock(b *strings.Builder, s string, indent int) {
+	body := strings.TrimRight(s, "\n")
+	trailing := len(s) - len(body)
+	chomp := "+"
+	switch trailing {
+	case 0:
+		chomp = "-"
+	case 1:
+		chomp = ""
+	}
+	pad := strings.Repeat("  ", indent)
+	b.WriteString(" |" + chomp + "\n")
+	for _, line := range strings.Split(body, "\n") {
+		if line == "" {
+			b.WriteString("\n")
+			continue
+		}
+		b.WriteString(pad + line + "\n")
+	}
+	for i := 1; i < trailing; i++ {
+		b.WriteString("\n")
+	}
+}
+
+func formatScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprint(v)
+	case json.Number:
+		return v.String()
+	case string:
+		if needsQuotes(v) {
+			return quote(v)
+		}
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// quote returns s as a double-quoted YAML scalar, with everything that is
+// not printable escaped.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x80 && !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\x%02x`, r)
+		case !unicode.IsPrint(r) && r <= 0xffff:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		case !unicode.IsPrint(r):
+			fmt.Fprintf(&b, `\U%08x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// printable reports whether s holds only printable characters, spaces and
+// the characters in allowed, so that it can be written unescaped.
+func printable(s, allowed string) bool {
+	for _, r := range s {
+		if r != ' ' && !unicode.IsPrint(r) && !strings.ContainsRune(allowed, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// needsQuotes reports whether a plain YAML scalar would be read back as
+// something other than the string s.
+func needsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~":
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`0123456789.+") {
+		return true
+	}
+	if strings.HasSuffix(s, ":") || strings.Contains(s, ": ") || strings.Contains(s, " #") {
+		return true
+	}
+	return strings.ContainsAny(s, "\n\\") || !printable(s, "")
+}
@@ -0,0 +1,136 @@
+package output
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteYAML(t *testing.T) {
+	type limits struct {
+		MemoryMB int `json:"memory_mb,omitempty"`
+		CPU      int `json:"cpu_seconds,omitempty"`
+	}
+	type job struct {
+		ID     string            `json:"id"`
+		State  string            `json:"state"`
+		Limits *limits           `json:"limits,omitempty"`
+		Env    map[string]string `json:"env,omitempty"`
+		Tags   []string          `json:"tags"`
+		Output string            `json:"output,omitempty"`
+	}
+
+	tests := []struct {
+		name string
+		in   any
+		want string
+	}{
+		{
+			name: "struct keys keep their field order",
+			in:   job{ID: "a", State: "pending", Limits: &limits{MemoryMB: 64, CPU: 5}, Tags: []string{"x", "y"}},
+			want: "id: a\nstate: pending\nlimits:\n  memory_mb: 64\n  cpu_seconds: 5\ntags:\n  - x\n  - y\n",
+		},
+		{
+			name: "empty collections and null",
+			in:   map[string]any{"a": []int{}, "b": map[string]int{}, "c": nil},
+			want: "a: []\nb: {}\nc: null\n",
+		},
+		{
+			name: "list of objects",
+			in:   []map[string]any{{"id": "a", "n": 1}, {"id": "b", "n": 2.5}},
+			want: "- id: a\n  n: 1\n- id: b\n  n: 2.5\n",
+		},
+		{
+			name: "nested lists",
+			in:   [][]int{{1, 2}, {3}},
+			want: "- - 1\n  - 2\n- - 3\n",
+		},
+		{
+			name: "empty list at the top",
+			in:   []string{},
+			want: "[]\n",
+		},
+		{
+			name: "large numbers are kept exactly",
+			in:   map[string]int64{"n": 9007199254740993},
+			want: "n: 9007199254740993\n",
+		},
+		{
+			name: "multi-line output is a literal block",
+			in:   job{ID: "a", State: "dead", Tags: nil, Output: "line 1\n  indented\n\nline 4\n"},
+			want: "id: a\nstate: dead\ntags: null\noutput: |\n  line 1\n    indented\n\n  line 4\n",
+		},
+		{
+			name: "block scalars keep trailing newlines",
+			in:   []string{"a\nb", "a\nb\n\n"},
+			want: "- |-\n  a\n  b\n- |+\n  a\n  b\n\n",
+		},
+		{
+			name: "multi-line strings that cannot be blocks are quoted",
+			in:   []string{" lead\nx", "a\r\nb"},
+			want: "- \" lead\\nx\"\n- \"a\\r\\nb\"\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b strings.Builder
+			if err := WriteYAML(&b, tt.in); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", b.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteYAMLQuotesAmbiguousStrings(t *testing.T) {
+	tests := map[string]string{
+		"plain":          "plain",
+		"with space":     "with space",
+		"a:b":            "a:b",
+		"":               `""`,
+		" padded":        `" padded"`,
+		"true":           `"true"`,
+		"No":             `"No"`,
+		"null":           `"null"`,
+		"~":              `"~"`,
+		"42":             `"42"`,
+		"1.5":            `"1.5"`,
+		"-1":             `"-1"`,
+		"- item":         `"- item"`,
+		"key: value":     `"key: value"`,
+		"ends:":          `"ends:"`,
+		"a # comment":    `"a # comment"`,
+		"#tag":           `"#tag"`,
+		"*alias":         `"*alias"`,
+		"{x}":            `"{x}"`,
+		`say "hi"`:       `say "hi"`,
+		`'single'`:       `"'single'"`,
+		`back\slash`:     `"back\\slash"`,
+		"tab\there":      `"tab\there"`,
+		"bell\a":         `"bell\x07"`,
+		"nul\x00":        `"nul\x00"`,
+		"zero\u200bwide": `"zero\u200bwide"`,
+		"café":           "café",
+	}
+	for in, want := range tests {
+		var b strings.Builder
+		if err := WriteYAML(&b, map[string]string{"v": in}); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(strings.TrimPrefix(b.String(), "v: "), "\n"); got != want {
+			t.Errorf("%q: got %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestWriteYAMLQuotesKeys(t *testing.T) {
+	var b strings.Builder
+	if err := WriteYAML(&b, map[string]int{"1": 1, "on": 2, "ok": 3}); err != nil {
+		t.Fatal(err)
+	}
+	want := "\"1\": 1\nok: 3\n\"on\": 2\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
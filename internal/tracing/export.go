@@ -0,0 +1,340 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileExporter writes each span as a line of JSON, for testing without a
+// collector.
+type FileExporter struct {
+	service string
+
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// FileSpan is the JSON line FileExporter writes for a span.
+type FileSpan struct {
+	TraceID       string         `json:"trace_id"`
+	SpanID        string         `json:"span_id"`
+	ParentSpanID  string         `json:"parent_span_id,omitempty"`
+	Name          string         `json:"name"`
+	Service       string         `json:"service"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	DurationMS    float64        `json:"duration_ms"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Status        string         `json:"status"`
+	StatusMessage string         `json:"status_message,omitempty"`
+}
+
+// NewFileExporter appends spans to path, creating it if needed.
+func NewFileExporter(path, service string) (*FileExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: %w", err)
+	}
+	return &FileExporter{service: service, f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Export writes span. Write errors are logged.
+func (e *FileExporter) Export(span *SpanData) {
+	line := FileSpan{
+		TraceID:       hex.EncodeToString(span.Context.TraceID[:]),
+		SpanID:        hex.EncodeToString(span.Context.SpanID[:]),
+		Name:          span.Name,
+		Service:       e.service,
+		Start:         span.Start,
+		End:           span.End,
+		DurationMS:    float64(span.End.Sub(span.Start).Microseconds()) / 1000,
+		Attributes:    span.Attributes,
+		Status:        span.Status,
+		StatusMessage: span.StatusMessage,
+	}
+	if span.Parent.IsValid() {
+		line.ParentSpanID = hex.EncodeToString(span.Parent.SpanID[:])
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.f == nil {
+		return
+	}
+	if err := e.enc.Encode(line); err != nil {
+		slog.Warn("could not write span", "component", "tracing", "err", err)
+	}
+}
+
+// Shutdown closes the file.
+func (e *FileExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.f == nil {
+		return nil
+	}
+	err := e.f.Close()
+	e.f = nil
+	return err
+}
+
+const (
+	// DefaultOTLPEndpoint is used when neither the configuration nor
+	// OTEL_EXPORTER_OTLP_ENDPOINT names a collector.
+	DefaultOTLPEndpoint = "http://localhost:4318"
+	// otlpBatchSize is how many spans are sent in one request at most.
+	otlpBatchSize = 256
+	// otlpQueueSize is how many spans may wait to be sent. Spans beyond it
+	// are dropped rather than making a worker wait.
+	otlpQueueSize = 2048
+	// otlpFlushInterval is how long a span waits for a batch to fill up.
+	otlpFlushInterval = 5 * time.Second
+	// otlpTimeout bounds one export request.
+	otlpTimeout = 10 * time.Second
+)
+
+// OTLPExporter sends spans in batches to an OTLP/HTTP collector, encoded as
+// JSON. Export never blocks; failed requests are logged and their spans
+// dropped.
+type OTLPExporter struct {
+	url     string
+	headers map[string]string
+	service string
+
+	spans    chan *SpanData
+	loopDone chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int
+}
+
+// NewOTLPExporter starts an exporter posting to endpoint's /v1/traces path.
+// An empty endpoint falls back to OTEL_EXPORTER_OTLP_ENDPOINT, then to
+// DefaultOTLPEndpoint.
+func NewOTLPExporter(endpoint string, headers map[string]string, service string) (*OTLPExporter, error) {
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = DefaultOTLPEndpoint
+	}
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("tracing: OTLP endpoint %q must start with http:// or https://", endpoint)
+	}
+	e := &OTLPExporter{
+		url:      strings.TrimRight(endpoint, "/") + "/v1/traces",
+		headers:  headers,
+		service:  service,
+		spans:    make(chan *SpanData, otlpQueueSize),
+		loopDone: make(chan struct{}),
+	}
+	go e.loop()
+	return e, nil
+}
+
+// Export queues span for the next batch.
+func (e *OTLPExporter) Export(span *SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.spans <- span:
+	default:
+		e.dropped++
+		if e.dropped == 1 || e.dropped%1000 == 0 {
+			slog.Warn("span queue full, spans dropped", "component", "tracing", "dropped", e.dropped)
+		}
+	}
+}
+
+// Shutdown sends the spans still queued, giving up when ctx is done.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		close(e.spans)
+	}
+	e.mu.Unlock()
+	select {
+	case <-e.loopDone:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("tracing: spans not flushed: %w", ctx.Err())
+	}
+}
+
+func (e *OTLPExporter) loop() {
+	defer close(e.loopDone)
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+	var batch []*SpanData
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			slog.Error("span export failed", "component", "tracing", "spans", len(batch), "err", err)
+		}
+		batch = nil
+	}
+	for {
+		select {
+		case span, ok := <-e.spans:
+			if !ok {
+				flush()
+				return
+			}
+			if batch = append(batch, span); len(batch) >= otlpBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// send posts one batch.
+func (e *OTLPExporter) send(batch []*SpanData) error {
+	body, err := json.Marshal(otlpRequest(e.service, batch))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), otlpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "queuectl")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", e.url, resp.Status)
+	}
+	return nil
+}
+
+// The OTLP/JSON encoding of an ExportTraceServiceRequest, limited to the
+// fields queuectl sets.
+type (
+	otlpExport struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Flags             uint32         `json:"flags"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpKeyValue struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code,omitempty"`
+		Message string `json:"message,omitempty"`
+	}
+)
+
+// otlpSpanKindInternal is SPAN_KIND_INTERNAL.
+const otlpSpanKindInternal = 1
+
+func otlpRequest(service string, batch []*SpanData) otlpExport {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		span := otlpSpan{
+			TraceID:           hex.EncodeToString(s.Context.TraceID[:]),
+			SpanID:            hex.EncodeToString(s.Context.SpanID[:]),
+			Flags:             uint32(s.Context.Flags),
+			Name:              s.Name,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if s.Parent.IsValid() {
+			span.ParentSpanID = hex.EncodeToString(s.Parent.SpanID[:])
+		}
+		switch s.Status {
+		case StatusOK:
+			span.Status.Code = 1
+		case StatusError:
+			span.Status = otlpStatus{Code: 2, Message: s.StatusMessage}
+		}
+		spans = append(spans, span)
+	}
+	return otlpExport{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: otlpAttributes(map[string]any{"service.name": service})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "queuectl"}, Spans: spans}},
+	}}}
+}
+
+// otlpAttributes encodes attrs as OTLP key/value pairs, sorted by key.
+func otlpAttributes(attrs map[string]any) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		var value map[string]any
+		switch v := attrs[k].(type) {
+		case bool:
+			value = map[string]any{"boolValue": v}
+		case int:
+			value = map[string]any{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]any{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]any{"doubleValue": v}
+		default:
+			value = map[string]any{"stringValue": fmt.Sprint(v)}
+		}
+		out = append(out, otlpKeyValue{Key: k, Value: value})
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestFileExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	tracer, err := New(Options{Exporter: "file", File: path, ServiceName: "billing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := ParseTraceParent(testTraceParent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	span := tracer.Start(parent, "queuectl.job")
+	span.SetAttr("job.id", "a")
+	span.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Spans ending after shutdown are dropped.
+	tracer.Start(parent, "late").End()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var spans []FileSpan
+	lines := bufio.NewScanner(f)
+	for lines.Scan() {
+		var s FileSpan
+		if err := json.Unmarshal(lines.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		spans = append(spans, s)
+	}
+	if len(spans) != 1 {
+		t.Fatalf("file holds %d spans, want 1", len(spans))
+	}
+	s := spans[0]
+	if s.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || s.ParentSpanID != "00f067aa0ba902b7" ||
+		s.Name != "queuectl.job" || s.Service != "billing" || s.Attributes["job.id"] != "a" || s.Status != StatusUnset {
+		t.Errorf("span = %+v", s)
+	}
+}
+
+// collector is an OTLP/HTTP endpoint recording the requests it gets.
+type collector struct {
+	mu       sync.Mutex
+	requests []otlpExport
+	headers  []http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req otlpExport
+	if r.URL.Path != "/v1/traces" || json.Unmarshal(body, &req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, req)
+	c.headers = append(c.headers, r.Header.Clone())
+}
+
+func TestOTLPExporter(t *testing.T) {
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	tracer, err := New(Options{Exporter: "otlp", Endpoint: srv.URL + "/", Headers: map[string]string{"Authorization": "Bearer t"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := tracer.Start(SpanContext{}, "queuectl.job")
+	child := tracer.Start(root.Context(), "queuectl.execute")
+	child.SetAttr("exit_code", 3)
+	child.SetAttr("leftovers", false)
+	child.SetAttr("queue", "default")
+	child.SetError(io.ErrUnexpectedEOF)
+	child.End()
+	root.SetError(nil)
+	root.End()
+	// Shutdown sends what is queued without waiting for the flush
+	// interval.
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.requests) != 1 {
+		t.Fatalf("collector got %d requests, want 1", len(c.requests))
+	}
+	if got := c.headers[0].Get("Authorization"); got != "Bearer t" {
+		t.Errorf("Authorization = %q", got)
+	}
+	rs := c.requests[0].ResourceSpans[0]
+	if kv := rs.Resource.Attributes[0]; kv.Key != "service.name" || kv.Value["stringValue"] != "queuectl" {
+		t.Errorf("resource = %+v", rs.Resource)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	execute, job := spans[0], spans[1]
+	if execute.ParentSpanID != job.SpanID || execute.TraceID != job.TraceID || job.ParentSpanID != "" {
+		t.Errorf("spans are not linked: %+v, %+v", execute, job)
+	}
+	if execute.Status.Code != 2 || execute.Status.Message != io.ErrUnexpectedEOF.Error() || job.Status.Code != 1 {
+		t.Errorf("statuses = %+v, %+v", execute.Status, job.Status)
+	}
+	want := []otlpKeyValue{
+		{"exit_code", map[string]any{"intValue": "3"}},
+		{"leftovers", map[string]any{"boolValue": false}},
+		{"queue", map[string]any{"stringValue": "default"}},
+	}
+	got, _ := json.Marshal(execute.Attributes)
+	wantJSON, _ := json.Marshal(want)
+	if string(got) != string(wantJSON) {
+		t.Errorf("attributes = %s, want %s", got, wantJSON)
+	}
+
+	// Spans exported after shutdown are dropped.
+	tracer.Start(SpanContext{}, "late").End()
+}
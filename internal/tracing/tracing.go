@@ -0,0 +1,291 @@
+// Package tracing follows a job from the producer that enqueued it through
+// its execution. Trace context travels in the W3C traceparent format: a
+// producer hands one to enqueue, the job stores it, and the worker records
+// its claim, execution and state update as spans in that trace, exported
+// over OTLP/HTTP or to a local file.
+//
+// A nil *Tracer records nothing, and every *Span method is a no-op on a nil
+// span, so callers need not check whether tracing is enabled.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EnvTraceParent is the environment variable a job's process receives its
+// trace context in, and the one enqueue reads it from by default.
+const EnvTraceParent = "TRACEPARENT"
+
+// Span status codes.
+const (
+	StatusUnset = "unset"
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// SpanContext identifies a span within a trace. The zero value is invalid
+// and means "no parent": a span started from it begins a new trace.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Flags   byte
+}
+
+// IsValid reports whether sc has non-zero trace and span IDs.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Sampled reports whether the sampled flag is set.
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&0x01 != 0
+}
+
+// TraceParent formats sc as a version 00 traceparent header, or "" when sc
+// is invalid.
+func (sc SpanContext) TraceParent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), sc.Flags)
+}
+
+// ParseTraceParent parses a W3C traceparent header such as
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01". Versions
+// above 00 are accepted as long as they start with the version 00 fields.
+func ParseTraceParent(s string) (SpanContext, error) {
+	var sc SpanContext
+	invalid := func(why string) (SpanContext, error) {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q: %s", s, why)
+	}
+	if len(s) < 55 {
+		return invalid("too short")
+	}
+	if !isLowerHex(s[:2]) || s[:2] == "ff" {
+		return invalid("bad version")
+	}
+	if s[:2] == "00" && len(s) != 55 {
+		return invalid("version 00 must be 55 characters long")
+	}
+	if len(s) > 55 && s[55] != '-' {
+		return invalid("bad field separator")
+	}
+	parts := strings.SplitN(s[:55], "-", 4)
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return invalid("bad field layout")
+	}
+	for _, p := range parts[1:] {
+		if !isLowerHex(p) {
+			return invalid("fields must be lowercase hex")
+		}
+	}
+	hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	var flags [1]byte
+	hex.Decode(flags[:], []byte(parts[3]))
+	sc.Flags = flags[0]
+	if sc.TraceID == [16]byte{} {
+		return invalid("trace ID is all zeros")
+	}
+	if sc.SpanID == [8]byte{} {
+		return invalid("parent ID is all zeros")
+	}
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+type traceParentKey struct{}
+
+// WithTraceParent returns ctx carrying the traceparent a job's process
+// should see.
+func WithTraceParent(ctx context.Context, traceParent string) context.Context {
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// TraceParentFromContext returns the traceparent stored in ctx by
+// WithTraceParent, or "".
+func TraceParentFromContext(ctx context.Context) string {
+	tp, _ := ctx.Value(traceParentKey{}).(string)
+	return tp
+}
+
+// SpanData is a finished span as handed to an Exporter.
+type SpanData struct {
+	Name          string
+	Context       SpanContext
+	Parent        SpanContext
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]any
+	Status        string
+	StatusMessage string
+}
+
+// Exporter sends finished spans somewhere. Export must not block for long:
+// it is called from the worker loop.
+type Exporter interface {
+	Export(span *SpanData)
+	// Shutdown flushes buffered spans, giving up when ctx is done.
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer starts spans and hands them to its exporter when they end.
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer returns a Tracer exporting to exporter.
+func NewTracer(exporter Exporter) *Tracer {
+	return &Tracer{exporter: exporter}
+}
+
+// Exporters are the values accepted for Options.Exporter, besides "" which
+// disables tracing.
+var Exporters = []string{"otlp", "file"}
+
+// Options configure New.
+type Options struct {
+	// Exporter is "otlp", "file", or "" to disable tracing.
+	Exporter string
+	// Endpoint is the base URL of an OTLP/HTTP collector; spans are posted
+	// to its /v1/traces path.
+	Endpoint string
+	// Headers are added to every OTLP request, e.g. for authentication.
+	Headers map[string]string
+	// File receives one JSON span per line with the file exporter.
+	File string
+	// ServiceName is reported as the service.name resource attribute.
+	ServiceName string
+}
+
+// New returns the Tracer described by opts, or nil when tracing is
+// disabled.
+func New(opts Options) (*Tracer, error) {
+	if opts.ServiceName == "" {
+		opts.ServiceName = "queuectl"
+	}
+	switch opts.Exporter {
+	case "":
+		return nil, nil
+	case "otlp":
+		exp, err := NewOTLPExporter(opts.Endpoint, opts.Headers, opts.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		return NewTracer(exp), nil
+	case "file":
+		if opts.File == "" {
+			return nil, errors.New("tracing: the file exporter needs a file")
+		}
+		exp, err := NewFileExporter(opts.File, opts.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		return NewTracer(exp), nil
+	default:
+		return nil, fmt.Errorf("tracing: unknown exporter %q (use otlp or file)", opts.Exporter)
+	}
+}
+
+// Shutdown flushes the exporter. It is a no-op on a nil Tracer.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+// Start begins a span named name now. See StartAt.
+func (t *Tracer) Start(parent SpanContext, name string) *Span {
+	return t.StartAt(parent, name, time.Now())
+}
+
+// StartAt begins a span named name at start, as a child of parent, or as
+// the root of a new, sampled trace when parent is invalid. It returns nil on
+// a nil Tracer.
+func (t *Tracer) StartAt(parent SpanContext, name string, start time.Time) *Span {
+	if t == nil {
+		return nil
+	}
+	sc := SpanContext{TraceID: parent.TraceID, Flags: parent.Flags}
+	if !parent.IsValid() {
+		rand.Read(sc.TraceID[:])
+		sc.Flags = 0x01
+	}
+	rand.Read(sc.SpanID[:])
+	return &Span{tracer: t, data: SpanData{
+		Name:       name,
+		Context:    sc,
+		Parent:     parent,
+		Start:      start,
+		Attributes: make(map[string]any),
+		Status:     StatusUnset,
+	}}
+}
+
+// Span is an operation being timed. It is not safe for concurrent use.
+type Span struct {
+	tracer *Tracer
+	data   SpanData
+	ended  bool
+}
+
+// Context returns the span's context, or the zero SpanContext for a nil
+// span.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.Context
+}
+
+// SetAttr records an attribute. Values should be strings, bools, integers
+// or floats.
+func (s *Span) SetAttr(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError marks the span as failed with err's message. A nil err marks it
+// as successful.
+func (s *Span) SetError(err error) {
+	if s == nil {
+		return
+	}
+	if err == nil {
+		s.data.Status, s.data.StatusMessage = StatusOK, ""
+		return
+	}
+	s.data.Status, s.data.StatusMessage = StatusError, err.Error()
+}
+
+// End finishes the span now and exports it if its trace is sampled. Only
+// the first call has an effect.
+func (s *Span) End() {
+	if s == nil || s.ended {
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	if s.data.Context.Sampled() {
+		data := s.data
+		s.tracer.exporter.Export(&data)
+	}
+}
@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceParent(t *testing.T) {
+	sc, err := ParseTraceParent(testTraceParent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sc.IsValid() || !sc.Sampled() || sc.TraceParent() != testTraceParent {
+		t.Errorf("parsed %+v, formatted %q", sc, sc.TraceParent())
+	}
+	future := "01" + testTraceParent[2:] + "-extra"
+	if sc, err := ParseTraceParent(future); err != nil || sc.TraceParent() != testTraceParent {
+		t.Errorf("a later version = %q, %v", sc.TraceParent(), err)
+	}
+	if sc, err := ParseTraceParent(testTraceParent[:53] + "00"); err != nil || sc.Sampled() {
+		t.Errorf("an unsampled traceparent = %+v, %v", sc, err)
+	}
+
+	for name, tp := range map[string]string{
+		"empty":            "",
+		"too short":        testTraceParent[:54],
+		"version ff":       "ff" + testTraceParent[2:],
+		"00 too long":      testTraceParent + "-extra",
+		"bad separator":    "01" + testTraceParent[2:] + "x",
+		"uppercase":        strings.ToUpper(testTraceParent),
+		"zero trace ID":    "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"zero parent ID":   "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"misplaced dashes": "00-4bf92f3577b34da6a3ce929d0e0e473-600f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceParent(tp); err == nil {
+			t.Errorf("%s: ParseTraceParent(%q) succeeded", name, tp)
+		}
+	}
+	if (SpanContext{}).TraceParent() != "" {
+		t.Error("the zero SpanContext has a traceparent")
+	}
+}
+
+// recorder is an Exporter keeping the spans it is given.
+type recorder struct {
+	spans []*SpanData
+}
+
+func (r *recorder) Export(span *SpanData)              { r.spans = append(r.spans, span) }
+func (r *recorder) Shutdown(ctx context.Context) error { return nil }
+
+func TestSpans(t *testing.T) {
+	rec := &recorder{}
+	tracer := NewTracer(rec)
+
+	root := tracer.Start(SpanContext{}, "job")
+	child := tracer.Start(root.Context(), "execute")
+	child.SetAttr("exit_code", 3)
+	child.SetError(errors.New("exit status 3"))
+	child.End()
+	child.End()
+	root.SetError(nil)
+	root.End()
+
+	if len(rec.spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(rec.spans))
+	}
+	c, r := rec.spans[0], rec.spans[1]
+	if !r.Context.Sampled() || r.Parent.IsValid() || r.Status != StatusOK {
+		t.Errorf("root span = %+v, want a sampled new trace", r)
+	}
+	if c.Context.TraceID != r.Context.TraceID || c.Parent != r.Context || c.Context.SpanID == r.Context.SpanID {
+		t.Errorf("child span %+v is not a child of %+v", c.Context, r.Context)
+	}
+	if c.Status != StatusError || c.StatusMessage != "exit status 3" || c.Attributes["exit_code"] != 3 || c.End.Before(c.Start) {
+		t.Errorf("child span = %+v", c)
+	}
+
+	// A trace its producer did not sample is not exported.
+	unsampled, err := ParseTraceParent(testTraceParent[:53] + "00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracer.Start(unsampled, "job").End()
+	if len(rec.spans) != 2 {
+		t.Error("exported a span of an unsampled trace")
+	}
+}
+
+func TestNilTracerAndSpan(t *testing.T) {
+	var tracer *Tracer
+	span := tracer.Start(SpanContext{}, "job")
+	if span != nil {
+		t.Fatal("a nil Tracer started a span")
+	}
+	span.SetAttr("k", "v")
+	span.SetError(errors.New("ignored"))
+	span.End()
+	if span.Context().IsValid() {
+		t.Error("a nil span has a valid context")
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if tp := TraceParentFromContext(WithTraceParent(context.Background(), testTraceParent)); tp != testTraceParent {
+		t.Errorf("traceparent from the context = %q", tp)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if tracer, err := New(Options{}); tracer != nil || err != nil {
+		t.Errorf("New without an exporter = %v, %v, want tracing disabled", tracer, err)
+	}
+	for _, opts := range []Options{{Exporter: "jaeger"}, {Exporter: "file"}, {Exporter: "otlp", Endpoint: "localhost:4318"}} {
+		if _, err := New(opts); err == nil {
+			t.Errorf("New(%+v) succeeded", opts)
+		}
+	}
+}
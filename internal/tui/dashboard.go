@@ -0,0 +1,490 @@
+// Package tui implements the full-screen 'queuectl top' dashboard.
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"queueCtl/internal/audit"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"queueCtl/internal/notify"
+	"queueCtl/internal/output"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Section sizes on the main view; smaller terminals show fewer rows.
+const (
+	maxRunning  = 10
+	maxFailures = 5
+	maxDead     = 10
+)
+
+// highlight marks a line to be drawn in reverse video.
+const highlight = "\x00"
+
+type view int
+
+const (
+	viewMain view = iota
+	viewDetail
+	viewLogs
+)
+
+// Dashboard shows live queue state and acts on the selected job.
+type Dashboard struct {
+	store *storage.Store
+	// notifier is told about the jobs canceled from the dashboard.
+	notifier *notify.Dispatcher
+	interval time.Duration
+
+	stats         map[string]int
+	completed1m   int
+	completed5m   int
+	running       []model.Job
+	failures      []model.Job
+	dead          []model.Job
+	loadErr       error
+	lastRefreshed time.Time
+
+	// selected indexes the concatenation of running, failures and dead.
+	selected int
+	view     view
+	// focus is the job shown by the detail and log views.
+	focus   *model.Job
+	message string
+}
+
+func New(store *storage.Store, notifier *notify.Dispatcher, interval time.Duration) *Dashboard {
+	return &Dashboard{store: store, notifier: notifier, interval: interval}
+}
+
+// Run takes over the terminal until the user quits.
+func (d *Dashboard) Run() error {
+	term, err := openTerminal()
+	if err != nil {
+		return err
+	}
+	defer term.restore()
+
+	keys := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go term.readKeys(keys, done)
+
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	d.refresh()
+	for {
+		rows, cols := term.size()
+		fmt.Print(d.render(rows, cols))
+
+		select {
+		case <-ticker.C:
+			d.refresh()
+		case key, ok := <-keys:
+			if !ok || key == "q" || key == keyQuit {
+				return nil
+			}
+			d.handleKey(key)
+		}
+	}
+}
+
+// refresh reloads everything the current view shows.
+func (d *Dashboard) refresh() {
+	d.lastRefreshed = time.Now()
+	d.loadErr = d.load()
+
+	if d.focus != nil {
+		if job, err := d.store.GetJob(d.focus.ID); err == nil {
+			d.focus = job
+		}
+	}
+	if n := len(d.selectable()); d.selected >= n {
+		d.selected = max(n-1, 0)
+	}
+}
+
+func (d *Dashboard) load() error {
+	var err error
+	if d.stats, err = d.store.GetJobStats(); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, window := range []struct {
+		since time.Duration
+		dest  *int
+	}{{time.Minute, &d.completed1m}, {5 * time.Minute, &d.completed5m}} {
+		*window.dest, err = d.store.CountJobs(storage.JobFilter{
+			States:       []string{model.StateCompleted},
+			UpdatedAfter: now.Add(-window.since),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	page, err := d.store.ListJobs(storage.JobFilter{States: []string{model.StateProcessing}, SortBy: "updated_at", Limit: maxRunning})
+	if err != nil {
+		return err
+	}
+	d.running = page.Jobs
+
+	page, err = d.store.ListJobs(storage.JobFilter{States: []string{model.StateFailed}, SortBy: "updated_at", Descending: true, Limit: maxFailures})
+	if err != nil {
+		return err
+	}
+	d.failures = page.Jobs
+
+	page, err = d.store.ListJobs(storage.JobFilter{States: []string{model.StateDead}, SortBy: "updated_at", Descending: true, Limit: maxDead})
+	if err != nil {
+		return err
+	}
+	d.dead = page.Jobs
+	return nil
+}
+
+// selectable lists the jobs on the main view in display order.
+func (d *Dashboard) selectable() []model.Job {
+	jobs := append([]model.Job{}, d.running...)
+	jobs = append(jobs, d.failures...)
+	return append(jobs, d.dead...)
+}
+
+func (d *Dashboard) selectedJob() *model.Job {
+	jobs := d.selectable()
+	if d.selected < 0 || d.selected >= len(jobs) {
+		return nil
+	}
+	return &jobs[d.selected]
+}
+
+func (d *Dashboard) handleKey(key string) {
+	if d.view != viewMain {
+		switch key {
+		case keyEsc, keyEnter, "b":
+			d.view, d.focus = viewMain, nil
+		case "l":
+			d.view = viewLogs
+		case "i":
+			d.view = viewDetail
+		case "r", "c":
+			d.act(key, d.focus)
+		}
+		return
+	}
+
+	d.message = ""
+	switch key {
+	case keyUp, "k":
+		if d.selected > 0 {
+			d.selected--
+		}
+	case keyDown, "j":
+		if d.selected < len(d.selectable())-1 {
+			d.selected++
+		}
+	case keyEnter, "i":
+		if job := d.selectedJob(); job != nil {
+			d.focus, d.view = job, viewDetail
+		}
+	case "l":
+		if job := d.selectedJob(); job != nil {
+			d.focus, d.view = job, viewLogs
+		}
+	case "r", "c":
+		d.act(key, d.selectedJob())
+	}
+}
+
+// act retries or cancels job and reports the outcome on the status line.
+func (d *Dashboard) act(key string, job *model.Job) {
+	if job == nil {
+		return
+	}
+	var err error
+	var done string
+	switch key {
+	case "r":
+		err, done = d.store.RetryDeadJob(job.ID), "moved back to pending"
+	case "c":
+		err, done = d.store.CancelJob(job.ID), "canceled"
+	}
+	action := map[string]string{"r": "dlq retry", "c": "job cancel"}[key]
+	audit.Record(d.store, model.AuditSourceCLI, action, []string{job.ID}, err)
+	if key == "c" && err == nil {
+		if canceled, err := d.store.GetJob(job.ID); err == nil {
+			d.notifier.Notify(canceled)
+		}
+	}
+	switch {
+	case errors.Is(err, storage.ErrInvalidState) && key == "r":
+		d.message = fmt.Sprintf("%s: only dead jobs can be retried", job.ID)
+	case errors.Is(err, storage.ErrInvalidState):
+		d.message = fmt.Sprintf("%s: already finished", job.ID)
+	case err != nil:
+		d.message = fmt.Sprintf("%s: %v", job.ID, err)
+	default:
+		d.message = fmt.Sprintf("%s %s", job.ID, done)
+	}
+	d.refresh()
+}
+
+// render draws a full frame over the previous one. Raw mode needs explicit
+// carriage returns.
+func (d *Dashboard) render(rows, cols int) string {
+	var lines []string
+	switch d.view {
+	case viewDetail:
+		lines = d.detailLines(rows)
+	case viewLogs:
+		lines = d.logLines(rows)
+	default:
+		lines = d.mainLines()
+	}
+
+	if len(lines) > rows-1 {
+		lines = lines[:rows-1]
+	}
+	for len(lines) < rows-1 {
+		lines = append(lines, "")
+	}
+	lines = append(lines, highlight+d.footer())
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range lines {
+		if text, ok := strings.CutPrefix(line, highlight); ok {
+			b.WriteString("\x1b[7m" + fit(text, cols) + "\x1b[0m")
+		} else {
+			b.WriteString(fit(line, cols))
+		}
+		if i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	return b.String()
+}
+
+func (d *Dashboard) footer() string {
+	help := " q quit  ↑/↓ select  enter inspect  l logs  r retry  c cancel"
+	if d.view != viewMain {
+		help = " esc back  i inspect  l logs  r retry  c cancel  q quit"
+	}
+	if d.message != "" {
+		help += "  |  " + d.message
+	}
+	return help
+}
+
+func (d *Dashboard) mainLines() []string {
+	lines := []string{
+		fmt.Sprintf("queuectl top  %s  (every %v)", d.lastRefreshed.Format("2006-01-02 15:04:05"), d.interval),
+		"",
+	}
+	if d.loadErr != nil {
+		lines = append(lines, "Error: "+d.loadErr.Error(), "")
+	}
+
+	counts := []string{}
+	for _, state := range model.States {
+		counts = append(counts, fmt.Sprintf("%s %d", state, d.stats[state]))
+	}
+	lines = append(lines,
+		"JOBS        "+strings.Join(counts, "   "),
+		fmt.Sprintf("THROUGHPUT  %d completed in the last minute, %.1f/min over 5 minutes", d.completed1m, float64(d.completed5m)/5),
+		"",
+	)
+
+	now := time.Now()
+	offset := 0
+	lines = append(lines, d.section(fmt.Sprintf("RUNNING (%d)", d.stats[model.StateProcessing]),
+		[]string{"ID", "WORKER", "ELAPSED", "ATTEMPT", "COMMAND"}, d.running, offset,
+		func(j model.Job) []string {
+			return []string{j.ID, j.WorkerID, now.Sub(j.UpdatedAt).Round(time.Second).String(), fmt.Sprintf("%d/%d", j.Attempts, j.MaxRetries), describe(j)}
+		})...)
+	offset += len(d.running)
+
+	lines = append(lines, d.section(fmt.Sprintf("RECENT FAILURES (%d)", d.stats[model.StateFailed]),
+		[]string{"ID", "ATTEMPT", "REASON", "EXIT", "NEXT RUN", "LAST OUTPUT"}, d.failures, offset,
+		func(j model.Job) []string {
+			return []string{j.ID, fmt.Sprintf("%d/%d", j.Attempts, j.MaxRetries), j.FailureReason, strconv.Itoa(j.ExitCode), j.NextRunAt.Format("15:04:05"), lastLine(j.Output)}
+		})...)
+	offset += len(d.failures)
+
+	lines = append(lines, d.section(fmt.Sprintf("DEAD LETTER QUEUE (%d)", d.stats[model.StateDead]),
+		[]string{"ID", "ATTEMPTS", "REASON", "EXIT", "DIED", "COMMAND"}, d.dead, offset,
+		func(j model.Job) []string {
+			return []string{j.ID, strconv.Itoa(j.Attempts), j.FailureReason, strconv.Itoa(j.ExitCode), j.UpdatedAt.Format("01-02 15:04:05"), describe(j)}
+		})...)
+	return lines
+}
+
+// section renders an aligned table of jobs, highlighting the selected one.
+// offset is the index of the first job among all selectable jobs.
+func (d *Dashboard) section(title string, columns []string, jobs []model.Job, offset int, row func(model.Job) []string) []string {
+	lines := []string{title}
+	if len(jobs) == 0 {
+		return append(lines, "  (none)", "")
+	}
+
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  "+strings.Join(columns, "\t"))
+	for _, j := range jobs {
+		cells := row(j)
+		for i, c := range cells {
+			cells[i] = sanitize(strings.ReplaceAll(c, "\n", " "))
+		}
+		fmt.Fprintln(tw, "  "+strings.Join(cells, "\t"))
+	}
+	tw.Flush()
+
+	table := strings.Split(strings.TrimRight(b.String(), "\n"), "\n")
+	lines = append(lines, table[0])
+	for i, line := range table[1:] {
+		if offset+i == d.selected {
+			line = highlight + ">" + line[1:]
+		}
+		lines = append(lines, line)
+	}
+	return append(lines, "")
+}
+
+func (d *Dashboard) detailLines(rows int) []string {
+	if d.focus == nil {
+		return nil
+	}
+	job := *d.focus
+	out := job.Output
+	job.Output = ""
+
+	lines := []string{"JOB " + job.ID, ""}
+	fields, err := output.Flatten(job)
+	if err != nil {
+		return append(lines, "Error: "+err.Error())
+	}
+	for _, f := range fields {
+		lines = append(lines, fmt.Sprintf("  %-22s %s", f[0], f[1]))
+	}
+	lines = append(lines, "", "OUTPUT (tail)")
+	return append(lines, tail(out, rows-len(lines)-2)...)
+}
+
+func (d *Dashboard) logLines(rows int) []string {
+	if d.focus == nil {
+		return nil
+	}
+	lines := []string{fmt.Sprintf("OUTPUT OF %s (%s, attempt %d/%d)", d.focus.ID, d.focus.State, d.focus.Attempts, d.focus.MaxRetries), ""}
+	if d.focus.State == model.StateProcessing {
+		lines = append(lines, "Following the running attempt; its output is saved every few seconds.", "")
+	}
+	return append(lines, tail(d.focus.Output, rows-len(lines)-2)...)
+}
+
+// describe is a job's command, or its handler type for Go jobs.
+func describe(j model.Job) string {
+	if j.Type != "" && j.Type != model.TypeShell {
+		return "[" + j.Type + "]"
+	}
+	return j.Command
+}
+
+// tail returns the last n lines of out. A line rewritten in place, as
+// progress bars do with carriage returns, shows its last version.
+func tail(out string, n int) []string {
+	out = strings.TrimRight(out, "\r\n")
+	if out == "" {
+		return []string{"(empty)"}
+	}
+	lines := strings.Split(out, "\n")
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if j := strings.LastIndexByte(line, '\r'); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = sanitize(line)
+	}
+	return lines
+}
+
+// sanitize removes what a job's output or fields could use to take over
+// the terminal: escape sequences and control characters. Tabs become
+// spaces.
+func sanitize(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\x1b':
+			i = skipEscape(s, i)
+		case c == '\t':
+			b.WriteByte(' ')
+		case c < 0x20 || c == 0x7f:
+		default:
+			b.WriteByte(c)
+		}
+	}
+	// C1 controls, such as the single-character CSI, are runes.
+	return strings.Map(func(r rune) rune {
+		if r >= 0x80 && r < 0xa0 {
+			return -1
+		}
+		return r
+	}, b.String())
+}
+
+// skipEscape returns the index of the last byte of the escape sequence
+// starting at s[i].
+func skipEscape(s string, i int) int {
+	if i+1 >= len(s) {
+		return i
+	}
+	switch s[i+1] {
+	case '[':
+		// CSI: parameters and intermediates up to a final byte.
+		for j := i + 2; j < len(s); j++ {
+			if s[j] >= 0x40 && s[j] <= 0x7e {
+				return j
+			}
+		}
+		return len(s) - 1
+	case ']', 'P', '_', '^':
+		// OSC and other strings end with BEL or ST.
+		for j := i + 2; j < len(s); j++ {
+			if s[j] == '\a' {
+				return j
+			}
+			if s[j] == '\x1b' && j+1 < len(s) && s[j+1] == '\\' {
+				return j + 1
+			}
+		}
+		return len(s) - 1
+	}
+	return i + 1
+}
+
+func lastLine(out string) string {
+	lines := tail(out, 1)
+	if lines[0] == "(empty)" {
+		return ""
+	}
+	return lines[0]
+}
+
+// fit sanitizes a line and truncates or pads it to exactly cols characters.
+func fit(line string, cols int) string {
+	line = sanitize(line)
+	runes := []rune(line)
+	if len(runes) > cols {
+		return string(runes[:cols])
+	}
+	return line + strings.Repeat(" ", cols-len(runes))
+}
@@ -0,0 +1,149 @@
+package tui
+
+import (
+	"path/filepath"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain text", "plain text"},
+		{"\x1b[31mred\x1b[0m", "red"},
+		{"\x1b[2J\x1b[Hcleared", "cleared"},
+		{"\x1b]0;title\abody", "body"},
+		{"\x1b]8;;http://x\x1b\\link", "link"},
+		{"\x1bPdcs\x1b\\ok", "ok"},
+		{"a\tb", "a b"},
+		{"bell\a back\b del\x7f", "bell back del"},
+		{"c1 \u009b31m csi", "c1 31m csi"},
+		{"unicode ✓ kept", "unicode ✓ kept"},
+		{"cut \x1b[", "cut "},
+		{"trailing \x1b", "trailing "},
+	}
+	for _, tt := range tests {
+		if got := sanitize(tt.in); got != tt.want {
+			t.Errorf("sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTail(t *testing.T) {
+	tests := []struct {
+		out  string
+		n    int
+		want []string
+	}{
+		{"", 5, []string{"(empty)"}},
+		{"\r\n", 5, []string{"(empty)"}},
+		{"a\nb\nc\n", 2, []string{"b", "c"}},
+		{"a\nb\n", 0, []string{"a", "b"}},
+		{"10%\r50%\r100%\ndone\r\n", 5, []string{"100%", "done"}},
+		{"\x1b[32mok\x1b[0m", 1, []string{"ok"}},
+	}
+	for _, tt := range tests {
+		if got := tail(tt.out, tt.n); !slices.Equal(got, tt.want) {
+			t.Errorf("tail(%q, %d) = %q, want %q", tt.out, tt.n, got, tt.want)
+		}
+	}
+	if got := lastLine(""); got != "" {
+		t.Errorf("lastLine of no output = %q", got)
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		line string
+		cols int
+		want string
+	}{
+		{"abc", 5, "abc  "},
+		{"abcdef", 4, "abcd"},
+		{"✓✓✓", 2, "✓✓"},
+		{"\x1b[2Jx", 3, "x  "},
+		{"\x1b[31mlong red\x1b[0m", 4, "long"},
+	}
+	for _, tt := range tests {
+		if got := fit(tt.line, tt.cols); got != tt.want {
+			t.Errorf("fit(%q, %d) = %q, want %q", tt.line, tt.cols, got, tt.want)
+		}
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	store, err := storage.NewStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Db.Close() })
+	for _, j := range []*model.Job{
+		{ID: "running", Command: "sleep 60"},
+		{ID: "dead", Command: "printf '\\033[2Jgone'"},
+	} {
+		if err := j.Prepare(time.Now().Add(-time.Minute), 3); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateJob(j); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range 2 {
+		if _, err := store.FindAndLock("w1", []string{model.TypeShell}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dead, err := store.GetJob("dead")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead.State, dead.Output, dead.UpdatedAt = model.StateDead, "\x1b[2Jgone\n", time.Now()
+	if err := store.UpdateJob(dead); err != nil {
+		t.Fatal(err)
+	}
+
+	d := New(store, nil, time.Second)
+	d.refresh()
+	if d.loadErr != nil {
+		t.Fatal(d.loadErr)
+	}
+	frame := d.render(30, 100)
+	if n := strings.Count(frame, "\r\n"); n != 29 {
+		t.Errorf("frame has %d lines, want 30", n+1)
+	}
+	if strings.Contains(frame, "\x1b[2J") {
+		t.Error("frame passes on the job's escape sequence")
+	}
+	for _, want := range []string{"RUNNING (1)", "DEAD LETTER QUEUE (1)", "\x1b[7m> running"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("frame lacks %q:\n%s", want, frame)
+		}
+	}
+
+	// Retrying the running job is refused; moving down selects the dead
+	// one, which is retried.
+	d.handleKey("r")
+	if d.message != "running: only dead jobs can be retried" {
+		t.Errorf("message = %q", d.message)
+	}
+	d.handleKey(keyDown)
+	d.handleKey(keyEnter)
+	if d.view != viewDetail || d.focus == nil || d.focus.ID != "dead" {
+		t.Fatalf("view %d focused on %+v, want the dead job's details", d.view, d.focus)
+	}
+	d.handleKey("r")
+	if d.message != "dead moved back to pending" || d.focus.State != model.StatePending {
+		t.Errorf("message = %q, focused job is %s", d.message, d.focus.State)
+	}
+	d.handleKey(keyEsc)
+	d.handleKey("c")
+	if d.message != "running canceled" {
+		t.Errorf("message = %q", d.message)
+	}
+	if st, _ := store.GetJobState("running"); st != model.StateCanceled {
+		t.Errorf("running is %s after cancel", st)
+	}
+}
@@ -0,0 +1,121 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// terminal puts the controlling terminal in raw mode on the alternate
+// screen, and restores it afterwards. It drives stty, so it works on any
+// Unix-like system without cgo or extra dependencies.
+type terminal struct {
+	// tty is where keys are read from; closing it ends readKeys.
+	tty   *os.File
+	saved string
+}
+
+func openTerminal() (*terminal, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("an interactive terminal is required: %w", err)
+	}
+	t := &terminal{tty: tty}
+	if t.saved, err = t.stty("-g"); err != nil {
+		tty.Close()
+		return nil, fmt.Errorf("an interactive terminal is required: %w", err)
+	}
+	if _, err := t.stty("raw", "-echo"); err != nil {
+		tty.Close()
+		return nil, err
+	}
+	// Switch to the alternate screen and hide the cursor.
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	return t, nil
+}
+
+func (t *terminal) restore() {
+	fmt.Print("\x1b[?25h\x1b[?1049l")
+	t.stty(t.saved)
+	t.tty.Close()
+}
+
+// size returns the terminal's rows and columns, defaulting to 24x80.
+func (t *terminal) size() (int, int) {
+	out, err := t.stty("size")
+	if err == nil {
+		fields := strings.Fields(out)
+		if len(fields) == 2 {
+			rows, err1 := strconv.Atoi(fields[0])
+			cols, err2 := strconv.Atoi(fields[1])
+			if err1 == nil && err2 == nil && rows > 0 && cols > 0 {
+				return rows, cols
+			}
+		}
+	}
+	return 24, 80
+}
+
+func (t *terminal) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = t.tty
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// Keys reported by readKeys besides single printable characters.
+const (
+	keyUp    = "up"
+	keyDown  = "down"
+	keyEnter = "enter"
+	keyEsc   = "esc"
+	keyQuit  = "ctrl-c"
+)
+
+// readKeys sends each key pressed on the terminal to keys until reading
+// fails, which closing the terminal causes, or done is closed.
+func (t *terminal) readKeys(keys chan<- string, done <-chan struct{}) {
+	send := func(key string) bool {
+		select {
+		case keys <- key:
+			return true
+		case <-done:
+			return false
+		}
+	}
+	buf := make([]byte, 16)
+	for {
+		n, err := t.tty.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		in := string(buf[:n])
+		ok := true
+		switch {
+		case in == "\x1b[A" || in == "\x1bOA":
+			ok = send(keyUp)
+		case in == "\x1b[B" || in == "\x1bOB":
+			ok = send(keyDown)
+		case in == "\r" || in == "\n":
+			ok = send(keyEnter)
+		case in == "\x1b":
+			ok = send(keyEsc)
+		case in == "\x03":
+			ok = send(keyQuit)
+		case strings.HasPrefix(in, "\x1b"):
+			// Other escape sequences are ignored.
+		default:
+			for _, r := range in {
+				if ok = send(string(r)); !ok {
+					break
+				}
+			}
+		}
+		if !ok {
+			return
+		}
+	}
+}
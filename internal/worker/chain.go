@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"maps"
+	"queueCtl/internal/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// outputTailBytes is how much of the parent's output a follow-up gets in
+// QUEUECTL_PARENT_OUTPUT.
+const outputTailBytes = 4096
+
+// followUps returns the job to enqueue after job reached its new state:
+// its on_success template once it completed, its on_failure template once
+// it is dead. The follow-up's ID defaults to model.FollowUpID, made unique
+// by the store when a job already has it, and it inherits the parent's
+// queue and trace context unless it names its own.
+func (w *Worker) followUps(job *model.Job) []*model.Job {
+	var template *model.Job
+	switch job.State {
+	case model.StateCompleted:
+		template = job.OnSuccess
+	case model.StateDead:
+		template = job.OnFailure
+	}
+	if template == nil {
+		return nil
+	}
+
+	f := *template
+	if f.ID == "" {
+		f.ID = model.FollowUpID(job.ID, job.State)
+	}
+	if f.Queue == "" {
+		f.Queue = job.Queue
+	}
+	if err := f.Prepare(time.Now(), w.Config.Get().MaxRetries); err != nil {
+		// Templates are validated at enqueue; this only happens to jobs
+		// enqueued by something that skipped Prepare.
+		w.jobLog(job).Error("invalid follow-up, not enqueued", "err", err)
+		return nil
+	}
+	f.ParentID = job.ID
+	if f.TraceParent == "" {
+		f.TraceParent = job.TraceParent
+	}
+	f.Env = maps.Clone(template.Env)
+	if f.Env == nil {
+		f.Env = make(map[string]string)
+	}
+	f.Env["QUEUECTL_PARENT_ID"] = job.ID
+	f.Env["QUEUECTL_PARENT_STATE"] = job.State
+	f.Env["QUEUECTL_PARENT_EXIT_CODE"] = strconv.Itoa(job.ExitCode)
+	f.Env["QUEUECTL_PARENT_FAILURE_REASON"] = job.FailureReason
+	// A NUL byte cannot be passed in the environment; exec refuses it.
+	f.Env["QUEUECTL_PARENT_OUTPUT"] = strings.ReplaceAll(outputTail(job.Output), "\x00", "")
+	return []*model.Job{&f}
+}
+
+// outputTail returns the end of out, at most outputTailBytes long, starting
+// at a line boundary when one is available.
+func outputTail(out string) string {
+	if len(out) <= outputTailBytes {
+		return out
+	}
+	tail := out[len(out)-outputTailBytes:]
+	for i := 0; i < len(tail); i++ {
+		if tail[i] == '\n' {
+			return tail[i+1:]
+		}
+	}
+	return tail
+}
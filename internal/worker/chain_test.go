@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"context"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"strings"
+	"testing"
+)
+
+func TestFollowUps(t *testing.T) {
+	w := newTestWorker(t)
+	enqueue(t, w, &model.Job{ID: "ok", Queue: "reports", Command: "echo built",
+		TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		OnSuccess:   &model.Job{Command: "echo \"$QUEUECTL_PARENT_OUTPUT\"", Env: map[string]string{"KEEP": "1"}},
+		OnFailure:   &model.Job{Command: "echo never"},
+	})
+	enqueue(t, w, &model.Job{ID: "bad", Command: "echo broke; exit 4", MaxRetries: 1,
+		OnFailure: &model.Job{ID: "alert", Queue: "ops", Command: "echo alert"},
+	})
+
+	run(t, w, context.Background(), "ok")
+	f, err := w.Store.GetJob("ok.on_success")
+	if err != nil {
+		t.Fatalf("on_success not enqueued: %v", err)
+	}
+	want := map[string]string{
+		"KEEP":                           "1",
+		"QUEUECTL_PARENT_ID":             "ok",
+		"QUEUECTL_PARENT_STATE":          model.StateCompleted,
+		"QUEUECTL_PARENT_EXIT_CODE":      "0",
+		"QUEUECTL_PARENT_FAILURE_REASON": "",
+		"QUEUECTL_PARENT_OUTPUT":         "built\n",
+	}
+	for k, v := range want {
+		if f.Env[k] != v {
+			t.Errorf("follow-up env %s = %q, want %q", k, f.Env[k], v)
+		}
+	}
+	if f.State != model.StatePending || f.ParentID != "ok" || f.Queue != "reports" || f.TraceParent == "" {
+		t.Errorf("follow-up = %+v, want it pending in its parent's queue and trace", f)
+	}
+	if _, err := w.Store.GetJob("ok.on_failure"); err == nil {
+		t.Error("on_failure enqueued for a job that completed")
+	}
+
+	job := run(t, w, context.Background(), "bad")
+	if job.State != model.StateDead {
+		t.Fatalf("bad = %s, want dead", job.State)
+	}
+	alert, err := w.Store.GetJob("alert")
+	if err != nil {
+		t.Fatalf("on_failure not enqueued under its own ID: %v", err)
+	}
+	if alert.Queue != "ops" || alert.Env["QUEUECTL_PARENT_EXIT_CODE"] != "4" ||
+		alert.Env["QUEUECTL_PARENT_FAILURE_REASON"] != model.FailureExitCode {
+		t.Errorf("alert = %+v", alert)
+	}
+
+	// The follow-up runs with its parent's details.
+	if f := run(t, w, context.Background(), "ok.on_success"); f.State != model.StateCompleted || f.Output != "built\n\n" {
+		t.Errorf("follow-up ran as %s with output %q", f.State, f.Output)
+	}
+}
+
+func TestFollowUpAfterRetryFromDLQ(t *testing.T) {
+	w := newTestWorker(t)
+	enqueue(t, w, &model.Job{ID: "flaky", Command: "exit 1", MaxRetries: 1, OnFailure: &model.Job{Command: "true"}})
+	for i := range 3 {
+		if i > 0 {
+			if err := w.Store.RetryDeadJob("flaky"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if job := run(t, w, context.Background(), "flaky"); job.State != model.StateDead {
+			t.Fatalf("flaky = %s", job.State)
+		}
+	}
+	// Each trip to the DLQ gets its own follow-up.
+	for _, id := range []string{"flaky.on_failure", "flaky.on_failure.2", "flaky.on_failure.3"} {
+		if f, err := w.Store.GetJob(id); err != nil || f.ParentID != "flaky" {
+			t.Errorf("%s = %+v, %v", id, f, err)
+		}
+	}
+}
+
+func TestFollowUpWithTakenID(t *testing.T) {
+	w := newTestWorker(t)
+	enqueue(t, w, &model.Job{ID: "taken", Command: "echo first"})
+	if _, err := w.Store.Db.Exec(`update jobs set state = ?`, model.StateCompleted); err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, w, &model.Job{ID: "parent", Command: "true", OnSuccess: &model.Job{ID: "taken", Command: "echo second"}})
+
+	if job := run(t, w, context.Background(), "parent"); job.State != model.StateCompleted {
+		t.Fatalf("parent = %s", job.State)
+	}
+	if f, _ := w.Store.GetJob("taken"); f.Command != "echo first" {
+		t.Errorf("taken job replaced by %q", f.Command)
+	}
+	events, err := w.Store.ListEvents(storage.EventFilter{JobID: "parent", Types: []string{model.EventFollowUpSkipped}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].FollowUpID != "taken" {
+		t.Errorf("follow_up_skipped events = %+v", events)
+	}
+}
+
+func TestOutputTail(t *testing.T) {
+	if got := outputTail("short\n"); got != "short\n" {
+		t.Errorf("outputTail of short output = %q", got)
+	}
+	long := strings.Repeat("x", 100) + "\n" + strings.Repeat("line\n", outputTailBytes/5)
+	got := outputTail(long)
+	if len(got) > outputTailBytes || !strings.HasPrefix(got, "line\n") || !strings.HasSuffix(long, got) {
+		t.Errorf("outputTail kept %d bytes starting %q", len(got), got[:min(len(got), 10)])
+	}
+	noBreaks := strings.Repeat("y", outputTailBytes+10)
+	if got := outputTail(noBreaks); len(got) != outputTailBytes {
+		t.Errorf("outputTail without line breaks kept %d bytes", len(got))
+	}
+}
@@ -0,0 +1,45 @@
+package worker
+
+import (
+	"context"
+	"log/slog"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/notify"
+	"time"
+)
+
+// ExpiryInterval is how often a worker pool settles jobs that expired or
+// passed their deadline while waiting. They cannot be claimed in between,
+// so this only decides how soon they show up as expired or dead.
+const ExpiryInterval = 5 * time.Second
+
+// RunExpiry settles expired and overdue jobs every ExpiryInterval until ctx
+// is canceled. Jobs abandoned at their deadline enqueue their on_failure
+// follow-up like any other dead job, and notifier hears of every job
+// settled.
+func RunExpiry(ctx context.Context, store *storage.Store, cfg *config.Live, notifier *notify.Dispatcher) {
+	w := &Worker{Store: store, Config: cfg, Notifier: notifier, log: slog.With("component", "expiry")}
+	ticker := time.NewTicker(ExpiryInterval)
+	defer ticker.Stop()
+	for {
+		w.expire()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) expire() {
+	jobs, err := w.Store.ExpireJobs(time.Now(), w.followUps)
+	if err != nil {
+		w.log.Error("error settling expired jobs", "err", err)
+		return
+	}
+	for _, job := range jobs {
+		w.jobLog(job).Info("job settled", "state", job.State, "reason", job.FailureReason)
+		w.Notifier.Notify(job)
+	}
+}
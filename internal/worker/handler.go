@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"queueCtl/internal/model"
+	"queueCtl/internal/tracing"
+	"sort"
+	"time"
+)
+
+// HandlerFunc runs a job in-process. Returning an error fails the attempt,
+// which is then retried with backoff and moved to the DLQ like a failing
+// shell command. ctx is canceled when the job times out, is canceled or the
+// pool stops. A handler that does not return soon after is abandoned: the
+// attempt is settled without it, and it keeps running unobserved.
+type HandlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+// handlerStopGrace is how long a handler gets to return once its context
+// is canceled by a timeout or a cancel, before the worker gives up on it.
+// A pool shutting down waits shutdownGrace, as for shell jobs.
+const handlerStopGrace = 2 * time.Second
+
+// handlerPanic is the error a handler's panic is turned into.
+type handlerPanic struct{ value any }
+
+func (p handlerPanic) Error() string { return fmt.Sprintf("handler panicked: %v", p.value) }
+
+// runnableTypes lists the job types this worker can run: shell commands
+// plus every type in Handlers.
+func (w *Worker) runnableTypes() []string {
+	types := []string{model.TypeShell}
+	for t := range w.Handlers {
+		types = append(types, t)
+	}
+	sort.Strings(types[1:])
+	return types
+}
+
+type envKey struct{}
+
+// Env returns the environment variables of the job a handler is running,
+// including the QUEUECTL_PARENT_* variables of a follow-up job and, when
+// the job is traced, TRACEPARENT.
+func Env(ctx context.Context) map[string]string {
+	env, _ := ctx.Value(envKey{}).(map[string]string)
+	return env
+}
+
+// runHandler calls the job's registered handler. A panic in the handler
+// fails the attempt instead of crashing the pool. The handler runs in its
+// own goroutine so that a timeout, a cancel or a shutdown settles the
+// attempt even if the handler ignores its context.
+func (w *Worker) runHandler(ctx context.Context, job *model.Job) (res runResult) {
+	fn, ok := w.Handlers[job.Type]
+	if !ok {
+		err := fmt.Errorf("no handler registered for job type %q", job.Type)
+		return runResult{output: err.Error(), exitCode: 1, err: err, reason: model.FailureNoHandler}
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, w.jobTimeout(job))
+	defer cancel()
+
+	env := job.Env
+	if traceParent := tracing.TraceParentFromContext(ctx); traceParent != "" {
+		env = maps.Clone(job.Env)
+		if env == nil {
+			env = make(map[string]string)
+		}
+		env[tracing.EnvTraceParent] = traceParent
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- handlerPanic{r}
+			}
+		}()
+		done <- fn(context.WithValue(jobCtx, envKey{}, env), job.Payload)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-jobCtx.Done():
+		grace := handlerStopGrace
+		if ctx.Err() != nil && !errors.Is(context.Cause(ctx), errJobCanceled) {
+			grace = shutdownGrace
+		}
+		select {
+		case err = <-done:
+		case <-time.After(grace):
+			w.jobLog(job).Warn("handler did not return after its context was canceled, abandoning it", "grace", grace.String())
+			err = context.Cause(jobCtx)
+		}
+	}
+
+	var panicked handlerPanic
+	switch {
+	case errors.As(err, &panicked):
+		res = runResult{exitCode: 1, err: err, reason: model.FailureHandlerError}
+	case err == nil:
+		// The handler finished its work, whatever stopped it meanwhile.
+	case errors.Is(context.Cause(ctx), errJobCanceled):
+		res = runResult{exitCode: -1, err: errJobCanceled, reason: model.FailureCanceled}
+	case ctx.Err() != nil:
+		res = runResult{exitCode: -1, err: err, reason: model.FailureInterrupted}
+	case errors.Is(jobCtx.Err(), context.DeadlineExceeded):
+		res = runResult{exitCode: -1, err: context.DeadlineExceeded, reason: model.FailureTimeout}
+	case err != nil:
+		res = runResult{exitCode: 1, err: err, reason: model.FailureHandlerError}
+	}
+	if res.err != nil {
+		res.output = res.err.Error()
+	}
+	return res
+}
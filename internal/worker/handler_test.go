@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"queueCtl/internal/model"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestHandlerJobs(t *testing.T) {
+	w := newTestWorker(t)
+	var gotPayload string
+	var gotEnv map[string]string
+	w.Handlers = map[string]HandlerFunc{
+		"email": func(ctx context.Context, payload json.RawMessage) error {
+			gotPayload, gotEnv = string(payload), Env(ctx)
+			return nil
+		},
+		"flaky": func(ctx context.Context, payload json.RawMessage) error {
+			return errors.New("smtp unreachable")
+		},
+		"broken": func(ctx context.Context, payload json.RawMessage) error {
+			panic("nil map")
+		},
+		"stuck": func(ctx context.Context, payload json.RawMessage) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+	if got := w.runnableTypes(); !slices.Equal(got, []string{model.TypeShell, "broken", "email", "flaky", "stuck"}) {
+		t.Errorf("runnable types = %v", got)
+	}
+
+	enqueue(t, w, &model.Job{ID: "email", Type: "email", Payload: json.RawMessage(`{"to":"a@example.com"}`), Env: map[string]string{"LANG": "C"}})
+	if job := run(t, w, context.Background(), "email"); job.State != model.StateCompleted {
+		t.Errorf("email = %s, want completed", job.State)
+	}
+	if gotPayload != `{"to":"a@example.com"}` || gotEnv["LANG"] != "C" {
+		t.Errorf("handler got payload %s and env %v", gotPayload, gotEnv)
+	}
+
+	tests := []struct {
+		job    *model.Job
+		reason string
+		output string
+	}{
+		{&model.Job{ID: "flaky", Type: "flaky"}, model.FailureHandlerError, "smtp unreachable"},
+		{&model.Job{ID: "broken", Type: "broken"}, model.FailureHandlerError, "handler panicked: nil map"},
+		{&model.Job{ID: "stuck", Type: "stuck", Timeout: "100ms"}, model.FailureTimeout, context.DeadlineExceeded.Error()},
+	}
+	for _, tt := range tests {
+		enqueue(t, w, tt.job)
+		job := run(t, w, context.Background(), tt.job.ID)
+		if job.State != model.StateFailed || job.FailureReason != tt.reason || job.Output != tt.output {
+			t.Errorf("%s = %s, %s, %q, want failed with %s and %q", tt.job.ID, job.State, job.FailureReason, job.Output, tt.reason, tt.output)
+		}
+	}
+}
+
+func TestHandlerJobsWithoutHandlerWait(t *testing.T) {
+	w := newTestWorker(t)
+	enqueue(t, w, &model.Job{ID: "resize", Type: "image.resize"})
+	if job := run(t, w, context.Background(), "resize"); job.State != model.StatePending || job.Attempts != 0 {
+		t.Errorf("resize = %s after %d attempts, want it left for a worker with the handler", job.State, job.Attempts)
+	}
+
+	w.Handlers = map[string]HandlerFunc{"image.resize": func(ctx context.Context, payload json.RawMessage) error { return nil }}
+	if job := run(t, w, context.Background(), "resize"); job.State != model.StateCompleted {
+		t.Errorf("resize = %s, want completed once a handler is registered", job.State)
+	}
+}
+
+func TestHandlerInterruptedByShutdown(t *testing.T) {
+	w := newTestWorker(t)
+	w.Handlers = map[string]HandlerFunc{"wait": func(ctx context.Context, payload json.RawMessage) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	enqueue(t, w, &model.Job{ID: "wait", Type: "wait"})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	if job := run(t, w, ctx, "wait"); job.State != model.StatePending || job.FailureReason != model.FailureInterrupted {
+		t.Errorf("wait = %s, %s, want pending again", job.State, job.FailureReason)
+	}
+}
+
+func TestHandlerIgnoringItsContextTimesOut(t *testing.T) {
+	w := newTestWorker(t)
+	release := make(chan struct{})
+	defer close(release)
+	w.Handlers = map[string]HandlerFunc{"stuck": func(ctx context.Context, payload json.RawMessage) error {
+		<-release
+		return nil
+	}}
+	enqueue(t, w, &model.Job{ID: "stuck", Type: "stuck", Timeout: "1s"})
+	start := time.Now()
+	job := run(t, w, context.Background(), "stuck")
+	if took := time.Since(start); took > 1*time.Second+handlerStopGrace+2*time.Second {
+		t.Errorf("a handler ignoring its 1s timeout held the worker for %v", took)
+	}
+	if job.State != model.StateFailed || job.FailureReason != model.FailureTimeout {
+		t.Errorf("stuck = %s, %s, want failed by its timeout", job.State, job.FailureReason)
+	}
+}
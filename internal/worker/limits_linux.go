@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"queueCtl/internal/model"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// limitsSupported reports whether resource limits are enforced on this OS.
+const limitsSupported = true
+
+var (
+	nprocFlagOnce sync.Once
+	nprocFlag     string
+)
+
+// ulimitNprocFlag returns the flag the system shell uses for the process
+// count limit: bash and busybox use -u, dash uses -p.
+func ulimitNprocFlag() string {
+	nprocFlagOnce.Do(func() {
+		nprocFlag = "-p"
+		if err := exec.Command("sh", "-c", "ulimit -u").Run(); err == nil {
+			nprocFlag = "-u"
+		}
+	})
+	return nprocFlag
+}
+
+// shellCommand returns the command that runs a job's shell command. Limits
+// are set as rlimits on a wrapper shell, which then execs the command so
+// that it and all its children inherit them.
+func shellCommand(command string, limits model.ResourceLimits) *exec.Cmd {
+	var prefix strings.Builder
+	if limits.CPUSeconds > 0 {
+		fmt.Fprintf(&prefix, "ulimit -t %d && ", limits.CPUSeconds)
+	}
+	if limits.MemoryMB > 0 {
+		fmt.Fprintf(&prefix, "ulimit -v %d && ", limits.MemoryMB*1024)
+	}
+	if limits.OpenFiles > 0 {
+		fmt.Fprintf(&prefix, "ulimit -n %d && ", limits.OpenFiles)
+	}
+	if limits.MaxProcs > 0 {
+		fmt.Fprintf(&prefix, "ulimit %s %d && ", ulimitNprocFlag(), limits.MaxProcs)
+	}
+	if prefix.Len() == 0 {
+		return exec.Command("sh", "-c", command)
+	}
+	return exec.Command("sh", "-c", prefix.String()+`exec sh -c "$1"`, "sh", command)
+}
+
+// killedByLimit reports whether a finished process was stopped by one of
+// its rlimits. The kernel signals a process that exceeds its CPU or file
+// size limit. The address-space limit is not signaled: the allocation
+// fails, and the process either crashes, usually with SIGSEGV, or reports
+// it and exits, so with a memory limit a failed exit is also counted when
+// output shows an allocation failure. Otherwise only a process that was
+// itself killed by a signal counts: an exit code above 128 may be a
+// command's own choice, so it is not taken for a signal.
+func killedByLimit(state *os.ProcessState, output string, limits model.ResourceLimits) bool {
+	if state == nil {
+		return false
+	}
+	ws, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return false
+	}
+	if !ws.Signaled() {
+		return limits.MemoryMB > 0 && ws.ExitStatus() != 0 && allocationFailed(output)
+	}
+
+	switch ws.Signal() {
+	case syscall.SIGXCPU, syscall.SIGXFSZ:
+		return true
+	case syscall.SIGKILL:
+		// The CPU hard limit is enforced with SIGKILL.
+		return limits.CPUSeconds > 0
+	case syscall.SIGSEGV:
+		return limits.MemoryMB > 0
+	}
+	return false
+}
+
+// allocationFailures are what common runtimes print when memory cannot be
+// allocated, lowercased: strerror(ENOMEM) and bash, awk, perl and Go,
+// Python, C++, Rust and Node.
+var allocationFailures = []string{
+	"cannot allocate",
+	"out of memory",
+	"memoryerror",
+	"bad_alloc",
+	"memory allocation of",
+	"allocation failed",
+}
+
+// allocationFailed reports whether the end of a job's output shows that it
+// ran out of memory.
+func allocationFailed(output string) bool {
+	tail := strings.ToLower(output[max(0, len(output)-4096):])
+	for _, msg := range allocationFailures {
+		if strings.Contains(tail, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// jobCgroup is the cgroup v2 directory a single job attempt runs in.
+type jobCgroup struct {
+	path string
+	dir  *os.File
+}
+
+// newJobCgroup creates a cgroup named name under parent with the memory and
+// process limits written to it.
+func newJobCgroup(parent, name string, limits model.ResourceLimits) (*jobCgroup, error) {
+	if _, err := os.Stat(filepath.Join(parent, "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%s is not a cgroup v2 directory: %w", parent, err)
+	}
+
+	path := filepath.Join(parent, strings.ReplaceAll(name, string(filepath.Separator), "_"))
+	if err := os.Mkdir(path, 0755); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+
+	cg := &jobCgroup{path: path}
+	if limits.MemoryMB > 0 {
+		if err := cg.write("memory.max", strconv.Itoa(limits.MemoryMB*1024*1024)); err != nil {
+			cg.remove()
+			return nil, err
+		}
+	}
+	if limits.MaxProcs > 0 {
+		if err := cg.write("pids.max", strconv.Itoa(limits.MaxProcs)); err != nil {
+			cg.remove()
+			return nil, err
+		}
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		cg.remove()
+		return nil, err
+	}
+	cg.dir = dir
+	return cg, nil
+}
+
+func (c *jobCgroup) write(file, value string) error {
+	return os.WriteFile(filepath.Join(c.path, file), []byte(value), 0644)
+}
+
+// attach makes cmd start directly inside the cgroup.
+func (c *jobCgroup) attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.dir.Fd())
+}
+
+// limitHit reports whether the kernel OOM-killed a process in the cgroup or
+// refused a fork because of pids.max.
+func (c *jobCgroup) limitHit() bool {
+	return c.eventCount("memory.events", "oom_kill") > 0 || c.eventCount("pids.events", "max") > 0
+}
+
+func (c *jobCgroup) eventCount(file, key string) int {
+	data, err := os.ReadFile(filepath.Join(c.path, file))
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			n, _ := strconv.Atoi(fields[1])
+			return n
+		}
+	}
+	return 0
+}
+
+// remove deletes the cgroup. It fails if any process is still inside it.
+func (c *jobCgroup) remove() error {
+	if c.dir != nil {
+		c.dir.Close()
+	}
+	return os.Remove(c.path)
+}
@@ -0,0 +1,112 @@
+package worker
+
+import (
+	"errors"
+	"os/exec"
+	"queueCtl/internal/model"
+	"strings"
+	"testing"
+)
+
+func TestShellCommandSetsLimits(t *testing.T) {
+	limits := model.ResourceLimits{CPUSeconds: 7, MemoryMB: 64, OpenFiles: 32, MaxProcs: 500}
+	out, err := shellCommand(`ulimit -t; ulimit -v; ulimit -n; ulimit `+ulimitNprocFlag(), limits).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(out)); strings.Join(got, " ") != "7 65536 32 500" {
+		t.Errorf("limits seen by the command = %q", got)
+	}
+}
+
+func TestShellCommandKeepsCommandIntact(t *testing.T) {
+	command := `printf '%s|' "it's" "$HOME" "a  b"; echo`
+	for _, limits := range []model.ResourceLimits{{}, {OpenFiles: 64}} {
+		want, err := exec.Command("sh", "-c", command).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := shellCommand(command, limits).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != string(want) {
+			t.Errorf("limits %+v: output %q, want %q", limits, got, want)
+		}
+	}
+}
+
+func TestKilledByLimit(t *testing.T) {
+	cpu := model.ResourceLimits{CPUSeconds: 10}
+	memory := model.ResourceLimits{MemoryMB: 64}
+	tests := []struct {
+		command string
+		limits  model.ResourceLimits
+		want    bool
+	}{
+		{"kill -XCPU $$", model.ResourceLimits{}, true},
+		{"kill -XFSZ $$", model.ResourceLimits{}, true},
+		{"kill -KILL $$", cpu, true},
+		{"kill -KILL $$", memory, false},
+		{"kill -TERM $$", cpu, false},
+		{"kill -SEGV $$", memory, true},
+		{"kill -SEGV $$", cpu, false},
+		// A command exiting as if killed was not killed.
+		{"exit 152", cpu, false},
+		{"exit 137", cpu, false},
+		{"true", cpu, false},
+		// Under a memory limit, a failed exit counts once the output
+		// shows that an allocation failed.
+		{"echo 'fatal error: runtime: out of memory' >&2; exit 2", memory, true},
+		{"echo MemoryError; exit 1", memory, true},
+		{"echo MemoryError; exit 1", cpu, false},
+		{"echo MemoryError", memory, false},
+		{"echo 'no such file'; exit 1", memory, false},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command("sh", "-c", tt.command)
+		out, err := cmd.CombinedOutput()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			t.Fatal(err)
+		}
+		if got := killedByLimit(cmd.ProcessState, string(out), tt.limits); got != tt.want {
+			t.Errorf("%s with %+v: killedByLimit = %v, want %v", tt.command, tt.limits, got, tt.want)
+		}
+	}
+	if killedByLimit(nil, "", cpu) {
+		t.Error("a process that never started was killed by a limit")
+	}
+}
+
+func TestCPULimitStopsBusyLoop(t *testing.T) {
+	if testing.Short() {
+		t.Skip("uses a second of CPU")
+	}
+	cmd := shellCommand("while :; do :; done", model.ResourceLimits{CPUSeconds: 1})
+	if err := cmd.Run(); err == nil {
+		t.Fatal("busy loop exited cleanly")
+	}
+	if !killedByLimit(cmd.ProcessState, "", model.ResourceLimits{CPUSeconds: 1}) {
+		t.Errorf("busy loop ended with %v, not by its CPU limit", cmd.ProcessState)
+	}
+}
+
+func TestMemoryLimitStopsGrowingProcess(t *testing.T) {
+	limits := model.ResourceLimits{MemoryMB: 64}
+	for _, command := range []string{
+		// awk reports the failed allocation and exits.
+		`awk 'BEGIN { s = "x"; while (1) s = s s }'`,
+		// sh crashes.
+		`x=$(head -c 200000000 /dev/zero | tr '\0' x); echo ${#x}`,
+	} {
+		cmd := shellCommand(command, limits)
+		out, err := cmd.CombinedOutput()
+		if err == nil {
+			t.Fatalf("%s ran within its memory limit", command)
+		}
+		if !killedByLimit(cmd.ProcessState, string(out), limits) {
+			t.Errorf("%s ended with %v and output %q, not by its memory limit", command, cmd.ProcessState, out)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+//go:build !linux
+
+package worker
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"queueCtl/internal/model"
+)
+
+// limitsSupported reports whether resource limits are enforced on this OS.
+const limitsSupported = false
+
+func shellCommand(command string, limits model.ResourceLimits) *exec.Cmd {
+	return exec.Command("sh", "-c", command)
+}
+
+func killedByLimit(state *os.ProcessState, output string, limits model.ResourceLimits) bool {
+	return false
+}
+
+type jobCgroup struct{}
+
+func newJobCgroup(parent, name string, limits model.ResourceLimits) (*jobCgroup, error) {
+	return nil, errors.New("cgroups are only supported on Linux")
+}
+
+func (c *jobCgroup) attach(cmd *exec.Cmd) {}
+
+func (c *jobCgroup) limitHit() bool { return false }
+
+func (c *jobCgroup) remove() error { return nil }
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"context"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/health"
+	"queueCtl/internal/notify"
+	"queueCtl/internal/tracing"
+	"sync"
+)
+
+// Pool is a set of workers sharing a store, plus the loop that settles
+// expired jobs.
+type Pool struct {
+	Count  int
+	Store  *storage.Store
+	Config *config.Live
+	// Notifier, Tracer, Health and Handlers are handed to every worker;
+	// see Worker.
+	Notifier *notify.Dispatcher
+	Tracer   *tracing.Tracer
+	Health   *health.Monitor
+	Handlers map[string]HandlerFunc
+}
+
+// Run starts the workers and blocks until ctx is canceled and every one of
+// them has finished its job, interrupting it if need be.
+func (p *Pool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 1; i <= p.Count; i++ {
+		wg.Add(1)
+		w := New(i, p.Store, p.Config)
+		w.Notifier = p.Notifier
+		w.Tracer = p.Tracer
+		w.Health = p.Health
+		w.Handlers = p.Handlers
+		go w.Run(ctx, &wg)
+	}
+
+	// Jobs that expire or pass their deadline while waiting are settled in
+	// the background too.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		RunExpiry(ctx, p.Store, p.Config, p.Notifier)
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"queueCtl/internal/model"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// alive reports whether the process with the given PID still exists, and
+// is not a zombie, a second from now. A process that was just killed can
+// take a moment to go.
+func alive(t *testing.T, pidText string) bool {
+	t.Helper()
+	pid, err := strconv.Atoi(strings.TrimSpace(pidText))
+	if err != nil {
+		t.Fatalf("no PID in %q", pidText)
+	}
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(20 * time.Millisecond) {
+		stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+		if err != nil {
+			return false
+		}
+		s := string(stat)
+		fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+		if len(fields) == 0 || fields[0] == "Z" {
+			return false
+		}
+		if time.Now().After(deadline) {
+			return true
+		}
+	}
+}
+
+func TestTimeoutKillsChildProcesses(t *testing.T) {
+	w := newTestWorker(t)
+	enqueue(t, w, &model.Job{ID: "parent", Command: "sleep 30 & echo $!; wait", Timeout: "1s"})
+	job := run(t, w, context.Background(), "parent")
+	if job.FailureReason != model.FailureTimeout {
+		t.Fatalf("parent = %s, %s, want failed by its timeout", job.State, job.FailureReason)
+	}
+	if alive(t, job.Output) {
+		t.Error("the job's child outlived its timeout")
+	}
+}
+
+func TestLeftoverProcessesAreKilled(t *testing.T) {
+	w := newTestWorker(t)
+	enqueue(t, w, &model.Job{ID: "leaky", Command: "sleep 30 >/dev/null 2>&1 & echo $!"})
+	job := run(t, w, context.Background(), "leaky")
+	if job.State != model.StateCompleted {
+		t.Fatalf("leaky = %s, want completed", job.State)
+	}
+	if alive(t, job.Output) {
+		t.Error("the job's background child was left running")
+	}
+	attempts, err := w.Store.AttemptsFor([]string{"leaky"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := attempts["leaky"]; len(a) != 1 || len(a[0].LeftoverProcesses) != 1 ||
+		!strings.HasSuffix(a[0].LeftoverProcesses[0], " sleep") {
+		t.Errorf("attempts = %+v, want the sleep reported as left over", a)
+	}
+}
+
+func TestShutdownInterruptsJob(t *testing.T) {
+	w := newTestWorker(t)
+	enqueue(t, w, &model.Job{ID: "long", Command: "sleep 30"})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(500*time.Millisecond, cancel)
+	start := time.Now()
+	job := run(t, w, ctx, "long")
+	if took := time.Since(start); took > shutdownGrace {
+		t.Errorf("interrupted job ran for %v", took)
+	}
+	if job.State != model.StatePending || job.Attempts != 0 || job.FailureReason != model.FailureInterrupted {
+		t.Errorf("long = %s, attempt %d, %s, want pending again with the attempt not counted", job.State, job.Attempts, job.FailureReason)
+	}
+}
+
+func TestJobFinishingDuringShutdownKeepsResult(t *testing.T) {
+	w := newTestWorker(t)
+	enqueue(t, w, &model.Job{ID: "tidy", Command: "trap 'echo cleaned up; exit 0' TERM; sleep 30 & wait"})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(500*time.Millisecond, cancel)
+	job := run(t, w, ctx, "tidy")
+	if job.State != model.StateCompleted || job.Output != "cleaned up\n" || job.FailureReason != "" {
+		t.Errorf("tidy = %s, %q, %q, want completed", job.State, job.Output, job.FailureReason)
+	}
+}
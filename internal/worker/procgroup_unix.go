@@ -0,0 +1,79 @@
+//go:build unix
+
+package worker
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// startsOwnGroup puts the command in a new session, so the shell and
+// everything it spawns share a process group whose ID is the shell's PID.
+func startsOwnGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+}
+
+// terminateGroup asks every process in the group to exit.
+func terminateGroup(pgid int) error {
+	return ignoreGone(syscall.Kill(-pgid, syscall.SIGTERM))
+}
+
+// killGroup kills every process in the group.
+func killGroup(pgid int) error {
+	return ignoreGone(syscall.Kill(-pgid, syscall.SIGKILL))
+}
+
+func ignoreGone(err error) error {
+	if err == syscall.ESRCH {
+		return nil
+	}
+	return err
+}
+
+// groupMembers lists the processes still in the group as "pid command".
+// Where /proc is unavailable it can only tell whether the group is empty.
+func groupMembers(pgid int) []string {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		if syscall.Kill(-pgid, 0) == nil {
+			return []string{fmt.Sprintf("process group %d (members unknown)", pgid)}
+		}
+		return nil
+	}
+
+	var members []string
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(filepath.Join("/proc", e.Name(), "stat"))
+		if err != nil {
+			continue
+		}
+		// The command name is in parentheses and may contain spaces, so the
+		// remaining fields are counted from the last ')'.
+		s := string(stat)
+		open, end := strings.IndexByte(s, '('), strings.LastIndexByte(s, ')')
+		if open < 0 || end < open {
+			continue
+		}
+		fields := strings.Fields(s[end+1:])
+		// fields: state, ppid, pgrp, ...
+		if len(fields) < 3 || fields[0] == "Z" {
+			continue
+		}
+		if fields[2] == strconv.Itoa(pgid) {
+			members = append(members, fmt.Sprintf("%d %s", pid, s[open+1:end]))
+		}
+	}
+	return members
+}
@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"os"
+	"os/exec"
+)
+
+// Windows has no process groups that can be signalled; only the shell
+// itself is stopped.
+
+func startsOwnGroup(cmd *exec.Cmd) {}
+
+func terminateGroup(pid int) error {
+	return killGroup(pid)
+}
+
+func killGroup(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return nil
+	}
+	return p.Kill()
+}
+
+func groupMembers(pgid int) []string { return nil }
@@ -0,0 +1,276 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"queueCtl/internal/config"
+	"queueCtl/internal/model"
+	"queueCtl/internal/tracing"
+	"sync"
+	"time"
+)
+
+// shutdownGrace is how long a running job gets to exit after SIGTERM when
+// the pool shuts down, before its process group is killed.
+const shutdownGrace = 10 * time.Second
+
+// outputWaitDelay bounds how long output is still read after the shell has
+// exited, in case a background child keeps stdout open.
+const outputWaitDelay = 2 * time.Second
+
+// outputSaveInterval is how often the output of a running shell job is
+// saved, so that it can be followed while the job runs.
+const outputSaveInterval = 2 * time.Second
+
+// liveOutputBytes is how much of a running job's output is saved; the
+// whole output is saved once it finishes.
+const liveOutputBytes = 64 << 10
+
+// errJobCanceled is the cause attached to a job's context when the job is
+// canceled while running.
+var errJobCanceled = errors.New("job canceled")
+
+// cancelCheckInterval is how often a running job's state is checked for
+// cancellation.
+const cancelCheckInterval = 1 * time.Second
+
+// leaseRenewInterval is how often the lease of a running job is renewed,
+// well within storage.LeaseTimeout.
+const leaseRenewInterval = time.Minute
+
+// jobTimeout returns how long one attempt of job may run: the job's own
+// timeout, or else the configured job_timeout.
+func (w *Worker) jobTimeout(job *model.Job) time.Duration {
+	if d, err := time.ParseDuration(job.Timeout); err == nil && d > 0 {
+		return d
+	}
+	if d, err := config.ParseDuration(w.Config.Get().JobTimeout); err == nil && d > 0 {
+		return d
+	}
+	return defaultJobTimeout
+}
+
+// defaultJobTimeout applies when the configured job_timeout is missing or
+// invalid.
+const defaultJobTimeout = 5 * time.Minute
+
+// watchJob renews the lease of the running job and cancels ctx with
+// errJobCanceled once the job is marked canceled in the store. It returns
+// when ctx is done.
+func (w *Worker) watchJob(ctx context.Context, jobID string, cancel context.CancelCauseFunc) {
+	ticker := time.NewTicker(cancelCheckInterval)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(renewed) >= leaseRenewInterval {
+				if err := w.Store.RenewLease(jobID, w.name); err != nil {
+					w.log.Warn("could not renew the job's lease", "job_id", jobID, "err", err)
+				} else {
+					renewed = time.Now()
+				}
+			}
+			state, err := w.Store.GetJobState(jobID)
+			if err != nil {
+				continue
+			}
+			if state == model.StateCanceled {
+				cancel(errJobCanceled)
+				return
+			}
+		}
+	}
+}
+
+// outputBuffer collects a command's output while it is being read.
+type outputBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *outputBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// tail returns the last n bytes written and how many were written in all.
+func (b *outputBuffer) tail(n int) (string, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := b.buf.Bytes()
+	return string(out[max(len(out)-n, 0):]), len(out)
+}
+
+// saveOutput saves the end of out to the job every outputSaveInterval,
+// when it has grown, until done is closed.
+func (w *Worker) saveOutput(job *model.Job, out *outputBuffer, done <-chan struct{}) {
+	ticker := time.NewTicker(outputSaveInterval)
+	defer ticker.Stop()
+	saved := 0
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			tail, written := out.tail(liveOutputBytes)
+			if written == saved {
+				continue
+			}
+			if err := w.Store.SaveOutput(job.ID, w.name, tail); err != nil {
+				w.jobLog(job).Debug("could not save the job's output so far", "err", err)
+				continue
+			}
+			saved = written
+		}
+	}
+}
+
+// runResult is the outcome of running a job's command once.
+type runResult struct {
+	output   string
+	exitCode int
+	err      error
+	// reason is the failure reason, set when err is not nil or the run was
+	// interrupted.
+	reason    string
+	leftovers []string
+}
+
+// runShell runs the job's command in its own process group. The whole
+// group is killed when the job exceeds its timeout or is canceled, and
+// terminated when the pool shuts down. Processes still in the group after the shell exits are
+// reported as leftovers and killed. The trace context in ctx, if any, is
+// passed to the command in TRACEPARENT.
+func (w *Worker) runShell(ctx context.Context, job *model.Job) runResult {
+	// We use "sh -c" to allow for complex commands
+	log := w.jobLog(job)
+	cfg := w.Config.Get()
+	limits := job.Limits.WithDefaults(cfg.DefaultLimits)
+	if !limits.IsZero() && !limitsSupported {
+		log.Warn("resource limits are not supported on this OS, running the job without them")
+	}
+	cmd := shellCommand(job.Command, limits)
+	startsOwnGroup(cmd)
+	traceParent := tracing.TraceParentFromContext(ctx)
+	if len(job.Env) > 0 || traceParent != "" {
+		cmd.Env = os.Environ()
+		for k, v := range job.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		if traceParent != "" {
+			cmd.Env = append(cmd.Env, tracing.EnvTraceParent+"="+traceParent)
+		}
+	}
+
+	var cgroup *jobCgroup
+	if cfg.CgroupParent != "" {
+		cg, err := newJobCgroup(cfg.CgroupParent, fmt.Sprintf("queuectl-%s-%d", job.ID, job.Attempts), limits)
+		if err != nil {
+			log.Warn("cgroup unavailable, using rlimits only", "err", err)
+		} else {
+			cgroup = cg
+			cgroup.attach(cmd)
+		}
+	}
+
+	var out outputBuffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.WaitDelay = outputWaitDelay
+
+	if err := cmd.Start(); err != nil {
+		if cgroup != nil {
+			cgroup.remove()
+		}
+		return runResult{exitCode: -1, err: err, reason: model.FailureExitCode}
+	}
+	pgid := cmd.Process.Pid
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	saveDone := make(chan struct{})
+	defer close(saveDone)
+	go w.saveOutput(job, &out, saveDone)
+
+	limit := w.jobTimeout(job)
+	timeout := time.NewTimer(limit)
+	defer timeout.Stop()
+
+	var stopReason string
+	var err error
+	select {
+	case err = <-done:
+	case <-timeout.C:
+		stopReason = model.FailureTimeout
+		log.Warn("job exceeded its timeout, killing its process group", "timeout", limit.String())
+		killGroup(pgid)
+		err = <-done
+	case <-ctx.Done():
+		if errors.Is(context.Cause(ctx), errJobCanceled) {
+			stopReason = model.FailureCanceled
+			log.Info("job was canceled, killing its process group")
+			killGroup(pgid)
+			err = <-done
+			break
+		}
+		stopReason = model.FailureInterrupted
+		log.Info("stopping job")
+		terminateGroup(pgid)
+		select {
+		case err = <-done:
+		case <-time.After(shutdownGrace):
+			killGroup(pgid)
+			err = <-done
+		}
+		if err == nil {
+			// The command finished, successfully, before it could be
+			// stopped; its result stands.
+			stopReason = ""
+		}
+	}
+	// The shell finished but a background child kept the output open.
+	if errors.Is(err, exec.ErrWaitDelay) && cmd.ProcessState.Success() {
+		err = nil
+	}
+
+	res := runResult{
+		output:   out.String(),
+		exitCode: cmd.ProcessState.ExitCode(),
+		err:      err,
+		reason:   stopReason,
+	}
+	if res.leftovers = groupMembers(pgid); len(res.leftovers) > 0 {
+		log.Warn("job left processes behind, killing them", "count", len(res.leftovers))
+		killGroup(pgid)
+	}
+
+	limitHit := killedByLimit(cmd.ProcessState, res.output, limits)
+	if cgroup != nil {
+		limitHit = limitHit || cgroup.limitHit()
+		if err := cgroup.remove(); err != nil {
+			log.Warn("could not remove cgroup", "err", err)
+		}
+	}
+
+	if res.reason == "" && res.err != nil {
+		res.reason = model.FailureExitCode
+		if limitHit {
+			res.reason = model.FailureKilledByLimit
+		}
+	}
+	return res
+}
@@ -2,12 +2,17 @@ package worker
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"math"
-	"os/exec"
+	"os"
 	"queueCtl/internal/config"
 	"queueCtl/internal/model"
 	"queueCtl/internal/database"
+	"queueCtl/internal/health"
+	"queueCtl/internal/notify"
+	"queueCtl/internal/tracing"
 	"sync"
 	"time"
 )
@@ -15,22 +20,52 @@ import (
 type Worker struct {
 	ID     int
 	Store  *storage.Store
-	Config *config.Config
+	// Config is read afresh for every job, so a reload applies from the
+	// next claim on.
+	Config *config.Live
+	// Notifier is told about every state change the worker makes; nil
+	// disables notifications.
+	Notifier *notify.Dispatcher
+	// Tracer records a span for each job's claim, execution and state
+	// update; nil disables tracing.
+	Tracer *tracing.Tracer
+	// Health hears each time the worker polls for a job or starts one; nil
+	// disables health reporting.
+	Health *health.Monitor
+	// Handlers run jobs of other types than shell in-process, by type. The
+	// worker only claims shell jobs and jobs of these types.
+	Handlers map[string]HandlerFunc
+
+	// name identifies the worker across pools and hosts in job and attempt
+	// records.
+	name string
+	log  *slog.Logger
 }
 
-func New(id int, store *storage.Store, cfg *config.Config) *Worker {
+func New(id int, store *storage.Store, cfg *config.Live) *Worker {
+	host, _ := os.Hostname()
+	name := fmt.Sprintf("%s:%d:%d", host, os.Getpid(), id)
 	return &Worker{
 		ID:     id,
 		Store:  store,
 		Config: cfg,
+		name:   name,
+		log:    slog.With("worker", id, "worker_id", name),
 	}
 }
 
+// jobLog returns the worker's logger with the fields identifying job's
+// current attempt.
+func (w *Worker) jobLog(job *model.Job) *slog.Logger {
+	return w.log.With("job_id", job.ID, "queue", job.Queue, "attempt", job.Attempts)
+}
+
 // Run is the main loop for the worker.
 // It polls for jobs and executes them.
 func (w *Worker) Run(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
-	log.Printf("Worker %d: Starting", w.ID)
+	w.log.Info("worker starting")
+	w.Health.Tick(w.ID)
 
 	// Poll for jobs every second
 	ticker := time.NewTicker(1 * time.Second)
@@ -39,63 +74,172 @@ func (w *Worker) Run(ctx context.Context, wg *sync.WaitGroup) {
 	for {
 		select {
 		case <-ctx.Done(): // Context was canceled (shutdown signal)
-			log.Printf("Worker %d: Shutting down...", w.ID)
+			w.log.Info("worker shutting down")
 			return
 		case <-ticker.C: // Time to check for a job
-			w.processJob()
+			w.Health.Tick(w.ID)
+			w.processJob(ctx)
 		}
 	}
 }
 
-// processJob finds and executes a single job.
-func (w *Worker) processJob() {
+// processJob finds and executes a single job. Canceling ctx stops the job
+// that is running. A claimed job gets a queuectl.job span, in the trace it
+// was enqueued with, covering its claim, execution and state update.
+func (w *Worker) processJob(ctx context.Context) {
+	// Don't claim new work once shutdown has begun.
+	if ctx.Err() != nil {
+		return
+	}
+
 	// Step 1: Find and lock a job
-	job, err := w.Store.FindAndLock()
+	claimStart := time.Now()
+	job, err := w.Store.FindAndLock(w.name, w.runnableTypes())
 	if err != nil {
-		log.Printf("Worker %d: Error finding job: %v", w.ID, err)
+		w.log.Error("error finding job", "err", err)
 		return
 	}
 	if job == nil {
 		return // No job found, just loop again
 	}
 
-	log.Printf("Worker %d: Processing job %s (command: %s)", w.ID, job.ID, job.Command)
+	log := w.jobLog(job)
+	w.Health.Busy(w.ID, job.ID, w.jobTimeout(job))
+
+	parent, err := tracing.ParseTraceParent(job.TraceParent)
+	if err != nil && job.TraceParent != "" {
+		log.Warn("ignoring the job's trace context", "err", err)
+	}
+	span := w.Tracer.StartAt(parent, "queuectl.job", claimStart)
+	defer span.End()
+	span.SetAttr("job.id", job.ID)
+	span.SetAttr("job.queue", job.Queue)
+	span.SetAttr("job.type", job.Type)
+	span.SetAttr("job.attempt", job.Attempts)
+	span.SetAttr("worker.id", w.name)
+	claimSpan := w.Tracer.StartAt(span.Context(), "queuectl.claim", claimStart)
+	claimSpan.SetAttr("job.id", job.ID)
+	claimSpan.End()
+
+	// Step 2: Execute the job's command, or its Go handler
+	jobCtx, cancelJob := context.WithCancelCause(ctx)
+	go w.watchJob(jobCtx, job.ID, cancelJob)
+
+	execSpan := w.Tracer.Start(span.Context(), "queuectl.execute")
+	traceParent := execSpan.Context().TraceParent()
+	if traceParent == "" {
+		// Not tracing here: hand the producer's context on unchanged.
+		traceParent = job.TraceParent
+	}
+	if traceParent != "" {
+		jobCtx = tracing.WithTraceParent(jobCtx, traceParent)
+	}
+
+	started := time.Now()
+	var res runResult
+	if job.Type == model.TypeShell {
+		log.Info("processing job", "command", job.Command)
+		res = w.runShell(jobCtx, job)
+	} else {
+		log.Info("processing job", "type", job.Type)
+		res = w.runHandler(jobCtx, job)
+	}
+	cancelJob(nil)
+	execSpan.SetAttr("job.exit_code", res.exitCode)
+	if res.reason != "" {
+		execSpan.SetAttr("job.failure_reason", res.reason)
+	}
+	execSpan.SetError(res.err)
+	execSpan.End()
+	job.Output = res.output
+	log.Debug("job output", "output", res.output)
+
+	attempt := &model.Attempt{
+		JobID:             job.ID,
+		Attempt:           job.Attempts,
+		WorkerID:          w.name,
+		StartedAt:         started,
+		FinishedAt:        time.Now(),
+		ExitCode:          res.exitCode,
+		FailureReason:     res.reason,
+		Output:            res.output,
+		LeftoverProcesses: res.leftovers,
+	}
+	if err := w.Store.RecordAttempt(attempt, job.Queue); err != nil {
+		log.Error("error recording attempt", "err", err)
+	}
 
-	// Step 2: Execute the job's command
-	// We use "sh -c" to allow for complex commands
-	cmd := exec.Command("sh", "-c", job.Command)
-	output, execErr := cmd.CombinedOutput()
-	job.Output = string(output)
-	log.Printf("%s output: %s",job.ID,string(output))
 	// Step 3: Update the job based on the result
 	job.UpdatedAt = time.Now()
+	job.ExitCode = res.exitCode
+	job.FailureReason = res.reason
 
-	if execErr == nil {
+	if res.reason == model.FailureCanceled {
+		// --- CANCELED ---
+		job.State = model.StateCanceled
+		log.Info("job canceled")
+	} else if res.reason == model.FailureInterrupted {
+		// --- INTERRUPTED (pool shutting down) ---
+		// The attempt is not held against the job; it runs again on the
+		// next start.
+		job.State = model.StatePending
+		job.Attempts--
+		job.NextRunAt = time.Now()
+		log.Info("job interrupted, returned to the queue")
+	} else if res.err == nil {
 		// --- SUCCESS ---
 		job.State = model.StateCompleted
-		log.Printf("Worker %d:%s completed successfully", w.ID, job.ID)
+		log.Info("job completed", "duration_ms", time.Since(started).Milliseconds())
 	} else {
 		// --- FAILURE ---
-		log.Printf("Worker %d:%s failed: %v", w.ID, job.ID, execErr)
+		log.Warn("job failed", "reason", job.FailureReason, "exit_code", job.ExitCode, "err", res.err)
 		
+		// Calculate exponential backoff
+		delay := math.Pow(w.Config.Get().BackoffBase, float64(job.Attempts))
+		nextRun := time.Now().Add(time.Second * time.Duration(delay))
+
 		if job.Attempts >= job.MaxRetries {
 			// --- DEAD (Max retries reached) ---
 			job.State = model.StateDead
-			log.Printf("Worker %d: %s moved to Dead Letter Queue (DLQ)", w.ID, job.ID)
+			log.Warn("job moved to the dead letter queue")
+		} else if job.Deadline != nil && !nextRun.Before(*job.Deadline) {
+			// --- DEAD (The retry would start after the deadline) ---
+			job.State = model.StateDead
+			job.FailureReason = model.FailureDeadline
+			log.Warn("job cannot retry before its deadline, moved to the dead letter queue")
 		} else {
 			// --- FAILED (Retryable) ---
 			job.State = model.StateFailed
+			job.NextRunAt = nextRun
 			
-			// Calculate exponential backoff
-			delay := math.Pow(w.Config.BackoffBase, float64(job.Attempts))
-			job.NextRunAt = time.Now().Add(time.Second * time.Duration(delay))
-			
-			log.Printf("Worker %d: %s will retry in %.0fs", w.ID, job.ID, delay)
+			log.Info("job will retry", "delay_s", delay, "next_run_at", nextRun.Format(time.RFC3339))
 		}
 	}
 
-	// Step 4: Save the job's final state
-	if err := w.Store.UpdateJob(job); err != nil {
-		log.Printf("Worker %d: Error updating job %s: %v", w.ID, job.ID, err)
+	span.SetAttr("job.state", job.State)
+	span.SetError(res.err)
+
+	// Step 4: Save the job's final state, together with any follow-up job
+	followUps := w.followUps(job)
+	updateSpan := w.Tracer.Start(span.Context(), "queuectl.update")
+	updateSpan.SetAttr("job.state", job.State)
+	err = w.Store.UpdateJob(job, followUps...)
+	updateSpan.SetError(err)
+	updateSpan.End()
+	if err != nil {
+		if errors.Is(err, storage.ErrLeaseLost) {
+			log.Warn("job was taken over by another worker, result discarded", "err", err)
+			return
+		}
+		if errors.Is(err, storage.ErrInvalidState) {
+			log.Warn("job changed state while running, result discarded", "err", err)
+			return
+		}
+		log.Error("error updating job", "err", err)
+		return
+	}
+	// Whoever canceled the job has already sent its notification.
+	if job.State != model.StateCanceled {
+		w.Notifier.Notify(job)
 	}
 }
\ No newline at end of file
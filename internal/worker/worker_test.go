@@ -0,0 +1,132 @@
+package worker
+
+import (
+	"context"
+	"path/filepath"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"testing"
+	"time"
+)
+
+// newTestWorker returns a worker on a fresh database with the default
+// configuration.
+func newTestWorker(t *testing.T) *Worker {
+	t.Helper()
+	store, err := storage.NewStore(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Db.Close() })
+	return New(1, store, config.NewLive(config.NewConfig()))
+}
+
+// enqueue prepares and inserts job so that it is due.
+func enqueue(t *testing.T, w *Worker, job *model.Job) {
+	t.Helper()
+	if err := job.Prepare(time.Now().Add(-time.Second), 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Store.CreateJob(job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// run has w claim and run one job, and returns that job as saved.
+func run(t *testing.T, w *Worker, ctx context.Context, id string) *model.Job {
+	t.Helper()
+	w.processJob(ctx)
+	job, err := w.Store.GetJob(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestProcessJobOutcomes(t *testing.T) {
+	w := newTestWorker(t)
+	enqueue(t, w, &model.Job{ID: "ok", Command: "echo hello"})
+	enqueue(t, w, &model.Job{ID: "fails", Command: "echo oops; exit 3", MaxRetries: 2})
+
+	if job := run(t, w, context.Background(), "ok"); job.State != model.StateCompleted || job.Output != "hello\n" {
+		t.Errorf("ok = %s %q, want completed with its output", job.State, job.Output)
+	}
+
+	job := run(t, w, context.Background(), "fails")
+	if job.State != model.StateFailed || job.ExitCode != 3 || job.FailureReason != model.FailureExitCode || job.Attempts != 1 {
+		t.Errorf("after one attempt fails = %s, exit %d, %s, attempt %d", job.State, job.ExitCode, job.FailureReason, job.Attempts)
+	}
+	// backoff_base^attempts seconds.
+	if wait := time.Until(job.NextRunAt); wait < time.Second || wait > 2*time.Second {
+		t.Errorf("retry in %v, want 2s", wait)
+	}
+	if _, err := w.Store.Db.Exec(`update jobs set next_run_at = ?`, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if job := run(t, w, context.Background(), "fails"); job.State != model.StateDead || job.Attempts != 2 {
+		t.Errorf("after the last attempt fails = %s, attempt %d, want dead", job.State, job.Attempts)
+	}
+
+	attempts, err := w.Store.AttemptsFor([]string{"fails"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := attempts["fails"]; len(a) != 2 || a[1].Output != "oops\n" || a[1].WorkerID != w.name {
+		t.Errorf("attempts = %+v", a)
+	}
+}
+
+func TestProcessJobTimeout(t *testing.T) {
+	w := newTestWorker(t)
+	enqueue(t, w, &model.Job{ID: "slow", Command: "echo started; sleep 30", Timeout: "1s"})
+	start := time.Now()
+	job := run(t, w, context.Background(), "slow")
+	if took := time.Since(start); took > 10*time.Second {
+		t.Errorf("a job with a 1s timeout ran for %v", took)
+	}
+	if job.State != model.StateFailed || job.FailureReason != model.FailureTimeout || job.Output != "started\n" {
+		t.Errorf("slow = %s, %s, %q, want failed by its timeout", job.State, job.FailureReason, job.Output)
+	}
+}
+
+func TestJobTimeout(t *testing.T) {
+	w := newTestWorker(t)
+	if got := w.jobTimeout(&model.Job{Timeout: "2h"}); got != 2*time.Hour {
+		t.Errorf("job timeout = %v, want its own 2h", got)
+	}
+	if got := w.jobTimeout(&model.Job{}); got != 5*time.Minute {
+		t.Errorf("job timeout = %v, want the configured 5m", got)
+	}
+	cfg := config.NewConfig()
+	cfg.JobTimeout = "1d"
+	w.Config.Set(cfg)
+	if got := w.jobTimeout(&model.Job{}); got != 24*time.Hour {
+		t.Errorf("job timeout = %v, want the configured 1d", got)
+	}
+	cfg.JobTimeout = "soon"
+	if got := w.jobTimeout(&model.Job{}); got != defaultJobTimeout {
+		t.Errorf("job timeout = %v with an invalid job_timeout, want %v", got, defaultJobTimeout)
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	w := newTestWorker(t)
+	enqueue(t, w, &model.Job{ID: "long", Command: "sleep 30"})
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		w.Store.CancelJob("long")
+	}()
+	start := time.Now()
+	job := run(t, w, context.Background(), "long")
+	if took := time.Since(start); took > 10*time.Second {
+		t.Errorf("canceled job ran for %v", took)
+	}
+	if job.State != model.StateCanceled {
+		t.Errorf("long = %s, want canceled", job.State)
+	}
+	attempts, _ := w.Store.AttemptsFor([]string{"long"})
+	if a := attempts["long"]; len(a) != 1 || a[0].FailureReason != model.FailureCanceled {
+		t.Errorf("attempts = %+v", a)
+	}
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"queueCtl/cmd"
@@ -12,18 +12,21 @@ import (
 func main() {
 	cfg, err := config.LoadConfig()	
 	if err != nil {
-		log.Fatal("Failed to load config:", err)
+		slog.Error("failed to load config", "err", err)
+		os.Exit(1)
 	}
 
 	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
-		log.Fatal("Failed to create data directory:", err)
+		slog.Error("failed to create data directory", "err", err)
+		os.Exit(1)
 	}
 
 	dbPath := filepath.Join(cfg.DataDir, "queue.db")
 
 	store,err := storage.NewStore(dbPath)
 	if err!=nil{
-		log.Fatal("Failed to initialize storage:", err)
+		slog.Error("failed to initialize storage", "err", err)
+		os.Exit(1)
 	}
 
 	cmd.Execute(store,cfg)
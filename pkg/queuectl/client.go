@@ -0,0 +1,192 @@
+// Package queuectl lets Go programs enqueue and inspect jobs in a queuectl
+// queue without shelling out to the CLI, and run jobs as Go functions.
+//
+// # Handlers
+//
+// Register a HandlerFunc for a job type, then start a pool with
+// Client.RunWorkers. Jobs of that type get their JSON payload passed to the
+// handler. The CLI's 'worker start' runs shell jobs only, so jobs of a
+// handler type wait until a program that registered it runs workers;
+// 'queuectl status' lists them while they wait.
+//
+// # Concurrency
+//
+// A Client is safe for concurrent use by multiple goroutines. Any number of
+// clients, CLI invocations and worker pools may share one queue database;
+// SQLite in WAL mode serializes their writes, and each call waits up to five
+// seconds for a competing writer before failing.
+//
+// Every method is a single atomic change: Enqueue either stores the whole
+// job or nothing, and RetryDead and Cancel only apply when the job is in a
+// state that allows them at the moment of the call. Cancel stops a pending
+// or failed job immediately; a processing job is killed by its worker within
+// about a second and whatever it was doing is discarded. If the worker has
+// already recorded the job's result, Cancel returns ErrInvalidState.
+//
+// # Auditing
+//
+// Enqueue, Cancel and RetryDead are recorded in the queue's audit log with
+// the source "api", the OS user and host of the calling process, and their
+// outcome; 'queuectl audit list' shows them.
+package queuectl
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"queueCtl/internal/audit"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"queueCtl/internal/model"
+	"queueCtl/internal/notify"
+	"time"
+)
+
+// Job is a unit of work in the queue.
+type Job = model.Job
+
+// Config holds the queue settings shared with the CLI.
+type Config = config.Config
+
+// ListFilter selects jobs for List. Zero fields match everything.
+type ListFilter = storage.JobFilter
+
+// Page is one page of List results. Pass its NextCursor as the Cursor of the
+// same filter to fetch the following page.
+type Page = storage.JobPage
+
+// Job states.
+const (
+	StatePending    = model.StatePending
+	StateProcessing = model.StateProcessing
+	StateCompleted  = model.StateCompleted
+	StateFailed     = model.StateFailed
+	StateDead       = model.StateDead
+	StateCanceled   = model.StateCanceled
+	StateExpired    = model.StateExpired
+)
+
+// Errors returned by Client methods. Use errors.Is to test for them.
+var (
+	ErrNotFound     = storage.ErrNotFound
+	ErrDuplicate    = storage.ErrDuplicate
+	ErrInvalidState = storage.ErrInvalidState
+)
+
+// waitPollInterval is how often Wait checks a job's state.
+const waitPollInterval = 500 * time.Millisecond
+
+// Client reads and writes a queue database.
+type Client struct {
+	store *storage.Store
+	cfg   *Config
+	// notifier is told about the jobs the client cancels.
+	notifier *notify.Dispatcher
+}
+
+// LoadConfig reads the configuration the CLI uses, creating it with
+// defaults on first use.
+func LoadConfig() (*Config, error) {
+	return config.LoadConfig()
+}
+
+// DefaultConfig returns the built-in configuration.
+func DefaultConfig() *Config {
+	return config.NewConfig()
+}
+
+// Open opens the queue database in cfg.DataDir, creating it if needed.
+func Open(cfg *Config) (*Client, error) {
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return nil, err
+	}
+	store, err := storage.NewStore(filepath.Join(cfg.DataDir, "queue.db"))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{store: store, cfg: cfg, notifier: notify.New(cfg.Notifiers)}, nil
+}
+
+// Close waits briefly for notifications still being delivered and
+// releases the database handle.
+func (c *Client) Close() error {
+	c.notifier.Close(poolShutdownGrace)
+	return c.store.Db.Close()
+}
+
+// Enqueue validates job, fills in its state, timestamps and default max
+// retries, and stores it. It returns ErrDuplicate if the ID is taken.
+func (c *Client) Enqueue(job Job) (*Job, error) {
+	submitted, _ := json.Marshal(job)
+	err := job.Prepare(time.Now(), c.cfg.MaxRetries)
+	if err == nil {
+		err = c.store.CreateJob(&job)
+	}
+	audit.Record(c.store, model.AuditSourceAPI, "enqueue", []string{string(submitted)}, err)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Get returns the job with the given ID, or ErrNotFound.
+func (c *Client) Get(id string) (*Job, error) {
+	return c.store.GetJob(id)
+}
+
+// List returns a page of the jobs matching filter, oldest first unless the
+// filter sorts otherwise.
+func (c *Client) List(filter ListFilter) (*Page, error) {
+	return c.store.ListJobs(filter)
+}
+
+// Cancel stops a pending, failed or processing job from running again and
+// tells the notifiers subscribed to canceled jobs. It returns ErrNotFound
+// for an unknown ID and ErrInvalidState if the job has already reached a
+// final state.
+func (c *Client) Cancel(id string) error {
+	err := c.store.CancelJob(id)
+	audit.Record(c.store, model.AuditSourceAPI, "job cancel", []string{id}, err)
+	if err != nil {
+		return err
+	}
+	if job, err := c.store.GetJob(id); err == nil {
+		c.notifier.Notify(job)
+	}
+	return nil
+}
+
+// RetryDead moves a job from the dead letter queue back to pending with its
+// attempts reset. It returns ErrInvalidState if the job is not dead.
+func (c *Client) RetryDead(id string) error {
+	err := c.store.RetryDeadJob(id)
+	audit.Record(c.store, model.AuditSourceAPI, "dlq retry", []string{id}, err)
+	return err
+}
+
+// Stats returns the number of jobs in each state.
+func (c *Client) Stats() (map[string]int, error) {
+	return c.store.GetJobStats()
+}
+
+// Wait blocks until the job is completed, dead, canceled or expired and
+// returns it in that state. It returns ctx's error if ctx ends first.
+func (c *Client) Wait(ctx context.Context, id string) (*Job, error) {
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for {
+		job, err := c.store.GetJob(id)
+		if err != nil {
+			return nil, err
+		}
+		if model.IsTerminal(job.State) {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package queuectl
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"queueCtl/internal/config"
+	"queueCtl/internal/database"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestClient(t *testing.T) *Client {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	c, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestWaitForExpiredJob(t *testing.T) {
+	c := openTestClient(t)
+	if _, err := c.Enqueue(Job{ID: "a", Command: "true", TTL: "1s"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.store.ExpireJobs(time.Now().Add(time.Minute), nil); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := c.Wait(ctx, "a")
+	if err != nil || job.State != StateExpired {
+		t.Errorf("Wait = %+v, %v, want the expired job", job, err)
+	}
+}
+
+func TestCancelNotifies(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DataDir = t.TempDir()
+	events := filepath.Join(cfg.DataDir, "events")
+	cfg.Notifiers = []config.Notifier{{Name: "log", States: []string{StateCanceled}, Command: "cat > " + events}}
+	c, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Enqueue(Job{ID: "a", Command: "true"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Cancel("a"); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	got, err := os.ReadFile(events)
+	if err != nil || !strings.Contains(string(got), `"state":"canceled"`) {
+		t.Errorf("notifier got %q, %v, want the canceled event", got, err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	c := openTestClient(t)
+
+	job, err := c.Enqueue(Job{ID: "a", Command: "echo a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != StatePending || job.MaxRetries != 3 || job.Queue != "default" {
+		t.Errorf("enqueued job = %+v", job)
+	}
+	if _, err := c.Enqueue(Job{ID: "a", Command: "echo again"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate Enqueue error = %v, want ErrDuplicate", err)
+	}
+	if _, err := c.Enqueue(Job{ID: "b"}); err == nil {
+		t.Error("a shell job without a command was enqueued")
+	}
+	if _, err := c.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get error = %v, want ErrNotFound", err)
+	}
+	if err := c.RetryDead("a"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("RetryDead of a pending job error = %v, want ErrInvalidState", err)
+	}
+
+	if err := c.Cancel("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Cancel("a"); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("second Cancel error = %v, want ErrInvalidState", err)
+	}
+	got, err := c.Wait(context.Background(), "a")
+	if err != nil || got.State != StateCanceled {
+		t.Errorf("Wait = %+v, %v, want the canceled job", got, err)
+	}
+
+	if _, err := c.Enqueue(Job{ID: "c", Command: "true"}); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := c.Stats()
+	if err != nil || stats[StatePending] != 1 || stats[StateCanceled] != 1 {
+		t.Errorf("Stats = %v, %v", stats, err)
+	}
+	page, err := c.List(ListFilter{States: []string{StatePending}})
+	if err != nil || len(page.Jobs) != 1 || page.Jobs[0].ID != "c" {
+		t.Errorf("List = %+v, %v", page, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Wait(ctx, "c"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait on a pending job error = %v, want the context's", err)
+	}
+
+	// Changes are audited with the api source, including failed ones.
+	entries, err := c.store.ListAudit(storage.AuditFilter{Source: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actions []string
+	for _, e := range entries {
+		actions = append(actions, e.Action+" "+e.Outcome)
+	}
+	want := []string{"enqueue ok", "enqueue error", "enqueue error", "dlq retry error", "job cancel ok", "job cancel error", "enqueue ok"}
+	if len(actions) != len(want) {
+		t.Fatalf("audited %v, want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("audited %v, want %v", actions, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package queuectl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"queueCtl/internal/config"
+	"queueCtl/internal/model"
+	"queueCtl/internal/notify"
+	"queueCtl/internal/tracing"
+	"queueCtl/internal/worker"
+	"sync"
+	"time"
+)
+
+// HandlerFunc runs a job in-process. Returning an error fails the attempt,
+// which is then retried with backoff and moved to the DLQ like a failing
+// shell command. ctx is canceled when the job times out, is canceled or the
+// pool stops.
+type HandlerFunc = worker.HandlerFunc
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = make(map[string]HandlerFunc)
+)
+
+// Register makes fn the handler for jobs whose type is jobType, in the
+// worker pools RunWorkers starts. It panics if jobType is empty, is
+// "shell", or already has a handler.
+func Register(jobType string, fn HandlerFunc) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	if jobType == "" || jobType == model.TypeShell {
+		panic(fmt.Sprintf("queuectl: cannot register a handler for job type %q", jobType))
+	}
+	if fn == nil {
+		panic("queuectl: Register handler is nil")
+	}
+	if _, dup := handlers[jobType]; dup {
+		panic("queuectl: Register called twice for job type " + jobType)
+	}
+	handlers[jobType] = fn
+}
+
+// Env returns the environment variables of the job a handler is running,
+// including the QUEUECTL_PARENT_* variables of a follow-up job and, when
+// the job is traced, TRACEPARENT.
+func Env(ctx context.Context) map[string]string {
+	return worker.Env(ctx)
+}
+
+// poolShutdownGrace is how long a stopping pool waits for notifications and
+// spans still being delivered.
+const poolShutdownGrace = 5 * time.Second
+
+// RunWorkers runs a pool of count workers on the queue until ctx is
+// canceled. The workers run shell jobs and jobs of every type registered
+// with Register; jobs of other types are left for pools that can run them.
+// Notifications and tracing follow the client's configuration.
+//
+// Once ctx is canceled, running jobs are interrupted and returned to the
+// queue, and RunWorkers returns nil when every worker has stopped.
+func (c *Client) RunWorkers(ctx context.Context, count int) error {
+	if count < 1 {
+		return errors.New("queuectl: RunWorkers needs at least one worker")
+	}
+	tracer, err := tracing.New(tracing.Options{
+		Exporter:    c.cfg.Tracing.Exporter,
+		Endpoint:    c.cfg.Tracing.Endpoint,
+		Headers:     c.cfg.Tracing.Headers,
+		File:        c.cfg.Tracing.File,
+		ServiceName: c.cfg.Tracing.ServiceName,
+	})
+	if err != nil {
+		return err
+	}
+	notifier := notify.New(c.cfg.Notifiers)
+
+	handlersMu.RLock()
+	registered := maps.Clone(handlers)
+	handlersMu.RUnlock()
+
+	pool := &worker.Pool{
+		Count:    count,
+		Store:    c.store,
+		Config:   config.NewLive(c.cfg),
+		Notifier: notifier,
+		Tracer:   tracer,
+		Handlers: registered,
+	}
+	pool.Run(ctx)
+
+	notifier.Close(poolShutdownGrace)
+	flushCtx, cancel := context.WithTimeout(context.Background(), poolShutdownGrace)
+	defer cancel()
+	return tracer.Shutdown(flushCtx)
+}
@@ -0,0 +1,71 @@
+package queuectl
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRunWorkers(t *testing.T) {
+	c := openTestClient(t)
+	done := make(chan string, 1)
+	Register("test.greet", func(ctx context.Context, payload json.RawMessage) error {
+		var p struct{ Name string }
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return err
+		}
+		done <- p.Name
+		return nil
+	})
+	if _, err := c.Enqueue(Job{ID: "greet", Type: "test.greet", Payload: json.RawMessage(`{"name":"Ada"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Enqueue(Job{ID: "shell", Command: "true"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() { stopped <- c.RunWorkers(ctx, 2) }()
+	defer func() {
+		cancel()
+		if err := <-stopped; err != nil {
+			t.Errorf("RunWorkers = %v", err)
+		}
+	}()
+
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelWait()
+	for _, id := range []string{"greet", "shell"} {
+		job, err := c.Wait(waitCtx, id)
+		if err != nil || job.State != StateCompleted {
+			t.Fatalf("%s = %+v, %v, want completed", id, job, err)
+		}
+	}
+	if name := <-done; name != "Ada" {
+		t.Errorf("handler got %q", name)
+	}
+	if err := c.RunWorkers(context.Background(), 0); err == nil {
+		t.Error("RunWorkers started without workers")
+	}
+}
+
+func TestRegisterRejects(t *testing.T) {
+	Register("test.once", func(context.Context, json.RawMessage) error { return nil })
+	for name, register := range map[string]func(){
+		"shell":     func() { Register("shell", func(context.Context, json.RawMessage) error { return nil }) },
+		"empty":     func() { Register("", func(context.Context, json.RawMessage) error { return nil }) },
+		"nil":       func() { Register("test.nil", nil) },
+		"duplicate": func() { Register("test.once", func(context.Context, json.RawMessage) error { return nil }) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: Register did not panic", name)
+				}
+			}()
+			register()
+		}()
+	}
+}